@@ -0,0 +1,333 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// maxAttributeExamples is the number of distinct example values kept per attribute
+const maxAttributeExamples = 3
+
+func runAnalyze(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("analyze", "[files or directories...]", stderr)
+	top := flags.Int("top", 5, "number of most frequent event names a starter configuration is suggested for")
+	configOut := flags.String("config-out", "", "write a starter connector configuration to this file, - for stdout after the report")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *top < 1 {
+		return &exitError{code: 2, err: errors.New("-top must be positive")}
+	}
+	inputs, err := expandInputs(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	corpus := newCorpusStats()
+	for _, in := range inputs {
+		batches, err := in.read(stdin)
+		if err != nil {
+			return err
+		}
+		for _, td := range batches {
+			corpus.add(td)
+		}
+	}
+	corpus.inputs = len(inputs)
+
+	if err := corpus.write(stdout); err != nil {
+		return err
+	}
+	switch *configOut {
+	case "":
+		return nil
+	case "-":
+		fmt.Fprintln(stdout)
+		_, err = io.WriteString(stdout, corpus.starterConfig(*top))
+		return err
+	default:
+		return os.WriteFile(*configOut, []byte(corpus.starterConfig(*top)), 0o644)
+	}
+}
+
+// attributeStats describes one attribute key across the occurrences of an event name
+type attributeStats struct {
+	key   string
+	count int
+	types map[string]int
+	// examples are distinct values in the order they were first seen
+	examples []string
+}
+
+func (a *attributeStats) add(v pcommon.Value) {
+	a.count++
+	a.types[v.Type().String()]++
+	if len(a.examples) >= maxAttributeExamples {
+		return
+	}
+	example := v.AsString()
+	if !slices.Contains(a.examples, example) {
+		a.examples = append(a.examples, example)
+	}
+}
+
+// typeNames returns the value types of the attribute, most frequent first
+func (a *attributeStats) typeNames() string {
+	return strings.Join(sortedByCount(a.types), "|")
+}
+
+// eventStats describes all occurrences of one event name
+type eventStats struct {
+	name      string
+	count     int
+	services  map[string]int
+	spanKinds map[string]int
+	// attributes are the event attributes; spanAttributes the attributes of the spans that
+	// carry the event, counted once per event
+	attributes     map[string]*attributeStats
+	spanAttributes map[string]*attributeStats
+}
+
+type corpusStats struct {
+	inputs   int
+	spans    int
+	events   int
+	services map[string]bool
+	byName   map[string]*eventStats
+}
+
+func newCorpusStats() *corpusStats {
+	return &corpusStats{services: make(map[string]bool), byName: make(map[string]*eventStats)}
+}
+
+func (c *corpusStats) add(td ptrace.Traces) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		service := "unknown"
+		if v, ok := rs.Resource().Attributes().Get("service.name"); ok {
+			service = v.AsString()
+		}
+		c.services[service] = true
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			c.spans += spans.Len()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				for l := 0; l < span.Events().Len(); l++ {
+					c.addEvent(service, span, span.Events().At(l))
+				}
+			}
+		}
+	}
+}
+
+func (c *corpusStats) addEvent(service string, span ptrace.Span, event ptrace.SpanEvent) {
+	c.events++
+	e, ok := c.byName[event.Name()]
+	if !ok {
+		e = &eventStats{
+			name:           event.Name(),
+			services:       make(map[string]int),
+			spanKinds:      make(map[string]int),
+			attributes:     make(map[string]*attributeStats),
+			spanAttributes: make(map[string]*attributeStats),
+		}
+		c.byName[event.Name()] = e
+	}
+	e.count++
+	e.services[service]++
+	e.spanKinds[span.Kind().String()]++
+	addAttributes(e.attributes, event.Attributes())
+	addAttributes(e.spanAttributes, span.Attributes())
+}
+
+func addAttributes(stats map[string]*attributeStats, attrs pcommon.Map) {
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		a, ok := stats[k]
+		if !ok {
+			a = &attributeStats{key: k, types: make(map[string]int)}
+			stats[k] = a
+		}
+		a.add(v)
+		return true
+	})
+}
+
+// sortedEvents returns the event names from the most to the least frequent
+func (c *corpusStats) sortedEvents() []*eventStats {
+	events := make([]*eventStats, 0, len(c.byName))
+	for _, e := range c.byName {
+		events = append(events, e)
+	}
+	slices.SortFunc(events, func(a, b *eventStats) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), strings.Compare(a.name, b.name))
+	})
+	return events
+}
+
+// sortedAttributes returns attributes from the most to the least frequent
+func sortedAttributes(stats map[string]*attributeStats) []*attributeStats {
+	attrs := make([]*attributeStats, 0, len(stats))
+	for _, a := range stats {
+		attrs = append(attrs, a)
+	}
+	slices.SortFunc(attrs, func(a, b *attributeStats) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), strings.Compare(a.key, b.key))
+	})
+	return attrs
+}
+
+// sortedByCount returns the keys of counts from the most to the least frequent
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), strings.Compare(a, b))
+	})
+	return keys
+}
+
+// formatCounts renders counts as "a (3), b (1)"
+func formatCounts(counts map[string]int) string {
+	keys := sortedByCount(counts)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s (%d)", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (c *corpusStats) write(w io.Writer) error {
+	fmt.Fprintf(w, "Corpus: %d inputs, %d services, %d spans, %d span events with %d names\n", c.inputs, len(c.services), c.spans, c.events, len(c.byName))
+	events := c.sortedEvents()
+	if len(events) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT\tCOUNT\tSERVICES\tSPAN KINDS")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", e.name, e.count, formatCounts(e.services), formatCounts(e.spanKinds))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		fmt.Fprintf(w, "\n%s (%d)\n", e.name, e.count)
+		writeAttributes(w, "Event attributes", e.attributes, e.count, true)
+		writeAttributes(w, "Attributes of the spans with this event", e.spanAttributes, e.count, false)
+	}
+	return nil
+}
+
+func writeAttributes(w io.Writer, title string, stats map[string]*attributeStats, events int, examples bool) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  %s:\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, a := range sortedAttributes(stats) {
+		fmt.Fprintf(tw, "    %s\t%s\t%d/%d", a.key, a.typeNames(), a.count, events)
+		if examples {
+			quoted := make([]string, len(a.examples))
+			for i, example := range a.examples {
+				quoted[i] = strconv.Quote(truncate(example, 40))
+			}
+			fmt.Fprintf(tw, "\t%s", strings.Join(quoted, ", "))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// preferredBodyAttributes are event attributes that make a good log body, in order
+var preferredBodyAttributes = []string{"exception.type", "exception.message", "error.type", "error.message", "message", "log.message"}
+
+// bodyAttributes picks up to two attributes for the suggested body template of e: the
+// preferred ones present on most events, else the most common short string attributes
+func (e *eventStats) bodyAttributes() []string {
+	common := func(a *attributeStats) bool {
+		return a.count*10 >= e.count*9 && a.types[pcommon.ValueTypeStr.String()] == a.count
+	}
+	var keys []string
+	for _, key := range preferredBodyAttributes {
+		if a, ok := e.attributes[key]; ok && common(a) && len(keys) < 2 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		return keys
+	}
+	for _, a := range sortedAttributes(e.attributes) {
+		if len(keys) == 2 {
+			break
+		}
+		if common(a) && !strings.Contains(a.key, "stacktrace") {
+			keys = append(keys, a.key)
+		}
+	}
+	return keys
+}
+
+// bodyTemplate suggests a log body template for e
+func (e *eventStats) bodyTemplate() string {
+	placeholders := make([]string, 0, 2)
+	for _, key := range e.bodyAttributes() {
+		placeholders = append(placeholders, fmt.Sprintf("{{index .EventAttributes %s}}", strconv.Quote(key)))
+	}
+	if len(placeholders) == 0 {
+		return "{{.SpanName}}: {{.EventName}}"
+	}
+	return "{{.SpanName}}: " + strings.Join(placeholders, ": ")
+}
+
+// starterConfig suggests a connector configuration converting the top most frequent events
+func (c *corpusStats) starterConfig(top int) string {
+	events := c.sortedEvents()
+	if len(events) > top {
+		events = events[:top]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Starter configuration suggested by spanevents2log analyze from %d inputs\n", c.inputs)
+	fmt.Fprintf(&b, "# (%d spans, %d span events). Review the conditions and templates before use.\n", c.spans, c.events)
+	if len(events) == 0 {
+		b.WriteString("# The corpus has no span events, so there is nothing to convert.\n")
+		b.WriteString("event_conditions: []\n")
+		return b.String()
+	}
+	b.WriteString("event_conditions:\n")
+	for _, e := range events {
+		fmt.Fprintf(&b, "  # %d events from %s\n", e.count, formatCounts(e.services))
+		fmt.Fprintf(&b, "  - %s\n", yamlQuote("name == "+strconv.Quote(e.name)))
+	}
+	b.WriteString("include_span_attributes: true\n")
+	b.WriteString("include_event_attributes: true\n")
+	b.WriteString("body_templates:\n")
+	for _, e := range events {
+		fmt.Fprintf(&b, "  %s:\n", yamlQuote(e.name))
+		fmt.Fprintf(&b, "    template: %s\n", yamlQuote(e.bodyTemplate()))
+	}
+	return b.String()
+}
+
+// yamlQuote returns s as a single-quoted YAML scalar
+func yamlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestRunAnalyze(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "starter.yaml")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"analyze", "-config-out", configPath, exceptionTraces}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"67 spans, 3 span events with 1 names",
+		"exception  3      loadgenerator (3)  Client (3)",
+		`exception.type        Str  3/3  "requests.exceptions.ConnectionError"`,
+		"http.method  Str  3/3",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+
+	cfg, err := loadConfig(configPath, "")
+	if err != nil {
+		t.Fatalf("starter configuration does not load: %v", err)
+	}
+	if len(cfg.EventConditions) != 1 || cfg.EventConditions[0] != `name == "exception"` {
+		t.Errorf("event_conditions = %v", cfg.EventConditions)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"convert", "-config", configPath, "-format", "ndjson", exceptionTraces}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("convert exit code = %d: %s", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d records, want 3", len(lines))
+	}
+	var record harness.Record
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := record.Body.(string); !strings.HasPrefix(body, "GET: requests.exceptions.ConnectionError: HTTPConnectionPool") {
+		t.Errorf("body = %q", record.Body)
+	}
+}
+
+func TestEventStats_BodyTemplate(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs []map[string]any
+		want  string
+	}{
+		{
+			name:  "exception",
+			attrs: []map[string]any{{"exception.type": "E", "exception.message": "m", "exception.stacktrace": "s"}},
+			want:  `{{.SpanName}}: {{index .EventAttributes "exception.type"}}: {{index .EventAttributes "exception.message"}}`,
+		},
+		{
+			name:  "common string attributes",
+			attrs: []map[string]any{{"retry.reason": "timeout", "retry.attempt": 1}, {"retry.reason": "reset", "retry.attempt": 2}},
+			want:  `{{.SpanName}}: {{index .EventAttributes "retry.reason"}}`,
+		},
+		{
+			name:  "rare attributes",
+			attrs: []map[string]any{{"detail": "x"}, {}},
+			want:  "{{.SpanName}}: {{.EventName}}",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			td := ptrace.NewTraces()
+			span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+			for _, attrs := range tc.attrs {
+				event := span.Events().AppendEmpty()
+				event.SetName(tc.name)
+				if err := event.Attributes().FromRaw(attrs); err != nil {
+					t.Fatal(err)
+				}
+			}
+			corpus := newCorpusStats()
+			corpus.add(td)
+			if got := corpus.byName[tc.name].bodyTemplate(); got != tc.want {
+				t.Errorf("bodyTemplate() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,502 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+// defaultCheckpointName is the checkpoint file created in the output directory
+const defaultCheckpointName = ".spanevents2log-checkpoint"
+
+func runBackfill(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("backfill", "<archive root>", stderr)
+	configPath := flags.String("config", "", "connector configuration YAML, either the connector's settings or a whole collector configuration (required)")
+	connectorName := flags.String("connector", "", "connector to use when the collector configuration has several")
+	outDir := flags.String("out", "", "directory the logs are written to, mirroring the partitions of the archive (required)")
+	from := flags.String("from", "", "only convert partitions ending after this time (UTC), such as 2025-05-23T13:02")
+	to := flags.String("to", "", "only convert partitions starting before this time (UTC)")
+	year := flags.Int("year", 0, "year of partitions without a year= key (default: the year of -from or -to, else the current year)")
+	workers := flags.Int("workers", runtime.GOMAXPROCS(0), "files converted in parallel")
+	format := flags.String("format", formatOTLP, "output format: otlp or ndjson")
+	checkpointPath := flags.String("checkpoint", "", "checkpoint file recording the converted files (default: "+defaultCheckpointName+" in -out)")
+	restart := flags.Bool("restart", false, "ignore the checkpoint and convert every selected file again")
+	verbose := flags.Bool("v", false, "log the connector's debug messages to stderr")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" || *outDir == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return &exitError{code: 2, err: errors.New("-config, -out and one archive root are required")}
+	}
+	if *format != formatOTLP && *format != formatNDJSON {
+		return &exitError{code: 2, err: fmt.Errorf("invalid format %q, must be otlp or ndjson", *format)}
+	}
+	if *workers < 1 {
+		return &exitError{code: 2, err: fmt.Errorf("invalid workers %d, must be positive", *workers)}
+	}
+	selection, err := newTimeRange(*from, *to, *year)
+	if err != nil {
+		return &exitError{code: 2, err: err}
+	}
+
+	cfg, err := loadConfig(*configPath, *connectorName)
+	if err != nil {
+		return err
+	}
+	files, err := findPartitionFiles(flags.Arg(0), selection)
+	if err != nil {
+		return err
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = filepath.Join(*outDir, defaultCheckpointName)
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return err
+	}
+	hash, err := configHash(cfg, *format)
+	if err != nil {
+		return err
+	}
+	checkpoint, err := openCheckpoint(*checkpointPath, hash, *restart)
+	if err != nil {
+		return err
+	}
+	defer checkpoint.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	b := &backfill{
+		cfg:        cfg,
+		root:       flags.Arg(0),
+		outDir:     *outDir,
+		format:     *format,
+		logger:     newLogger(stderr, *verbose),
+		checkpoint: checkpoint,
+		stderr:     stderr,
+	}
+	report, err := b.run(ctx, files, *workers)
+	if err != nil {
+		return err
+	}
+	if err := report.write(stdout); err != nil {
+		return err
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return &exitError{code: 130, err: errors.New("interrupted, run the same command again to resume")}
+	case report.total.failed > 0:
+		return &exitError{code: 1, err: fmt.Errorf("%d files failed", report.total.failed)}
+	}
+	return nil
+}
+
+func newTimeRange(from, to string, year int) (timeRange, error) {
+	var r timeRange
+	var err error
+	if r.from, err = parseTimeFlag(from); err != nil {
+		return r, err
+	}
+	if r.to, err = parseTimeFlag(to); err != nil {
+		return r, err
+	}
+	if !r.from.IsZero() && !r.to.IsZero() && !r.from.Before(r.to) {
+		return r, fmt.Errorf("-from %s must be before -to %s", from, to)
+	}
+	switch {
+	case year != 0:
+		r.year = year
+	case !r.from.IsZero():
+		r.year = r.from.Year()
+	case !r.to.IsZero():
+		r.year = r.to.Year()
+	default:
+		r.year = time.Now().UTC().Year()
+	}
+	return r, nil
+}
+
+// partitionFile is one archived trace file selected for the backfill
+type partitionFile struct {
+	// rel is the path relative to the archive root, with forward slashes
+	rel       string
+	partition partition
+}
+
+// findPartitionFiles walks the archive root for trace files in partitions within r
+func findPartitionFiles(root string, r timeRange) ([]partitionFile, error) {
+	var files []partitionFile
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isTraceFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		p, err := parsePartition(filepath.Dir(rel))
+		if err != nil {
+			return err
+		}
+		if r.contains(p) {
+			files = append(files, partitionFile{rel: filepath.ToSlash(rel), partition: p})
+		}
+		return nil
+	})
+	slices.SortFunc(files, func(a, b partitionFile) int { return strings.Compare(a.rel, b.rel) })
+	return files, err
+}
+
+// fileResult is what converting one file produced; it is also the checkpoint entry
+type fileResult struct {
+	File    string `json:"file"`
+	Spans   int    `json:"spans"`
+	Records int    `json:"records"`
+	err     error
+}
+
+// configHash identifies the connector settings, the template files they read and the
+// output format a checkpoint was written with
+func configHash(cfg *spaneventstologconnector.Config, format string) (string, error) {
+	content, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash the configuration: %w", err)
+	}
+	h := sha256.New()
+	h.Write(content)
+	fmt.Fprintf(h, "\x00%s", format)
+
+	// Templates read from files are part of the configuration as much as inline ones
+	files := slices.Clone(cfg.TemplateFiles)
+	for _, tc := range cfg.BodyTemplates {
+		if tc.File != "" {
+			files = append(files, tc.File)
+		}
+	}
+	slices.Sort(files)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash the configuration: %w", err)
+		}
+		fmt.Fprintf(h, "\x00%s\x00%d\x00", file, len(content))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkpointHeader is the first line of a checkpoint
+type checkpointHeader struct {
+	Config string `json:"config"`
+}
+
+// checkpointFile appends one JSON line per converted file, so an interrupted run can
+// skip them when it is started again. Its first line holds the configuration hash, so a
+// run with other settings does not skip files converted with the old ones.
+type checkpointFile struct {
+	mu   sync.Mutex
+	f    *os.File
+	done map[string]fileResult
+}
+
+func openCheckpoint(path, hash string, restart bool) (*checkpointFile, error) {
+	c := &checkpointFile{done: make(map[string]fileResult)}
+	var found string
+	if !restart {
+		content, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(content)))
+		for scanner.Scan() {
+			var header checkpointHeader
+			if err := json.Unmarshal(scanner.Bytes(), &header); err == nil && header.Config != "" {
+				found = header.Config
+				continue
+			}
+			var result fileResult
+			// A line cut short by an interruption is ignored and its file converted again
+			if err := json.Unmarshal(scanner.Bytes(), &result); err == nil && result.File != "" {
+				c.done[result.File] = result
+			}
+		}
+		if (found != "" || len(c.done) > 0) && found != hash {
+			return nil, fmt.Errorf("checkpoint %s was written with another configuration or format, use -restart to convert every file again", path)
+		}
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if restart {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	c.f = f
+	if found == "" {
+		line, err := json.Marshal(checkpointHeader{Config: hash})
+		if err == nil {
+			_, err = f.Write(append(line, '\n'))
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *checkpointFile) record(result fileResult) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.f.Write(append(line, '\n'))
+	return err
+}
+
+func (c *checkpointFile) close() error {
+	return c.f.Close()
+}
+
+// backfill converts the files of an archive into a mirrored tree of logs
+type backfill struct {
+	cfg        *spaneventstologconnector.Config
+	root       string
+	outDir     string
+	format     string
+	logger     *zap.Logger
+	checkpoint *checkpointFile
+	stderr     io.Writer
+}
+
+// run converts the files not yet in the checkpoint with up to workers goroutines. Every
+// worker has its own connector, so dedup and rate_limit state is kept per worker. When
+// ctx is cancelled the files being converted are finished and the rest is left for the
+// next run.
+func (b *backfill) run(ctx context.Context, files []partitionFile, workers int) (*backfillReport, error) {
+	report := newBackfillReport()
+	var pending []partitionFile
+	for _, file := range files {
+		if result, ok := b.checkpoint.done[file.rel]; ok {
+			report.add(file.partition.dir, result, true)
+			continue
+		}
+		pending = append(pending, file)
+	}
+
+	workers = min(workers, len(pending))
+	conversions := make([]*harness.Conversion, workers)
+	for i := range conversions {
+		conv, err := newConversion(ctx, b.cfg, newTelemetrySettings(b.logger))
+		if err != nil {
+			return nil, err
+		}
+		defer conv.Shutdown(context.Background())
+		conversions[i] = conv
+	}
+
+	jobs := make(chan partitionFile)
+	results := make(chan fileResult)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for _, conv := range conversions {
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				results <- b.convertFile(ctx, conv, file)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, file := range pending {
+			select {
+			case jobs <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	partitions := make(map[string]string, len(pending))
+	for _, file := range pending {
+		partitions[file.rel] = file.partition.dir
+	}
+	for result := range results {
+		if result.err != nil {
+			fmt.Fprintf(b.stderr, "%s: %v\n", result.File, result.err)
+		} else if err := b.checkpoint.record(result); err != nil {
+			result.err = fmt.Errorf("failed to record checkpoint: %w", err)
+			fmt.Fprintf(b.stderr, "%s: %v\n", result.File, result.err)
+		}
+		report.add(partitions[result.File], result, false)
+	}
+	return report, nil
+}
+
+// convertFile converts one archived file and writes its logs, if any, to the mirrored path
+func (b *backfill) convertFile(ctx context.Context, conv *harness.Conversion, file partitionFile) fileResult {
+	result := fileResult{File: file.rel}
+	batches, err := traceInput{path: filepath.Join(b.root, filepath.FromSlash(file.rel))}.read(nil)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	var logs []plog.Logs
+	var pipelines []string
+	for _, td := range batches {
+		result.Spans += td.SpanCount()
+		if err := conv.Convert(ctx, td); err != nil {
+			result.err = err
+		}
+		// Drain even after an error so the next file starts with empty sinks
+		_ = conv.Drain(func(l plog.Logs, pipeline string) error {
+			result.Records += l.LogRecordCount()
+			logs = append(logs, l)
+			pipelines = append(pipelines, pipeline)
+			return nil
+		})
+		if result.err != nil {
+			return result
+		}
+	}
+	if result.Records > 0 {
+		result.err = b.writeLogs(b.outputPath(file.rel), logs, pipelines)
+	}
+	return result
+}
+
+// outputPath mirrors the archived file's path in the output directory
+func (b *backfill) outputPath(rel string) string {
+	ext := ".logs.json"
+	if b.format == formatNDJSON {
+		ext = ".logs.ndjson"
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + ext
+	return filepath.Join(b.outDir, filepath.FromSlash(rel))
+}
+
+// writeLogs writes the logs to a temporary file renamed into place, so a file that exists
+// is always complete
+func (b *backfill) writeLogs(path string, logs []plog.Logs, pipelines []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	w, err := newLogsWriter(b.format, f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	for i := range logs {
+		if err := w.write(logs[i], pipelines[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// partitionCounts sums the results of the files of one partition
+type partitionCounts struct {
+	files   int
+	resumed int
+	failed  int
+	spans   int
+	records int
+}
+
+type backfillReport struct {
+	partitions map[string]*partitionCounts
+	total      partitionCounts
+}
+
+func newBackfillReport() *backfillReport {
+	return &backfillReport{partitions: make(map[string]*partitionCounts)}
+}
+
+// add counts one file of partition dir; resumed files were converted by an earlier run
+func (r *backfillReport) add(dir string, result fileResult, resumed bool) {
+	p, ok := r.partitions[dir]
+	if !ok {
+		p = &partitionCounts{}
+		r.partitions[dir] = p
+	}
+	for _, counts := range []*partitionCounts{p, &r.total} {
+		counts.files++
+		switch {
+		case resumed:
+			counts.resumed++
+		case result.err != nil:
+			counts.failed++
+			continue
+		}
+		counts.spans += result.Spans
+		counts.records += result.Records
+	}
+}
+
+func (r *backfillReport) write(w io.Writer) error {
+	dirs := make([]string, 0, len(r.partitions))
+	for dir := range r.partitions {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PARTITION\tFILES\tRESUMED\tFAILED\tSPANS\tLOG RECORDS")
+	row := func(name string, c *partitionCounts) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", name, c.files, c.resumed, c.failed, c.spans, c.records)
+	}
+	for _, dir := range dirs {
+		row(dir, r.partitions[dir])
+	}
+	row("total", &r.total)
+	return tw.Flush()
+}
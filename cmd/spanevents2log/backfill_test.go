@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestArchive copies the exception traces into a partitioned archive with one file
+// per minute partition
+func newTestArchive(t *testing.T, minutes ...string) string {
+	t.Helper()
+	content, err := os.ReadFile(exceptionTraces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	for _, minute := range minutes {
+		dir := filepath.Join(root, "month=05", "day=23", "hour=13", "minute="+minute)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "traces_1.json"), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestRunBackfill(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	archive := newTestArchive(t, "04", "05", "06")
+	out := t.TempDir()
+	args := []string{"backfill", "-config", config, "-out", out, "-from", "2025-05-23T13:05", "-to", "2025-05-23T13:07", "-workers", "2", archive}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for minute, want := range map[string]bool{"04": false, "05": true, "06": true} {
+		_, err := os.Stat(filepath.Join(out, "month=05", "day=23", "hour=13", "minute="+minute, "traces_1.logs.json"))
+		if got := err == nil; got != want {
+			t.Errorf("minute=%s written = %v, want %v", minute, got, want)
+		}
+	}
+	if !strings.Contains(stdout.String(), "month=05/day=23/hour=13/minute=05") || strings.Contains(stdout.String(), "minute=04") {
+		t.Errorf("unexpected report:\n%s", stdout.String())
+	}
+	if fields := strings.Fields(lastLine(stdout.String())); strings.Join(fields, " ") != "total 2 0 0 134 6" {
+		t.Errorf("total = %v, want 2 files, 134 spans and 6 records", fields)
+	}
+
+	// A second run resumes from the checkpoint and reports the same counts
+	stdout.Reset()
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if fields := strings.Fields(lastLine(stdout.String())); strings.Join(fields, " ") != "total 2 2 0 134 6" {
+		t.Errorf("total = %v, want 2 resumed files", fields)
+	}
+}
+
+func TestRunBackfill_Failures(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	archive := newTestArchive(t, "05")
+	broken := filepath.Join(archive, "month=05", "day=23", "hour=13", "minute=05", "traces_2.json")
+	if err := os.WriteFile(broken, []byte(`{"resourceSpans": 1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	args := []string{"backfill", "-config", config, "-out", out, archive}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code = %d, want 1: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "traces_2.json") {
+		t.Errorf("stderr does not name the failed file: %s", stderr.String())
+	}
+
+	// Failed files are not checkpointed, so they are retried
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if fields := strings.Fields(lastLine(stdout.String())); strings.Join(fields, " ") != "total 1 1 0 67 3" {
+		t.Errorf("total = %v, want the resumed file only", fields)
+	}
+}
+
+func TestRunBackfill_ConfigChanged(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	archive := newTestArchive(t, "05")
+	out := t.TempDir()
+	args := []string{"backfill", "-config", config, "-out", out, archive}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+
+	// The checkpoint of another format does not apply
+	stderr.Reset()
+	changed := append([]string{"backfill", "-format", "ndjson"}, args[1:]...)
+	if code := run(changed, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code = %d, want 1: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "-restart") {
+		t.Errorf("stderr does not suggest -restart: %s", stderr.String())
+	}
+
+	stdout.Reset()
+	restarted := append([]string{"backfill", "-restart"}, changed[1:]...)
+	if code := run(restarted, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if fields := strings.Fields(lastLine(stdout.String())); strings.Join(fields, " ") != "total 1 0 0 67 3" {
+		t.Errorf("total = %v, want the file converted again", fields)
+	}
+}
+
+func TestRunBackfill_TemplateChanged(t *testing.T) {
+	template := writeTestFile(t, "exception.tmpl", "{{.SpanName}}: {{.EventName}}")
+	config := writeTestFile(t, "config.yaml", `
+event_conditions:
+  - 'name == "exception"'
+body_templates:
+  exception:
+    file: `+template+`
+`)
+	archive := newTestArchive(t, "05")
+	args := []string{"backfill", "-config", config, "-out", t.TempDir(), archive}
+
+	var stdout, stderr bytes.Buffer
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+
+	// Resuming with an edited template would mix the output of both versions
+	if err := os.WriteFile(template, []byte("{{.EventName}} in {{.SpanName}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := run(args, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code = %d, want 1: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "-restart") {
+		t.Errorf("stderr does not suggest -restart: %s", stderr.String())
+	}
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestTimeRange(t *testing.T) {
+	r, err := newTimeRange("2025-05-23T13:05", "2025-05-23T14", 0)
+	if err != nil {
+		t.Fatalf("newTimeRange() error = %v", err)
+	}
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{dir: "month=05/day=23/hour=13/minute=05", want: true},
+		{dir: "month=05/day=23/hour=13/minute=04", want: false},
+		{dir: "month=05/day=23/hour=14/minute=00", want: false},
+		{dir: "year=2024/month=05/day=23/hour=13/minute=30", want: false},
+		{dir: "region=eu/month=05/day=23/hour=13/minute=59", want: true},
+		{dir: "month=05/day=23/hour=13", want: true},
+		{dir: "month=05/day=23/hour=12", want: false},
+		{dir: "month=05/day=23", want: true},
+		{dir: "month=05/day=24", want: false},
+		{dir: ".", want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.dir, func(t *testing.T) {
+			p, err := parsePartition(tc.dir)
+			if err != nil {
+				t.Fatalf("parsePartition() error = %v", err)
+			}
+			if got := r.contains(p); got != tc.want {
+				t.Errorf("contains() = %v, want %v (start %s, end %s)", got, tc.want, p.start(r.year).Format(time.RFC3339), p.end(r.year).Format(time.RFC3339))
+			}
+		})
+	}
+
+	if _, err := parsePartition("month=may"); err == nil {
+		t.Error("parsePartition() expected error for a month that is not a number")
+	}
+	if _, err := newTimeRange("2025-05-23T14", "2025-05-23T13", 0); err == nil {
+		t.Error("newTimeRange() expected error when -from is not before -to")
+	}
+}
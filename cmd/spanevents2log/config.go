@@ -0,0 +1,17 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+)
+
+// loadConfig reads the connector configuration from a YAML file. The file holds either the
+// connector's own settings or a whole collector configuration; in the latter case the
+// connector is looked up under connectors by name, which defaults to the only
+// spaneventstolog connector.
+func loadConfig(path, name string) (*spaneventstologconnector.Config, error) {
+	return harness.LoadConfig(path, name, spaneventstologconnector.NewFactory())
+}
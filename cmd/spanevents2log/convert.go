@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("convert", "[files or directories...]", stderr)
+	configPath := flags.String("config", "", "connector configuration YAML, either the connector's settings or a whole collector configuration (required)")
+	connectorName := flags.String("connector", "", "connector to use when the collector configuration has several, such as spaneventstolog/errors")
+	format := flags.String("format", formatOTLP, "output format: "+strings.Join(outputFormats, ", "))
+	verbose := flags.Bool("v", false, "log the connector's debug messages and a summary to stderr")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		flags.Usage()
+		return &exitError{code: 2, err: errors.New("-config is required")}
+	}
+
+	cfg, err := loadConfig(*configPath, *connectorName)
+	if err != nil {
+		return err
+	}
+	inputs, err := expandInputs(flags.Args())
+	if err != nil {
+		return err
+	}
+	out, err := newLogsWriter(*format, stdout)
+	if err != nil {
+		return &exitError{code: 2, err: err}
+	}
+
+	ctx := context.Background()
+	conv, err := newConversion(ctx, cfg, newTelemetrySettings(newLogger(stderr, *verbose)))
+	if err != nil {
+		return err
+	}
+	defer conv.Shutdown(ctx)
+
+	var spans, records int
+	write := func(logs plog.Logs, pipeline string) error {
+		records += logs.LogRecordCount()
+		return out.write(logs, pipeline)
+	}
+	for _, in := range inputs {
+		batches, err := in.read(stdin)
+		if err != nil {
+			return err
+		}
+		for _, td := range batches {
+			spans += td.SpanCount()
+			if err := conv.Convert(ctx, td); err != nil {
+				return fmt.Errorf("%s: %w", in.name(), err)
+			}
+			if err := conv.Drain(write); err != nil {
+				return err
+			}
+		}
+	}
+	// Shutdown emits the rate limit markers of the records suppressed since the last ones
+	if err := conv.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := conv.Drain(write); err != nil {
+		return err
+	}
+	if err := out.flush(); err != nil {
+		return err
+	}
+	if *verbose {
+		fmt.Fprintf(stderr, "converted %d spans from %d inputs into %d log records\n", spans, len(inputs), records)
+	}
+	return nil
+}
+
+// newLogger returns the logger of the connector. Without verbose only warnings and errors,
+// such as failing templates, are written to stderr.
+func newLogger(stderr io.Writer, verbose bool) *zap.Logger {
+	level := zapcore.WarnLevel
+	if verbose {
+		level = zapcore.DebugLevel
+	}
+	encoderConfig := zap.NewDevelopmentEncoderConfig()
+	encoderConfig.TimeKey = ""
+	return zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.AddSync(stderr), level))
+}
+
+// newTelemetrySettings returns telemetry settings that discard everything but the logs
+func newTelemetrySettings(logger *zap.Logger) component.TelemetrySettings {
+	set := componenttest.NewNopTelemetrySettings()
+	set.Logger = logger
+	return set
+}
+
+// newConversion starts the connector in memory. Every logs pipeline the routes reference
+// gets its own sink, so routing behaves as in the collector.
+func newConversion(ctx context.Context, cfg *spaneventstologconnector.Config, telemetry component.TelemetrySettings) (*harness.Conversion, error) {
+	return harness.New(ctx, spaneventstologconnector.NewFactory(), cfg, telemetry)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// exceptionTraces is a recorded trace file with three exception events
+const exceptionTraces = "../../src/realistic_traces/month=05/day=23/hour=13/minute=06/traces_168499423.json"
+
+// writeTestFile writes content to a file in a temporary directory and returns its path
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testConfig = `
+event_conditions:
+  - 'name == "exception"'
+log_level: Error
+log_body_template: "{{.SpanName}}: {{.EventName}}"
+`
+
+func TestRunConvert(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	tests := []struct {
+		name   string
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			name:   "otlp",
+			format: formatOTLP,
+			check: func(t *testing.T, out string) {
+				logs, err := (&plog.JSONUnmarshaler{}).UnmarshalLogs([]byte(out))
+				if err != nil {
+					t.Fatalf("output is not OTLP/JSON: %v", err)
+				}
+				if logs.LogRecordCount() != 3 {
+					t.Errorf("got %d log records, want 3", logs.LogRecordCount())
+				}
+			},
+		},
+		{
+			name:   "ndjson",
+			format: formatNDJSON,
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				if len(lines) != 3 {
+					t.Fatalf("got %d lines, want 3", len(lines))
+				}
+				var record harness.Record
+				if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+					t.Fatalf("output is not a JSON record: %v", err)
+				}
+				if record.Body != "GET: exception" || record.SeverityText != "Error" {
+					t.Errorf("got body %q with severity %q", record.Body, record.SeverityText)
+				}
+				if record.Attributes["event.exception.type"] != "requests.exceptions.ConnectionError" {
+					t.Errorf("event.exception.type = %v", record.Attributes["event.exception.type"])
+				}
+			},
+		},
+		{
+			name:   "pretty",
+			format: formatPretty,
+			check: func(t *testing.T, out string) {
+				if !strings.Contains(out, "ERROR [loadgenerator]\n  GET: exception\n") {
+					t.Errorf("unexpected output:\n%s", out)
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{"convert", "-config", config, "-format", tc.format, exceptionTraces}, nil, &stdout, &stderr); code != 0 {
+				t.Fatalf("exit code %d: %s", code, stderr.String())
+			}
+			tc.check(t, stdout.String())
+		})
+	}
+}
+
+func TestRunConvert_Stdin(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	content, err := os.ReadFile(exceptionTraces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two documents in a row, as the file exporter writes them
+	stdin := bytes.NewReader(append(append(content, '\n'), content...))
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", "-config", config}, stdin, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	lines := 0
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("got %d OTLP documents, want 2", lines)
+	}
+}
+
+func TestRunConvert_Routing(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", `
+connectors:
+  spaneventstolog/errors:
+    event_conditions:
+      - 'name == "exception"'
+    routing:
+      routes:
+        - min_severity: Error
+          pipelines: [logs/errors]
+      default_pipelines: [logs/default]
+`)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", "-config", config, "-format", formatNDJSON, exceptionTraces}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	// The default log_level is Info, so the records miss the error route
+	decoder := json.NewDecoder(&stdout)
+	records := 0
+	for ; decoder.More(); records++ {
+		var record harness.Record
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Pipeline != "logs/default" {
+			t.Errorf("pipeline = %q, want logs/default", record.Pipeline)
+		}
+	}
+	if records != 3 {
+		t.Errorf("got %d records, want 3", records)
+	}
+}
+
+func TestRunConvert_RateLimitMarkers(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", `
+event_conditions:
+  - 'name == "exception"'
+rate_limit:
+  records_per_second: 0.001
+  burst: 1
+  overflow: marker
+  marker_interval: 1h
+`)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", "-config", config, "-format", formatNDJSON, exceptionTraces}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	// The marker is only due at shutdown, which must still be written
+	var bodies []string
+	decoder := json.NewDecoder(&stdout)
+	for decoder.More() {
+		var record harness.Record
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, fmt.Sprint(record.Body))
+	}
+	if len(bodies) != 2 || !strings.Contains(bodies[1], "2") {
+		t.Errorf("bodies = %q, want one record and a marker for the 2 suppressed ones", bodies)
+	}
+}
+
+func TestRunConvert_Errors(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "unknown command", args: []string{"transform"}, code: 2},
+		{name: "missing config", args: []string{"convert", exceptionTraces}, code: 2},
+		{name: "invalid format", args: []string{"convert", "-config", config, "-format", "csv", exceptionTraces}, code: 2},
+		{name: "invalid config", args: []string{"convert", "-config", writeTestFile(t, "invalid.yaml", "log_level: Loud\n"), exceptionTraces}, code: 1},
+		{name: "invalid input", args: []string{"convert", "-config", config, writeTestFile(t, "traces.json", "{\"resourceSpans\": 1}")}, code: 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tc.args, nil, &stdout, &stderr); code != tc.code {
+				t.Errorf("exit code = %d, want %d: %s", code, tc.code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunConvert_JaegerAndZipkin(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	var stdout, stderr bytes.Buffer
+	args := []string{"convert", "-config", config, "-format", formatNDJSON, "../../internal/harness/testdata/jaeger.json", "../../internal/harness/testdata/zipkin.json"}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var bodies []string
+	for decoder := json.NewDecoder(&stdout); decoder.More(); {
+		var record harness.Record
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, record.Body.(string))
+	}
+	want := []string{"GET /api/cart: exception", "post /api/checkout: exception", "charge: exception"}
+	if strings.Join(bodies, "|") != strings.Join(want, "|") {
+		t.Errorf("bodies = %q, want %q", bodies, want)
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// generatedStart is the start time of the first synthetic trace, fixed so that a seed
+// reproduces the same files
+var generatedStart = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func runGenerate(args []string, _ io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("generate", "", stderr)
+	traces := flags.Int("traces", 1000, "number of traces to generate")
+	duration := flags.Duration("duration", 0, "keep generating for this long instead of -traces, for soak tests")
+	batchSize := flags.Int("batch", 100, "traces per batch, which is one output file or one call of the connector")
+	services := flags.Int("services", 5, "number of services")
+	langs := flags.String("languages", strings.Join(languageNames(), ","), "languages of the services, assigned in turn, which select the exception types and stack traces")
+	depth := flags.Int("depth", 2, "span levels below the root span of every trace")
+	fanout := flags.Int("fanout", 2, "child spans of every span above the last level")
+	exceptionRate := flags.Float64("exception-rate", 0.05, "probability that a span records an exception")
+	eventRate := flags.Float64("event-rate", 0.5, "mean number of span events other than exceptions per span")
+	cardinality := flags.Int("cardinality", 1000, "distinct values of the user.id, session.id and cache.key attributes")
+	seed := flags.Uint64("seed", 1, "seed of the generator; the same seed and flags generate the same traces")
+	outPath := flags.String("out", "", "directory the batches are written to as one file each, or - for OTLP/JSON on stdout")
+	format := flags.String("format", "json", "file format with -out: json (OTLP/JSON) or protobuf (OTLP/protobuf)")
+	configPath := flags.String("config", "", "connector configuration YAML; the batches are converted in-process and a performance report is printed")
+	connectorName := flags.String("connector", "", "connector to use when the collector configuration has several")
+	verbose := flags.Bool("v", false, "log the connector's debug messages to stderr")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *outPath == "" && *configPath == "" {
+		flags.Usage()
+		return &exitError{code: 2, err: errors.New("-out or -config is required")}
+	}
+	if *format != "json" && *format != "protobuf" {
+		return &exitError{code: 2, err: fmt.Errorf("invalid format %q, must be json or protobuf", *format)}
+	}
+	if *outPath == "-" && *format == "protobuf" {
+		return &exitError{code: 2, err: errors.New("-format protobuf needs an -out directory, stdout only takes json")}
+	}
+	if *traces < 1 || *batchSize < 1 {
+		return &exitError{code: 2, err: fmt.Errorf("invalid traces %d or batch %d, must be positive", *traces, *batchSize)}
+	}
+	gen := generatorConfig{
+		services:      *services,
+		languages:     strings.Split(*langs, ","),
+		depth:         *depth,
+		fanout:        *fanout,
+		exceptionRate: *exceptionRate,
+		eventRate:     *eventRate,
+		cardinality:   *cardinality,
+		seed:          *seed,
+		start:         generatedStart,
+	}
+	if err := gen.validate(); err != nil {
+		return &exitError{code: 2, err: err}
+	}
+
+	var sink batchSink
+	if *outPath != "" {
+		var err error
+		if sink, err = newBatchSink(*outPath, *format, stdout); err != nil {
+			return err
+		}
+	}
+	var conv *harness.Conversion
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *configPath != "" {
+		cfg, err := loadConfig(*configPath, *connectorName)
+		if err != nil {
+			return err
+		}
+		if conv, err = newConversion(ctx, cfg, newTelemetrySettings(newLogger(stderr, *verbose))); err != nil {
+			return err
+		}
+		defer conv.Shutdown(context.Background())
+	}
+
+	g := newGenerator(gen)
+	report := &generateReport{}
+	deadline := time.Now().Add(*duration)
+	for remaining := *traces; ctx.Err() == nil; {
+		n := *batchSize
+		if *duration > 0 {
+			if time.Now().After(deadline) {
+				break
+			}
+		} else {
+			if remaining == 0 {
+				break
+			}
+			n = min(n, remaining)
+			remaining -= n
+		}
+		td := g.batch(n)
+		report.addBatch(n, td)
+		if sink != nil {
+			if err := sink.write(td); err != nil {
+				return err
+			}
+		}
+		if conv != nil {
+			if err := report.convert(ctx, conv, td); err != nil {
+				return err
+			}
+		}
+	}
+	if sink != nil {
+		if err := sink.close(); err != nil {
+			return err
+		}
+	}
+	if conv != nil {
+		// Shutdown emits the rate limit markers of the records suppressed since the last ones
+		if err := conv.Shutdown(context.Background()); err != nil {
+			return err
+		}
+		if err := report.drain(conv); err != nil {
+			return err
+		}
+	}
+
+	// With traces on stdout, the report goes to stderr
+	w := stdout
+	if *outPath == "-" {
+		w = stderr
+	}
+	if err := report.write(w, conv != nil); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return &exitError{code: 130, err: errors.New("interrupted")}
+	}
+	return nil
+}
+
+// batchSink writes the generated batches
+type batchSink interface {
+	write(td ptrace.Traces) error
+	close() error
+}
+
+func newBatchSink(path, format string, stdout io.Writer) (batchSink, error) {
+	if path == "-" {
+		return &streamSink{w: bufio.NewWriter(stdout)}, nil
+	}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	return &dirSink{dir: path, format: format}, nil
+}
+
+// streamSink writes one OTLP/JSON document per line and batch, which convert reads back
+type streamSink struct {
+	w *bufio.Writer
+}
+
+func (s *streamSink) write(td ptrace.Traces) error {
+	content, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(content); err != nil {
+		return err
+	}
+	return s.w.WriteByte('\n')
+}
+
+func (s *streamSink) close() error {
+	return s.w.Flush()
+}
+
+// dirSink writes every batch to its own numbered file
+type dirSink struct {
+	dir    string
+	format string
+	files  int
+}
+
+func (d *dirSink) write(td ptrace.Traces) error {
+	d.files++
+	var content []byte
+	var err error
+	name := fmt.Sprintf("traces-%06d", d.files)
+	if d.format == "protobuf" {
+		content, err = (&ptrace.ProtoMarshaler{}).MarshalTraces(td)
+		name += ".pb"
+	} else {
+		content, err = (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+		name += ".json"
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(d.dir, name), content, 0o644)
+}
+
+func (d *dirSink) close() error {
+	return nil
+}
+
+// generateReport sums up what was generated and how the connector performed
+type generateReport struct {
+	batches, traces, spans, events, exceptions int
+	// The fields below are only set when the batches are converted
+	records    int
+	elapsed    time.Duration
+	latencies  []time.Duration
+	allocBytes uint64
+	allocs     uint64
+}
+
+func (r *generateReport) addBatch(traces int, td ptrace.Traces) {
+	r.batches++
+	r.traces += traces
+	r.spans += td.SpanCount()
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		ss := td.ResourceSpans().At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				events := spans.At(k).Events()
+				r.events += events.Len()
+				for e := 0; e < events.Len(); e++ {
+					if events.At(e).Name() == "exception" {
+						r.exceptions++
+					}
+				}
+			}
+		}
+	}
+}
+
+// convert passes td to the connector and records the latency and allocations of the call.
+// The memory statistics are read outside the timed section, as reading them stops the world.
+func (r *generateReport) convert(ctx context.Context, conv *harness.Conversion, td ptrace.Traces) error {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	err := conv.Convert(ctx, td)
+	latency := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return err
+	}
+	r.elapsed += latency
+	r.latencies = append(r.latencies, latency)
+	r.allocBytes += after.TotalAlloc - before.TotalAlloc
+	r.allocs += after.Mallocs - before.Mallocs
+	return r.drain(conv)
+}
+
+// drain counts the log records the connector produced since the last drain
+func (r *generateReport) drain(conv *harness.Conversion) error {
+	return conv.Drain(func(logs plog.Logs, _ string) error {
+		r.records += logs.LogRecordCount()
+		return nil
+	})
+}
+
+func (r *generateReport) write(w io.Writer, converted bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "generated\t%d traces, %d spans, %d span events (%d exceptions) in %d batches\n",
+		r.traces, r.spans, r.events, r.exceptions, r.batches)
+	if converted {
+		seconds := r.elapsed.Seconds()
+		if seconds == 0 {
+			seconds = time.Nanosecond.Seconds()
+		}
+		fmt.Fprintf(tw, "converted\t%d log records in %v\n", r.records, r.elapsed.Round(time.Microsecond))
+		fmt.Fprintf(tw, "throughput\t%.0f spans/s, %.0f span events/s, %.0f log records/s\n",
+			float64(r.spans)/seconds, float64(r.events)/seconds, float64(r.records)/seconds)
+		fmt.Fprintf(tw, "allocations\t%.0f B/span, %.1f allocs/span, %s in total\n",
+			float64(r.allocBytes)/float64(max(r.spans, 1)), float64(r.allocs)/float64(max(r.spans, 1)), formatBytes(r.allocBytes))
+		latencies := slices.Clone(r.latencies)
+		slices.Sort(latencies)
+		fmt.Fprintf(tw, "batch latency\tp50 %v, p90 %v, p99 %v, max %v\n",
+			percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99), percentile(latencies, 100))
+	}
+	return tw.Flush()
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1].Round(time.Microsecond)
+}
+
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
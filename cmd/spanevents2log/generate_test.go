@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestRunGenerate_Files(t *testing.T) {
+	generate := func(seed string) map[string]string {
+		out := t.TempDir()
+		var stdout, stderr bytes.Buffer
+		args := []string{"generate", "-traces", "25", "-batch", "10", "-seed", seed, "-exception-rate", "0.3", "-out", out}
+		if code := run(args, nil, &stdout, &stderr); code != 0 {
+			t.Fatalf("exit code %d: %s", code, stderr.String())
+		}
+		// 25 traces of 1 + 2 + 4 spans with the default depth and fanout
+		if !strings.HasPrefix(stdout.String(), "generated  25 traces, 175 spans,") || !strings.HasSuffix(stdout.String(), "in 3 batches\n") {
+			t.Errorf("unexpected report:\n%s", stdout.String())
+		}
+		files := make(map[string]string)
+		inputs, err := expandInputs([]string{out})
+		if err != nil {
+			t.Fatal(err)
+		}
+		spans := 0
+		for _, in := range inputs {
+			content, err := os.ReadFile(in.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[filepath.Base(in.path)] = string(content)
+			batches, err := in.read(nil)
+			if err != nil {
+				t.Fatalf("generated file is not readable: %v", err)
+			}
+			spans += batches[0].SpanCount()
+		}
+		if len(files) != 3 || spans != 175 {
+			t.Errorf("got %d files with %d spans, want 3 files with 175 spans", len(files), spans)
+		}
+		return files
+	}
+
+	first, second, other := generate("7"), generate("7"), generate("8")
+	for name, content := range first {
+		if second[name] != content {
+			t.Errorf("%s differs between runs with the same seed", name)
+		}
+	}
+	if other["traces-000001.json"] == first["traces-000001.json"] {
+		t.Error("a different seed generated the same traces")
+	}
+}
+
+func TestRunGenerate_Convert(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	var stdout, stderr bytes.Buffer
+	args := []string{"generate", "-traces", "200", "-batch", "50", "-exception-rate", "0.2", "-config", config}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	report := stdout.String()
+	for _, want := range []string{"throughput", "allocations", "B/span", "batch latency  p50"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	// The configuration converts every exception, and nothing else
+	exceptions := regexp.MustCompile(`\((\d+) exceptions\)`).FindStringSubmatch(report)
+	records := regexp.MustCompile(`converted\s+(\d+) log records`).FindStringSubmatch(report)
+	if exceptions == nil || records == nil || exceptions[1] != records[1] || exceptions[1] == "0" {
+		t.Errorf("exceptions %v and converted records %v differ:\n%s", exceptions, records, report)
+	}
+}
+
+func TestRunGenerate_RateLimitMarkers(t *testing.T) {
+	// Every resource shares one key, of which only the first record is produced
+	config := writeTestFile(t, "config.yaml", testConfig+`rate_limit:
+  key_attribute: missing
+  records_per_second: 0.001
+  burst: 1
+  overflow: marker
+  marker_interval: 1h
+`)
+	var stdout, stderr bytes.Buffer
+	args := []string{"generate", "-traces", "200", "-batch", "50", "-exception-rate", "0.2", "-config", config}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	// The marker of the suppressed records is emitted at shutdown and counted too
+	if records := regexp.MustCompile(`converted\s+(\d+) log records`).FindStringSubmatch(stdout.String()); records == nil || records[1] != "2" {
+		t.Errorf("converted records = %v, want one record and one marker:\n%s", records, stdout.String())
+	}
+}
+
+func TestRunGenerate_Stdout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"generate", "-traces", "3", "-batch", "2", "-out", "-"}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	batches, err := traceInput{path: "-"}.read(&stdout)
+	if err != nil || len(batches) != 2 {
+		t.Fatalf("read %d batches from stdout, want 2: %v", len(batches), err)
+	}
+	if !strings.HasPrefix(stderr.String(), "generated  3 traces") {
+		t.Errorf("report is not on stderr: %q", stderr.String())
+	}
+}
+
+// failingWriter fails every write, like a closed pipe
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestStreamSink_WriteError(t *testing.T) {
+	td := ptrace.NewTraces()
+	// Larger than the buffer, so the first write reaches the failing writer
+	td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName(strings.Repeat("x", 8192))
+	sink := &streamSink{w: bufio.NewWriter(failingWriter{})}
+	if err := sink.write(td); err == nil || !strings.Contains(err.Error(), "broken pipe") {
+		t.Errorf("write() error = %v, want broken pipe", err)
+	}
+}
+
+func TestRunGenerate_InvalidFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "no output", args: []string{"-traces", "1"}, wantErr: "-out or -config is required"},
+		{name: "format", args: []string{"-out", "-", "-format", "yaml"}, wantErr: `invalid format "yaml"`},
+		{name: "protobuf on stdout", args: []string{"-out", "-", "-format", "protobuf"}, wantErr: "needs an -out directory"},
+		{name: "language", args: []string{"-out", "-", "-languages", "go,cobol"}, wantErr: `invalid language "cobol"`},
+		{name: "exception rate", args: []string{"-out", "-", "-exception-rate", "1.5"}, wantErr: "invalid exception-rate 1.5"},
+		{name: "fanout", args: []string{"-out", "-", "-fanout", "0"}, wantErr: "invalid fanout 0"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(append([]string{"generate"}, tc.args...), nil, &stdout, &stderr); code != 2 {
+				t.Errorf("exit code = %d, want 2", code)
+			}
+			if !strings.Contains(stderr.String(), tc.wantErr) {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	g := newGenerator(generatorConfig{
+		services:      12,
+		languages:     []string{"java", "dotnet"},
+		depth:         1,
+		fanout:        3,
+		exceptionRate: 1,
+		eventRate:     2,
+		cardinality:   3,
+		seed:          42,
+		start:         generatedStart,
+	})
+	td := g.batch(20)
+
+	users := make(map[string]bool)
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		service, _ := rs.Resource().Attributes().Get("service.name")
+		lang, _ := rs.Resource().Attributes().Get("telemetry.sdk.language")
+		spans := rs.ScopeSpans().At(0).Spans()
+		for j := 0; j < spans.Len(); j++ {
+			span := spans.At(j)
+			user, _ := span.Attributes().Get("user.id")
+			users[user.Str()] = true
+			if span.StartTimestamp().AsTime().Before(generatedStart) || span.EndTimestamp() <= span.StartTimestamp() {
+				t.Errorf("span %s has invalid timestamps", span.Name())
+			}
+			// Every span records an exception and exactly two other events
+			if span.Events().Len() != 3 {
+				t.Errorf("span has %d events, want 3", span.Events().Len())
+				continue
+			}
+			exception := span.Events().At(2)
+			stacktrace, _ := exception.Attributes().Get("exception.stacktrace")
+			exceptionType, _ := exception.Attributes().Get("exception.type")
+			switch {
+			case lang.Str() == "java" && !strings.Contains(stacktrace.Str(), "\tat com.example."):
+				t.Errorf("%s has no Java stack trace: %q", service.Str(), stacktrace.Str())
+			case lang.Str() == "dotnet" && !strings.HasPrefix(exceptionType.Str(), "System."):
+				t.Errorf("%s has no .NET exception: %q", service.Str(), exceptionType.Str())
+			}
+			if ts := exception.Timestamp(); ts < span.StartTimestamp() || ts > span.EndTimestamp() {
+				t.Errorf("exception at %v is outside of its span", ts.AsTime().Format(time.RFC3339Nano))
+			}
+		}
+	}
+	if td.ResourceSpans().Len() > 12 || len(users) > 3 {
+		t.Errorf("got %d resources and %d users, want at most 12 and 3", td.ResourceSpans().Len(), len(users))
+	}
+	if got, want := td.SpanCount(), 20*4; got != want {
+		t.Errorf("SpanCount() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// traceInput is one input file, or stdin when path is "-"
+type traceInput struct {
+	path string
+}
+
+// expandInputs turns the command line arguments into input files. Directories are walked
+// for .json and .pb files in lexical order; no arguments means stdin.
+func expandInputs(args []string) ([]traceInput, error) {
+	if len(args) == 0 {
+		return []traceInput{{path: "-"}}, nil
+	}
+	var inputs []traceInput
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if arg == "-" || (err == nil && !info.IsDir()) {
+			inputs = append(inputs, traceInput{path: arg})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isTraceFile(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(files)
+		for _, file := range files {
+			inputs = append(inputs, traceInput{path: file})
+		}
+	}
+	return inputs, nil
+}
+
+// isTraceFile reports whether a file found in a directory is read as traces
+func isTraceFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".json", ".pb", ".binpb":
+		return true
+	}
+	return false
+}
+
+// read returns the trace batches of the input
+func (in traceInput) read(stdin io.Reader) ([]ptrace.Traces, error) {
+	var content []byte
+	var err error
+	if in.path == "-" {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(in.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	batches, err := harness.DecodeTraces(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", in.name(), err)
+	}
+	return batches, nil
+}
+
+func (in traceInput) name() string {
+	if in.path == "-" {
+		return "stdin"
+	}
+	return in.path
+}
@@ -0,0 +1,103 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Command spanevents2log runs the spaneventstolog connector outside the collector.
+// It converts trace files with a connector configuration and writes the produced logs,
+// which makes it quick to try conversion rules against recorded traffic.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is one subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+var commands = []command{
+	{name: "convert", summary: "convert trace files into logs", run: runConvert},
+	{name: "analyze", summary: "describe the span events of a trace corpus and suggest a configuration", run: runAnalyze},
+	{name: "playground", summary: "try conditions and body templates interactively against trace files", run: runPlayground},
+	{name: "validate", summary: "report what each condition matches in sample traces", run: runValidate},
+	{name: "backfill", summary: "convert a partitioned trace archive into a mirrored tree of logs", run: runBackfill},
+	{name: "generate", summary: "generate synthetic traces with span events and measure the connector on them", run: runGenerate},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// exitError is an error with a specific exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// run executes the subcommand named by args[0] and returns the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(args[1:], stdin, stdout, stderr)
+		var exitErr *exitError
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp):
+			return 2
+		case errors.As(err, &exitErr):
+			if exitErr.err != nil {
+				fmt.Fprintf(stderr, "spanevents2log %s: %v\n", cmd.name, exitErr.err)
+			}
+			return exitErr.code
+		default:
+			fmt.Fprintf(stderr, "spanevents2log %s: %v\n", cmd.name, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(stderr, "spanevents2log: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: spanevents2log <command> [flags] [files...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'spanevents2log <command> -h' for the flags of a command.")
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors instead of exiting
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: spanevents2log %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
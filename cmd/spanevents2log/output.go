@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// Output formats of the produced logs
+const (
+	formatOTLP   = "otlp"
+	formatNDJSON = "ndjson"
+	formatPretty = "pretty"
+)
+
+var outputFormats = []string{formatOTLP, formatNDJSON, formatPretty}
+
+// logsWriter writes produced logs to the output. pipeline is the logs pipeline the logs
+// were routed to; it is empty unless the configuration has routes.
+type logsWriter interface {
+	write(logs plog.Logs, pipeline string) error
+	flush() error
+}
+
+func newLogsWriter(format string, w io.Writer) (logsWriter, error) {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case formatOTLP:
+		return &otlpWriter{w: bw}, nil
+	case formatNDJSON:
+		encoder := json.NewEncoder(bw)
+		encoder.SetEscapeHTML(false)
+		return &ndjsonWriter{w: bw, encoder: encoder}, nil
+	case formatPretty:
+		return &prettyWriter{w: bw}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q, must be one of %v", format, outputFormats)
+}
+
+// otlpWriter writes one OTLP/JSON logs document per line and batch
+type otlpWriter struct {
+	w *bufio.Writer
+}
+
+func (o *otlpWriter) write(logs plog.Logs, _ string) error {
+	content, err := (&plog.JSONMarshaler{}).MarshalLogs(logs)
+	if err != nil {
+		return err
+	}
+	o.w.Write(content)
+	return o.w.WriteByte('\n')
+}
+
+func (o *otlpWriter) flush() error {
+	return o.w.Flush()
+}
+
+// ndjsonWriter writes one JSON object per log record
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) write(logs plog.Logs, pipeline string) error {
+	for _, record := range harness.Records(logs, pipeline) {
+		if err := n.encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) flush() error {
+	return n.w.Flush()
+}
+
+// prettyWriter writes every log record as a block of indented lines for reading in a terminal
+type prettyWriter struct {
+	w *bufio.Writer
+}
+
+func (p *prettyWriter) write(logs plog.Logs, pipeline string) error {
+	rangeLogRecords(logs, func(resource pcommon.Resource, _ pcommon.InstrumentationScope, lr plog.LogRecord) {
+		service := "unknown"
+		if v, ok := resource.Attributes().Get("service.name"); ok {
+			service = v.AsString()
+		}
+		fmt.Fprintf(p.w, "%s %s [%s]", lr.Timestamp().AsTime().Format(time.RFC3339Nano), severity(lr), service)
+		if pipeline != "" {
+			fmt.Fprintf(p.w, " -> %s", pipeline)
+		}
+		fmt.Fprintf(p.w, "\n  %s\n", strings.ReplaceAll(lr.Body().AsString(), "\n", "\n  "))
+		if !lr.TraceID().IsEmpty() {
+			fmt.Fprintf(p.w, "  trace_id=%s span_id=%s\n", lr.TraceID(), lr.SpanID())
+		}
+		for _, key := range sortedKeys(lr.Attributes()) {
+			v, _ := lr.Attributes().Get(key)
+			fmt.Fprintf(p.w, "  %s=%s\n", key, truncate(v.AsString(), 200))
+		}
+		p.w.WriteByte('\n')
+	})
+	return nil
+}
+
+func (p *prettyWriter) flush() error {
+	return p.w.Flush()
+}
+
+func severity(lr plog.LogRecord) string {
+	if lr.SeverityText() != "" {
+		return strings.ToUpper(lr.SeverityText())
+	}
+	return lr.SeverityNumber().String()
+}
+
+func sortedKeys(attrs pcommon.Map) []string {
+	keys := make([]string, 0, attrs.Len())
+	attrs.Range(func(k string, _ pcommon.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	slices.Sort(keys)
+	return keys
+}
+
+// truncate shortens s to at most n bytes and keeps it on one line
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func rangeLogRecords(logs plog.Logs, fn func(pcommon.Resource, pcommon.InstrumentationScope, plog.LogRecord)) {
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		rl := logs.ResourceLogs().At(i)
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				fn(rl.Resource(), sl.Scope(), sl.LogRecords().At(k))
+			}
+		}
+	}
+}
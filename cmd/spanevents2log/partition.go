@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partitionKeys are the hive-style directory keys that place a file in time, from the
+// coarsest to the finest
+var partitionKeys = []string{"year", "month", "day", "hour", "minute"}
+
+// partition is the hive-style location of a file, such as month=05/day=23/hour=13/minute=01
+type partition struct {
+	// dir is the partition directory relative to the archive root; it is "." for files
+	// outside any partition
+	dir string
+	// values holds the value of each partitionKeys entry; -1 when the path does not set it
+	values [5]int
+}
+
+// parsePartition reads the partition of the directory dir, relative to the archive root.
+// Segments that are not key=value, or whose key is unknown, are kept in dir but do not
+// place the file in time.
+func parsePartition(dir string) (partition, error) {
+	p := partition{dir: filepath.ToSlash(dir)}
+	for i := range p.values {
+		p.values[i] = -1
+	}
+	if dir == "." {
+		return p, nil
+	}
+	for _, segment := range strings.Split(p.dir, "/") {
+		key, value, ok := strings.Cut(segment, "=")
+		if !ok {
+			continue
+		}
+		for i, k := range partitionKeys {
+			if k != key {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return p, fmt.Errorf("invalid partition %s: %q is not a number", p.dir, value)
+			}
+			p.values[i] = n
+		}
+	}
+	return p, nil
+}
+
+// timed reports whether the partition sets at least one time component
+func (p partition) timed() bool {
+	for _, v := range p.values {
+		if v >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// start returns the beginning of the partition in UTC. Unset components default to their
+// minimum; an unset year is taken from year.
+func (p partition) start(year int) time.Time {
+	v := p.values
+	if v[0] >= 0 {
+		year = v[0]
+	}
+	orDefault := func(n, def int) int {
+		if n < 0 {
+			return def
+		}
+		return n
+	}
+	return time.Date(year, time.Month(orDefault(v[1], 1)), orDefault(v[2], 1), orDefault(v[3], 0), orDefault(v[4], 0), 0, 0, time.UTC)
+}
+
+// end returns the end of the partition in UTC, exclusive: its start plus one unit of the
+// finest component it sets
+func (p partition) end(year int) time.Time {
+	start := p.start(year)
+	switch {
+	case p.values[4] >= 0:
+		return start.Add(time.Minute)
+	case p.values[3] >= 0:
+		return start.Add(time.Hour)
+	case p.values[2] >= 0:
+		return start.AddDate(0, 0, 1)
+	case p.values[1] >= 0:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(1, 0, 0)
+	}
+}
+
+// timeRange selects the partitions that overlap it. A zero bound is open.
+type timeRange struct {
+	from, to time.Time
+	// year places partitions without a year key in time
+	year int
+}
+
+func (r timeRange) open() bool {
+	return r.from.IsZero() && r.to.IsZero()
+}
+
+// contains reports whether the partition, [start, end), overlaps [from, to), so an
+// hour=13 partition is selected by -from 13:30. Files outside any timed partition are only
+// selected when the range is open.
+func (r timeRange) contains(p partition) bool {
+	if r.open() {
+		return true
+	}
+	if !p.timed() {
+		return false
+	}
+	return (r.from.IsZero() || p.end(r.year).After(r.from)) && (r.to.IsZero() || p.start(r.year).Before(r.to))
+}
+
+// parseTimeFlag reads a -from or -to value in RFC 3339 or a shorter form such as
+// 2025-05-23T13:02 or 2025-05-23, in UTC
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02T15", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 such as 2025-05-23T13:02:00Z or 2025-05-23T13:02", value)
+}
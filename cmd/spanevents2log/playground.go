@@ -0,0 +1,389 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// The condition lists typed conditions are parsed as: span_conditions, event_conditions
+// and conditions, whose paths name their OTTL context
+const (
+	contextSpan       = "span"
+	contextSpanEvent  = "spanevent"
+	contextConditions = "conditions"
+)
+
+// conditionLists are the playground contexts in the order :config writes them
+var conditionLists = []struct {
+	context, field string
+}{
+	{contextSpan, "span_conditions"},
+	{contextSpanEvent, "event_conditions"},
+	{contextConditions, "conditions"},
+}
+
+// conditionField returns the configuration field of the condition list of contextName
+func conditionField(contextName string) string {
+	for _, list := range conditionLists {
+		if list.context == contextName {
+			return list.field
+		}
+	}
+	return ""
+}
+
+const playgroundHelp = `Type a condition to see what it matches in the current context, or a command:
+  :span, :spanevent      parse conditions as span_conditions or event_conditions
+  :conditions            parse conditions as conditions, such as span.kind == SPAN_KIND_CLIENT
+  :match any|all         set whether any or all kept conditions of the current list must match
+  :template <template>   render a log body template for the current condition's matches
+  :keep                  add the current condition to the configuration
+  :config                show the configuration
+  :save <file>           write the configuration as connector settings
+  :load <paths...>       load more trace files or directories
+  :limit <n>             set the number of matches and log records shown
+  :quit                  leave the playground
+`
+
+// runPlayground reads commands from stdin, one per line
+func runPlayground(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("playground", "files or directories...", stderr)
+	limit := flags.Int("limit", 10, "number of matches and log records shown")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return &exitError{code: 2, err: errors.New("trace files are required, stdin is read for commands")}
+	}
+
+	p := &playground{
+		context: contextSpanEvent,
+		limit:   *limit,
+		current: make(map[string]string),
+		kept:    make(map[string][]string),
+		match:   make(map[string]spaneventstologconnector.MatchMode),
+		out:     stdout,
+	}
+	if err := p.load(flags.Args()); err != nil {
+		return err
+	}
+	return p.run(context.Background(), stdin)
+}
+
+// playground evaluates conditions and templates typed one per line against loaded traces
+type playground struct {
+	traces []ptrace.Traces
+	// context is the OTTL context of typed conditions
+	context string
+	limit   int
+	// current is the last condition that parsed, per context
+	current map[string]string
+	// kept, match and template are the configuration :save writes; kept and match are
+	// per context
+	kept     map[string][]string
+	match    map[string]spaneventstologconnector.MatchMode
+	template string
+	out      io.Writer
+}
+
+func (p *playground) load(paths []string) error {
+	inputs, err := expandInputs(paths)
+	if err != nil {
+		return err
+	}
+	spans, events := 0, 0
+	for _, in := range inputs {
+		if in.path == "-" {
+			return errors.New("traces cannot be read from stdin, it is read for commands")
+		}
+		batches, err := in.read(nil)
+		if err != nil {
+			return err
+		}
+		for _, td := range batches {
+			spans += td.SpanCount()
+			events += eventCount(td)
+		}
+		p.traces = append(p.traces, batches...)
+	}
+	fmt.Fprintf(p.out, "Loaded %d spans with %d span events from %d inputs\n", spans, events, len(inputs))
+	return nil
+}
+
+func (p *playground) run(ctx context.Context, input io.Reader) error {
+	fmt.Fprintln(p.out, "Type a condition, or :help for commands.")
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		fmt.Fprintf(p.out, "%s> ", p.context)
+		if !scanner.Scan() {
+			fmt.Fprintln(p.out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			p.evaluate(ctx, line)
+			continue
+		}
+
+		name, arg, _ := strings.Cut(line[1:], " ")
+		arg = strings.TrimSpace(arg)
+		var err error
+		switch name {
+		case "quit", "exit", "q":
+			return nil
+		case "help":
+			_, err = io.WriteString(p.out, playgroundHelp)
+		case contextSpan, contextSpanEvent, contextConditions:
+			p.context = name
+		case "match":
+			err = p.setMatch(arg)
+		case "template":
+			err = p.renderTemplate(ctx, arg)
+		case "keep":
+			err = p.keep()
+		case "config":
+			_, err = io.WriteString(p.out, p.config())
+		case "save":
+			err = p.save(arg)
+		case "load":
+			err = p.load(strings.Fields(arg))
+		case "limit":
+			err = p.setLimit(arg)
+		default:
+			err = fmt.Errorf("unknown command :%s, type :help for the commands", name)
+		}
+		if err != nil {
+			fmt.Fprintf(p.out, "error: %v\n", err)
+		}
+	}
+}
+
+// baseConfig returns the default connector settings without conditions
+func baseConfig() *spaneventstologconnector.Config {
+	cfg := spaneventstologconnector.NewFactory().CreateDefaultConfig().(*spaneventstologconnector.Config)
+	return isolatedConfig(cfg)
+}
+
+// setCondition sets condition as the only condition of cfg in the given context
+func setCondition(cfg *spaneventstologconnector.Config, contextName, condition string) {
+	switch contextName {
+	case contextSpan:
+		cfg.SpanConditions = []string{condition}
+	case contextConditions:
+		cfg.Conditions = []string{condition}
+	default:
+		cfg.EventConditions = []string{condition}
+	}
+}
+
+// evaluate parses condition in the current context and lists what it matches. A Converter
+// selects the matches, so they are exactly what the connector would convert.
+func (p *playground) evaluate(ctx context.Context, condition string) {
+	cfg := baseConfig()
+	setCondition(cfg, p.context, condition)
+	// Failed evaluations do not match and are counted from the converter's warnings
+	cfg.ErrorMode = ottl.IgnoreError
+	core, failures := observer.New(zap.WarnLevel)
+	converter, err := spaneventstologconnector.NewConverter(cfg, spaneventstologconnector.WithLogger(zap.New(core)))
+	if err != nil {
+		fmt.Fprintf(p.out, "error: %v\n", err)
+		return
+	}
+	p.current[p.context] = condition
+
+	evaluated, matched := 0, 0
+	tw := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	row := func(format string, args ...any) {
+		if matched++; matched <= p.limit {
+			fmt.Fprintf(tw, format, args...)
+		}
+	}
+	onSpan := func(rs ptrace.ResourceSpans, span ptrace.Span) {
+		row("  %s\t%s\t%s\t%s\t%d events\n", serviceName(rs), span.Name(), span.Kind(), span.Status().Code(), span.Events().Len())
+	}
+	onEvent := func(rs ptrace.ResourceSpans, span ptrace.Span, event ptrace.SpanEvent) {
+		row("  %s\t%s\t%s\t%s\n", serviceName(rs), span.Name(), event.Name(), formatAttributes(event))
+	}
+	if p.context == contextSpan {
+		onEvent = nil
+	} else {
+		onSpan = nil
+	}
+	for _, td := range p.traces {
+		if p.context == contextSpan {
+			evaluated += td.SpanCount()
+		} else {
+			evaluated += eventCount(td)
+		}
+		if err := converter.Match(ctx, td, onSpan, onEvent); err != nil {
+			fmt.Fprintf(p.out, "error: %v\n", err)
+			return
+		}
+	}
+	tw.Flush()
+
+	noun := "span events"
+	if p.context == contextSpan {
+		noun = "spans"
+	}
+	fmt.Fprintf(p.out, "%d of %d %s match", matched, evaluated, noun)
+	if matched > p.limit {
+		fmt.Fprintf(p.out, ", the first %d are shown", p.limit)
+	}
+	fmt.Fprintln(p.out)
+	if failed := failures.FilterMessage("failed to eval condition").All(); len(failed) > 0 {
+		fmt.Fprintf(p.out, "%d evaluations failed, the first with: %v\n", len(failed), failed[0].ContextMap()["error"])
+	}
+}
+
+// serviceName returns the service.name of the resource of rs, or unknown
+func serviceName(rs ptrace.ResourceSpans) string {
+	if v, ok := rs.Resource().Attributes().Get("service.name"); ok {
+		return v.AsString()
+	}
+	return "unknown"
+}
+
+// formatAttributes renders the attributes of event as a short key=value list
+func formatAttributes(event ptrace.SpanEvent) string {
+	attrs := event.Attributes()
+	parts := make([]string, 0, attrs.Len())
+	for _, k := range sortedKeys(attrs) {
+		v, _ := attrs.Get(k)
+		parts = append(parts, k+"="+strconv.Quote(truncate(v.AsString(), 30)))
+	}
+	return truncate(strings.Join(parts, " "), 120)
+}
+
+// renderTemplate converts the span events the current condition matches with the connector,
+// using tmpl as log_body_template, and shows the rendered bodies
+func (p *playground) renderTemplate(ctx context.Context, tmpl string) error {
+	if tmpl == "" {
+		return errors.New("usage: :template <template>, such as :template {{.SpanName}}: {{.EventName}}")
+	}
+	cfg := baseConfig()
+	if condition, ok := p.current[p.context]; ok {
+		setCondition(cfg, p.context, condition)
+	}
+	cfg.LogBodyTemplate = tmpl
+	result, err := probe(ctx, cfg, p.traces, p.limit)
+	if err != nil {
+		return err
+	}
+	p.template = tmpl
+
+	for _, e := range result.examples {
+		fmt.Fprintf(p.out, "  %s / %s: %s\n", e.SpanName, e.EventName, truncate(e.Body, 200))
+	}
+	fmt.Fprintf(p.out, "%d log records", result.records)
+	if result.records > len(result.examples) {
+		fmt.Fprintf(p.out, ", the first %d are shown", len(result.examples))
+	}
+	fmt.Fprintln(p.out)
+	for _, e := range result.errors {
+		fmt.Fprintf(p.out, "%dx %s\n", e.Count, truncate(e.Message, 200))
+	}
+	return nil
+}
+
+// keep adds the current condition of the current context to the configuration
+func (p *playground) keep() error {
+	condition, ok := p.current[p.context]
+	if !ok {
+		return fmt.Errorf("no condition has been evaluated in the %s context yet", p.context)
+	}
+	field := conditionField(p.context)
+	if i := slices.Index(p.kept[p.context], condition); i >= 0 {
+		fmt.Fprintf(p.out, "already kept as %s[%d]\n", field, i)
+		return nil
+	}
+	p.kept[p.context] = append(p.kept[p.context], condition)
+	fmt.Fprintf(p.out, "kept as %s[%d]\n", field, len(p.kept[p.context])-1)
+	return nil
+}
+
+// setMatch sets how the kept conditions of the current context are combined
+func (p *playground) setMatch(arg string) error {
+	mode := spaneventstologconnector.MatchMode(arg)
+	if mode != spaneventstologconnector.MatchAny && mode != spaneventstologconnector.MatchAll {
+		return fmt.Errorf("usage: :match any|all")
+	}
+	p.match[p.context] = mode
+	fmt.Fprintf(p.out, "%s_match: %s\n", conditionField(p.context), mode)
+	return nil
+}
+
+// config renders the kept conditions, their match modes and the last rendered template as
+// connector settings
+func (p *playground) config() string {
+	var b strings.Builder
+	for _, list := range conditionLists {
+		conditions := p.kept[list.context]
+		if len(conditions) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:\n", list.field)
+		for _, condition := range conditions {
+			fmt.Fprintf(&b, "  - %s\n", yamlQuote(condition))
+		}
+		if mode, ok := p.match[list.context]; ok {
+			fmt.Fprintf(&b, "%s_match: %s\n", list.field, mode)
+		}
+	}
+	if p.template != "" {
+		fmt.Fprintf(&b, "log_body_template: %s\n", yamlQuote(p.template))
+	}
+	if b.Len() == 0 {
+		return "# nothing kept yet, use :keep and :template\n"
+	}
+	return b.String()
+}
+
+// save writes the configuration to path and loads it back, so it is known to be valid
+func (p *playground) save(path string) error {
+	if path == "" {
+		return errors.New("usage: :save <file>")
+	}
+	if len(p.kept[contextSpan]) == 0 && len(p.kept[contextSpanEvent]) == 0 && len(p.kept[contextConditions]) == 0 {
+		return errors.New("no conditions kept, use :keep first")
+	}
+	if err := os.WriteFile(path, []byte(p.config()), 0o644); err != nil {
+		return err
+	}
+	if _, err := loadConfig(path, ""); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "saved to %s\n", path)
+	return nil
+}
+
+func (p *playground) setLimit(arg string) error {
+	limit, err := strconv.Atoi(arg)
+	if err != nil || limit < 1 {
+		return fmt.Errorf("invalid limit %q, must be a positive number", arg)
+	}
+	p.limit = limit
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunPlayground(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "rules.yaml")
+	session := strings.Join([]string{
+		`name == "exception"`,
+		`:keep`,
+		`:template {{.SpanName}}: {{index .EventAttributes "exception.type"}}`,
+		`:span`,
+		`kind == BOGUS`,
+		`kind == SPAN_KIND_CLIENT and Len(events) > 0`,
+		`:keep`,
+		`:keep`,
+		`:template {{index .SpanName 100}}`,
+		`:unknown`,
+		`:save ` + configPath,
+		`:quit`,
+		`name == "never evaluated"`,
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"playground", "-limit", "2", exceptionTraces}, strings.NewReader(session), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"Loaded 67 spans with 3 span events from 1 inputs",
+		"loadgenerator  GET  exception  exception.escaped=\"False\"",
+		"3 of 3 span events match, the first 2 are shown",
+		"kept as event_conditions[0]",
+		"GET / exception: GET: requests.exceptions.ConnectionError",
+		"3 log records, the first 2 are shown",
+		`error: invalid span_condition OTTL: "kind == BOGUS"`,
+		"loadgenerator  GET  Client  Error  1 events",
+		"3 of 67 spans match",
+		"kept as span_conditions[0]",
+		"already kept as span_conditions[0]",
+		"error: failed to parse log body templates",
+		"error: unknown command :unknown",
+		"saved to " + configPath,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "never evaluated") {
+		t.Error("input after :quit was evaluated")
+	}
+
+	cfg, err := loadConfig(configPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.SpanConditions, []string{"kind == SPAN_KIND_CLIENT and Len(events) > 0"}) || !slices.Equal(cfg.EventConditions, []string{`name == "exception"`}) {
+		t.Errorf("saved conditions = %q and %q", cfg.SpanConditions, cfg.EventConditions)
+	}
+	// The failing template was not kept
+	if cfg.LogBodyTemplate != `{{.SpanName}}: {{index .EventAttributes "exception.type"}}` {
+		t.Errorf("log_body_template = %q", cfg.LogBodyTemplate)
+	}
+}
+
+func TestRunPlayground_Conditions(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "rules.yaml")
+	session := strings.Join([]string{
+		`:conditions`,
+		`kind == SPAN_KIND_CLIENT`,
+		`span.kind == SPAN_KIND_CLIENT`,
+		`:keep`,
+		`spanevent.attributes["exception.escaped"] == "False"`,
+		`:keep`,
+		`:match all`,
+		`:config`,
+		`:save ` + configPath,
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"playground", exceptionTraces}, strings.NewReader(session), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		`error: invalid condition OTTL: "kind == SPAN_KIND_CLIENT"`,
+		"3 of 3 span events match",
+		"kept as conditions[0]",
+		"kept as conditions[1]",
+		"conditions_match: all",
+		"saved to " + configPath,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+
+	cfg, err := loadConfig(configPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`span.kind == SPAN_KIND_CLIENT`, `spanevent.attributes["exception.escaped"] == "False"`}
+	if !slices.Equal(cfg.Conditions, want) || cfg.ConditionsMatch != "all" {
+		t.Errorf("saved conditions = %q with conditions_match %q, want %q with all", cfg.Conditions, cfg.ConditionsMatch, want)
+	}
+}
+
+func TestRunPlayground_RuntimeErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	session := `Substring(name, 100, 5) == "x"`
+	if code := run([]string{"playground", exceptionTraces}, strings.NewReader(session), &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "3 evaluations failed, the first with:") {
+		t.Errorf("output does not report the failed evaluations:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// generatorConfig shapes the synthetic traces
+type generatorConfig struct {
+	services  int
+	languages []string
+	// depth is the number of span levels below the root span, and fanout the number of
+	// child spans of every span above the last level
+	depth  int
+	fanout int
+	// exceptionRate is the probability that a span records an exception, and eventRate
+	// the mean number of other span events per span
+	exceptionRate float64
+	eventRate     float64
+	// cardinality is the number of distinct values of the high cardinality attributes
+	cardinality int
+	seed        uint64
+	start       time.Time
+}
+
+// validate checks the settings that the flags cannot restrict
+func (c generatorConfig) validate() error {
+	switch {
+	case c.services < 1:
+		return fmt.Errorf("invalid services %d, must be positive", c.services)
+	case c.depth < 0:
+		return fmt.Errorf("invalid depth %d, must not be negative", c.depth)
+	case c.fanout < 1:
+		return fmt.Errorf("invalid fanout %d, must be positive", c.fanout)
+	case c.exceptionRate < 0 || c.exceptionRate > 1:
+		return fmt.Errorf("invalid exception-rate %v, must be between 0 and 1", c.exceptionRate)
+	case c.eventRate < 0:
+		return fmt.Errorf("invalid event-rate %v, must not be negative", c.eventRate)
+	case c.cardinality < 1:
+		return fmt.Errorf("invalid cardinality %d, must be positive", c.cardinality)
+	case len(c.languages) == 0:
+		return fmt.Errorf("invalid languages, must list at least one of %v", languageNames())
+	}
+	for _, lang := range c.languages {
+		if _, ok := languages[lang]; !ok {
+			return fmt.Errorf("invalid language %q, must be one of %v", lang, languageNames())
+		}
+	}
+	return nil
+}
+
+// language describes the telemetry of services written in one language
+type language struct {
+	sdk        string
+	exceptions []exceptionKind
+}
+
+// exceptionKind is an exception a service can raise, with a stack trace in the format of its
+// language. The stack trace is a format string taking the service name and the message.
+type exceptionKind struct {
+	typ        string
+	message    string
+	stacktrace string
+}
+
+var languages = map[string]language{
+	"java": {sdk: "opentelemetry-java-instrumentation", exceptions: []exceptionKind{
+		{
+			typ:     "java.net.ConnectException",
+			message: "Connection refused",
+			stacktrace: "java.net.ConnectException: %[2]s\n" +
+				"\tat java.base/sun.nio.ch.Net.pollConnect(Native Method)\n" +
+				"\tat java.base/sun.nio.ch.NioSocketImpl.timedFinishConnect(NioSocketImpl.java:547)\n" +
+				"\tat okhttp3.internal.connection.RealConnection.connectSocket(RealConnection.kt:285)\n" +
+				"\tat com.example.%[1]s.client.InventoryClient.reserve(InventoryClient.java:88)\n" +
+				"\tat com.example.%[1]s.service.OrderService.place(OrderService.java:142)\n",
+		},
+		{
+			typ:     "org.springframework.dao.DataAccessResourceFailureException",
+			message: "Unable to acquire JDBC Connection",
+			stacktrace: "org.springframework.dao.DataAccessResourceFailureException: %[2]s\n" +
+				"\tat org.springframework.orm.jpa.vendor.HibernateJpaDialect.convertHibernateAccessException(HibernateJpaDialect.java:277)\n" +
+				"\tat com.example.%[1]s.repository.OrderRepository.save(OrderRepository.java:54)\n" +
+				"Caused by: java.sql.SQLTransientConnectionException: HikariPool-1 - Connection is not available, request timed out after 30000ms.\n" +
+				"\tat com.zaxxer.hikari.pool.HikariPool.createTimeoutException(HikariPool.java:696)\n" +
+				"\t... 42 more\n",
+		},
+	}},
+	"python": {sdk: "opentelemetry-python", exceptions: []exceptionKind{
+		{
+			typ:     "requests.exceptions.ConnectionError",
+			message: "HTTPConnectionPool(host='inventory', port=8080): Max retries exceeded",
+			stacktrace: "Traceback (most recent call last):\n" +
+				"  File \"/usr/local/lib/python3.12/site-packages/urllib3/connection.py\", line 198, in _new_conn\n" +
+				"    sock = connection.create_connection(\n" +
+				"ConnectionRefusedError: [Errno 111] Connection refused\n\n" +
+				"The above exception was the direct cause of the following exception:\n\n" +
+				"Traceback (most recent call last):\n" +
+				"  File \"/app/%[1]s/clients.py\", line 61, in fetch_inventory\n" +
+				"    response = session.get(url, timeout=2)\n" +
+				"requests.exceptions.ConnectionError: %[2]s\n",
+		},
+		{
+			typ:     "KeyError",
+			message: "'currency_code'",
+			stacktrace: "Traceback (most recent call last):\n" +
+				"  File \"/app/%[1]s/handlers.py\", line 112, in convert\n" +
+				"    rate = rates[request['currency_code']]\n" +
+				"KeyError: %[2]s\n",
+		},
+	}},
+	"go": {sdk: "opentelemetry-go", exceptions: []exceptionKind{
+		{
+			typ:     "*net.OpError",
+			message: "dial tcp 10.0.12.7:5432: connect: connection refused",
+			stacktrace: "goroutine 87 [running]:\n" +
+				"runtime/debug.Stack()\n" +
+				"\t/usr/local/go/src/runtime/debug/stack.go:26 +0x5e\n" +
+				"github.com/example/%[1]s/internal/store.(*Store).Get(0xc0001a4000, {0x1a2b3c0, 0xc000312000})\n" +
+				"\t/src/internal/store/store.go:74 +0x1f5\n" +
+				"github.com/example/%[1]s/internal/api.(*Handler).ServeHTTP(0xc00011e0a0, {0x1a2f1e8, 0xc0002a61c0}, 0xc000262000)\n" +
+				"\t/src/internal/api/handler.go:39 +0x8d\n",
+		},
+		{
+			typ:     "context.deadlineExceededError",
+			message: "context deadline exceeded",
+			stacktrace: "goroutine 112 [running]:\n" +
+				"github.com/example/%[1]s/internal/client.(*Client).Quote(0xc000228000, {0x1a2b3c0, 0xc0004be0f0})\n" +
+				"\t/src/internal/client/client.go:118 +0x2c4\n" +
+				"main.main.func1()\n" +
+				"\t/src/main.go:52 +0x7a\n",
+		},
+	}},
+	"dotnet": {sdk: "opentelemetry-dotnet", exceptions: []exceptionKind{
+		{
+			typ:     "System.Net.Http.HttpRequestException",
+			message: "Connection refused (inventory:8080)",
+			stacktrace: "System.Net.Http.HttpRequestException: %[2]s ---> System.Net.Sockets.SocketException (111): Connection refused\n" +
+				"   at System.Net.Sockets.Socket.AwaitableSocketAsyncEventArgs.ThrowException(SocketError error, CancellationToken cancellationToken)\n" +
+				"   --- End of inner exception stack trace ---\n" +
+				"   at System.Net.Http.HttpConnectionPool.ConnectToTcpHostAsync(String host, Int32 port, HttpRequestMessage initialRequest, Boolean async, CancellationToken cancellationToken)\n" +
+				"   at Example.%[1]s.Clients.InventoryClient.ReserveAsync(Order order) in /src/Clients/InventoryClient.cs:line 57\n",
+		},
+		{
+			typ:     "System.InvalidOperationException",
+			message: "Sequence contains no elements",
+			stacktrace: "System.InvalidOperationException: %[2]s\n" +
+				"   at System.Linq.ThrowHelper.ThrowNoElementsException()\n" +
+				"   at Example.%[1]s.Services.CartService.GetCartAsync(String userId) in /src/Services/CartService.cs:line 33\n",
+		},
+	}},
+	"nodejs": {sdk: "opentelemetry-js", exceptions: []exceptionKind{
+		{
+			typ:     "Error",
+			message: "connect ECONNREFUSED 10.0.3.14:6379",
+			stacktrace: "Error: %[2]s\n" +
+				"    at TCPConnectWrap.afterConnect [as oncomplete] (node:net:1607:16)\n" +
+				"    at RedisClient.get (/app/%[1]s/node_modules/redis/dist/lib/client/index.js:451:18)\n" +
+				"    at async getRecommendations (/app/%[1]s/src/recommendations.js:27:19)\n",
+		},
+		{
+			typ:     "TypeError",
+			message: "Cannot read properties of undefined (reading 'price')",
+			stacktrace: "TypeError: %[2]s\n" +
+				"    at computeTotal (/app/%[1]s/src/cart.js:88:31)\n" +
+				"    at Array.reduce (<anonymous>)\n" +
+				"    at /app/%[1]s/src/routes/cart.js:42:26\n",
+		},
+	}},
+}
+
+func languageNames() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+var serviceNames = []string{"frontend", "cart", "checkout", "payment", "shipping", "currency", "inventory", "recommendation", "email", "ad"}
+
+var routes = []string{"/api/cart", "/api/checkout", "/api/products/{id}", "/api/recommendations", "/api/currency/convert", "/api/shipping/quote"}
+
+// plainEvents are the span events other than exceptions
+var plainEvents = []string{"cache.miss", "retry", "db.query", "message.sent", "feature_flag.evaluation"}
+
+// service is one synthetic service
+type service struct {
+	name     string
+	language string
+	version  string
+}
+
+// generator builds synthetic traces. The traces depend only on the configuration, so a
+// seed reproduces them exactly.
+type generator struct {
+	cfg      generatorConfig
+	rnd      *rand.Rand
+	services []service
+	traces   uint64
+	spans    uint64
+}
+
+func newGenerator(cfg generatorConfig) *generator {
+	g := &generator{cfg: cfg, rnd: rand.New(rand.NewPCG(cfg.seed, cfg.seed^0x9e3779b97f4a7c15))}
+	for i := 0; i < cfg.services; i++ {
+		name := serviceNames[i%len(serviceNames)]
+		if i >= len(serviceNames) {
+			name = fmt.Sprintf("%s-%d", name, i/len(serviceNames)+1)
+		}
+		g.services = append(g.services, service{
+			name:     name,
+			language: cfg.languages[i%len(cfg.languages)],
+			version:  fmt.Sprintf("1.%d.%d", i%4, i%7),
+		})
+	}
+	return g
+}
+
+// batch returns n traces. The spans of every service are grouped in one ResourceSpans.
+func (g *generator) batch(n int) ptrace.Traces {
+	td := ptrace.NewTraces()
+	scopes := make(map[int]ptrace.ScopeSpans, len(g.services))
+	for i := 0; i < n; i++ {
+		g.traces++
+		var traceID [16]byte
+		binary.BigEndian.PutUint64(traceID[:8], g.cfg.seed)
+		binary.BigEndian.PutUint64(traceID[8:], g.traces)
+		start := g.cfg.start.Add(time.Duration(g.traces) * 10 * time.Millisecond)
+		root := g.rnd.IntN(len(g.services))
+		g.span(td, scopes, traceID, pcommon.SpanID{}, root, 0, start, 400*time.Millisecond)
+	}
+	return td
+}
+
+// span adds a span of the service with index svc and its children
+func (g *generator) span(td ptrace.Traces, scopes map[int]ptrace.ScopeSpans, traceID pcommon.TraceID, parent pcommon.SpanID, svc, level int, start time.Time, budget time.Duration) {
+	scope, ok := scopes[svc]
+	if !ok {
+		rs := td.ResourceSpans().AppendEmpty()
+		s := g.services[svc]
+		attrs := rs.Resource().Attributes()
+		attrs.PutStr("service.name", s.name)
+		attrs.PutStr("service.version", s.version)
+		attrs.PutStr("telemetry.sdk.language", s.language)
+		attrs.PutStr("telemetry.sdk.name", "opentelemetry")
+		attrs.PutStr("deployment.environment", "synthetic")
+		scope = rs.ScopeSpans().AppendEmpty()
+		scope.Scope().SetName(languages[s.language].sdk)
+		scopes[svc] = scope
+	}
+	g.spans++
+	var spanID [8]byte
+	binary.BigEndian.PutUint64(spanID[:], g.spans)
+
+	span := scope.Spans().AppendEmpty()
+	span.SetTraceID(traceID)
+	span.SetSpanID(spanID)
+	span.SetParentSpanID(parent)
+	route := routes[g.rnd.IntN(len(routes))]
+	method := "GET"
+	if g.rnd.IntN(4) == 0 {
+		method = "POST"
+	}
+	span.SetName(method + " " + route)
+	span.SetKind(ptrace.SpanKindServer)
+	duration := budget/2 + time.Duration(g.rnd.Int64N(int64(budget/2)+1))
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(duration)))
+
+	attrs := span.Attributes()
+	attrs.PutStr("http.request.method", method)
+	attrs.PutStr("http.route", route)
+	attrs.PutStr("user.id", fmt.Sprintf("user-%d", g.rnd.IntN(g.cfg.cardinality)))
+	attrs.PutStr("session.id", fmt.Sprintf("session-%d", g.rnd.IntN(g.cfg.cardinality)))
+	attrs.PutStr("app.region", []string{"us-east-1", "eu-west-1", "ap-south-1"}[g.rnd.IntN(3)])
+
+	status := int64(200)
+	events := int(g.cfg.eventRate)
+	if g.rnd.Float64() < g.cfg.eventRate-float64(events) {
+		events++
+	}
+	for i := 0; i < events; i++ {
+		g.plainEvent(span, start, duration)
+	}
+	if g.rnd.Float64() < g.cfg.exceptionRate {
+		g.exception(span, start, duration, g.services[svc])
+		status = []int64{500, 502, 503, 504}[g.rnd.IntN(4)]
+	}
+	attrs.PutInt("http.response.status_code", status)
+
+	if level >= g.cfg.depth {
+		return
+	}
+	childBudget := duration / time.Duration(g.cfg.fanout+1)
+	for i := 0; i < g.cfg.fanout; i++ {
+		childStart := start.Add(time.Duration(i) * childBudget)
+		g.span(td, scopes, traceID, spanID, g.rnd.IntN(len(g.services)), level+1, childStart, childBudget)
+	}
+}
+
+// eventTime returns a random time during the span
+func (g *generator) eventTime(start time.Time, duration time.Duration) pcommon.Timestamp {
+	return pcommon.NewTimestampFromTime(start.Add(time.Duration(g.rnd.Int64N(int64(duration) + 1))))
+}
+
+func (g *generator) plainEvent(span ptrace.Span, start time.Time, duration time.Duration) {
+	event := span.Events().AppendEmpty()
+	name := plainEvents[g.rnd.IntN(len(plainEvents))]
+	event.SetName(name)
+	event.SetTimestamp(g.eventTime(start, duration))
+	attrs := event.Attributes()
+	switch name {
+	case "cache.miss":
+		attrs.PutStr("cache.key", fmt.Sprintf("product:%d", g.rnd.IntN(g.cfg.cardinality)))
+	case "retry":
+		attrs.PutInt("retry.attempt", int64(1+g.rnd.IntN(3)))
+	case "db.query":
+		attrs.PutStr("db.system", "postgresql")
+		attrs.PutInt("db.rows", int64(g.rnd.IntN(500)))
+	case "message.sent":
+		attrs.PutStr("messaging.destination.name", "orders")
+	case "feature_flag.evaluation":
+		attrs.PutStr("feature_flag.key", fmt.Sprintf("flag-%d", g.rnd.IntN(20)))
+		attrs.PutBool("feature_flag.enabled", g.rnd.IntN(2) == 0)
+	}
+}
+
+func (g *generator) exception(span ptrace.Span, start time.Time, duration time.Duration, s service) {
+	kinds := languages[s.language].exceptions
+	kind := kinds[g.rnd.IntN(len(kinds))]
+	event := span.Events().AppendEmpty()
+	event.SetName("exception")
+	event.SetTimestamp(g.eventTime(start, duration))
+	attrs := event.Attributes()
+	attrs.PutStr("exception.type", kind.typ)
+	attrs.PutStr("exception.message", kind.message)
+	attrs.PutStr("exception.stacktrace", fmt.Sprintf(kind.stacktrace, strings.ReplaceAll(s.name, "-", ""), kind.message))
+	attrs.PutBool("exception.escaped", g.rnd.IntN(3) == 0)
+	span.Status().SetCode(ptrace.StatusCodeError)
+	span.Status().SetMessage(kind.message)
+}
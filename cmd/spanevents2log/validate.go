@@ -0,0 +1,386 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Exit codes of the validate command, besides 1 for a configuration or input that cannot
+// be loaded and 2 for usage errors
+const (
+	exitUnmatched = 3
+	exitErrors    = 4
+)
+
+// stringsFlag is a flag that may be repeated
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := newFlagSet("validate", "-against <traces> [more traces...]", stderr)
+	configPath := flags.String("config", "", "connector configuration YAML, either the connector's settings or a whole collector configuration (required)")
+	connectorName := flags.String("connector", "", "connector to use when the collector configuration has several")
+	var against stringsFlag
+	flags.Var(&against, "against", "sample trace file or directory, - for stdin; may be repeated (required)")
+	examples := flags.Int("examples", 3, "number of produced log records to show")
+	jsonOutput := flags.Bool("json", false, "write the report as JSON")
+	allowUnmatched := flags.Bool("allow-unmatched", false, "do not fail when a condition matches nothing")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	against = append(against, flags.Args()...)
+	if *configPath == "" || len(against) == 0 {
+		flags.Usage()
+		return &exitError{code: 2, err: errors.New("-config and -against are required")}
+	}
+
+	cfg, err := loadConfig(*configPath, *connectorName)
+	if err != nil {
+		return err
+	}
+	inputs, err := expandInputs(against)
+	if err != nil {
+		return err
+	}
+	var traces []ptrace.Traces
+	for _, in := range inputs {
+		batches, err := in.read(stdin)
+		if err != nil {
+			return err
+		}
+		traces = append(traces, batches...)
+	}
+
+	report, err := validateConfig(context.Background(), cfg, traces, *examples)
+	if err != nil {
+		return err
+	}
+	report.Inputs = len(inputs)
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.write(stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case report.TemplateErrors > 0 || report.OTTLErrors > 0:
+		return &exitError{code: exitErrors}
+	case !*allowUnmatched && len(report.unmatched()) > 0:
+		return &exitError{code: exitUnmatched}
+	}
+	return nil
+}
+
+// conditionReport is what one configured condition matched on its own
+type conditionReport struct {
+	Field     string `json:"field"`
+	Condition string `json:"condition"`
+	// Spans is only set for span_conditions
+	Spans      *int64 `json:"spans_matched,omitempty"`
+	Events     int64  `json:"events_matched"`
+	OTTLErrors int64  `json:"ottl_errors"`
+}
+
+func (c *conditionReport) matched() bool {
+	if c.Spans != nil {
+		return *c.Spans > 0
+	}
+	return c.Events > 0
+}
+
+// exampleRecord is one produced log record, shown with the rendered body
+type exampleRecord struct {
+	TraceID   string `json:"trace_id"`
+	SpanID    string `json:"span_id"`
+	SpanName  string `json:"span_name"`
+	EventName string `json:"event_name"`
+	Severity  string `json:"severity"`
+	Body      string `json:"body"`
+}
+
+// errorMessage is a distinct error the connector logged, with how often it occurred
+type errorMessage struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+type validateReport struct {
+	Inputs     int               `json:"inputs"`
+	Spans      int               `json:"spans"`
+	Events     int               `json:"events"`
+	Conditions []conditionReport `json:"conditions"`
+	// SpansMatched, EventsMatched and LogRecords are the result of the whole configuration
+	SpansMatched   int64           `json:"spans_matched"`
+	EventsMatched  int64           `json:"events_matched"`
+	LogRecords     int             `json:"log_records"`
+	OTTLErrors     int64           `json:"ottl_errors"`
+	TemplateErrors int64           `json:"template_errors"`
+	Examples       []exampleRecord `json:"examples"`
+	Errors         []errorMessage  `json:"errors"`
+}
+
+func (r *validateReport) unmatched() []string {
+	var fields []string
+	for i := range r.Conditions {
+		if !r.Conditions[i].matched() {
+			fields = append(fields, r.Conditions[i].Field)
+		}
+	}
+	return fields
+}
+
+// validateConfig runs the configuration against the traces once as a whole, then once per
+// condition with that condition alone, so every condition is reported on its own
+func validateConfig(ctx context.Context, cfg *spaneventstologconnector.Config, traces []ptrace.Traces, examples int) (*validateReport, error) {
+	report := &validateReport{Examples: []exampleRecord{}, Errors: []errorMessage{}}
+	for _, td := range traces {
+		report.Spans += td.SpanCount()
+		report.Events += eventCount(td)
+	}
+
+	// The traces are converted several times, so they must not be moved from
+	whole := *cfg
+	whole.MoveData = false
+	result, err := probe(ctx, &whole, traces, examples)
+	if err != nil {
+		return nil, err
+	}
+	report.SpansMatched = result.sum("otelcol_connector_spaneventstolog_spans_matched")
+	report.EventsMatched = result.sum("otelcol_connector_spaneventstolog_events_matched")
+	report.LogRecords = result.records
+	report.OTTLErrors = result.sum("otelcol_connector_spaneventstolog_ottl_errors")
+	report.TemplateErrors = result.sum("otelcol_connector_spaneventstolog_template_errors")
+	report.Examples = append(report.Examples, result.examples...)
+	report.Errors = append(report.Errors, result.errors...)
+
+	lists := []struct {
+		field      string
+		conditions []string
+		set        func(c *spaneventstologconnector.Config, condition string)
+	}{
+		{"span_conditions", cfg.SpanConditions, func(c *spaneventstologconnector.Config, condition string) { c.SpanConditions = []string{condition} }},
+		{"event_conditions", cfg.EventConditions, func(c *spaneventstologconnector.Config, condition string) { c.EventConditions = []string{condition} }},
+		{"conditions", cfg.Conditions, func(c *spaneventstologconnector.Config, condition string) { c.Conditions = []string{condition} }},
+	}
+	for _, list := range lists {
+		for i, condition := range list.conditions {
+			single := isolatedConfig(cfg)
+			list.set(single, condition)
+			result, err := probe(ctx, single, traces, 0)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", list.field, i, err)
+			}
+			c := conditionReport{
+				Field:      fmt.Sprintf("%s[%d]", list.field, i),
+				Condition:  condition,
+				Events:     result.sum("otelcol_connector_spaneventstolog_events_matched"),
+				OTTLErrors: result.sum("otelcol_connector_spaneventstolog_ottl_errors", attribute.String("stage", "condition")),
+			}
+			if list.field == "span_conditions" {
+				spans := result.sum("otelcol_connector_spaneventstolog_spans_matched")
+				c.Spans = &spans
+			}
+			report.Conditions = append(report.Conditions, c)
+		}
+	}
+	return report, nil
+}
+
+// isolatedConfig returns a copy of cfg without conditions and without the features that
+// hold back matching records, so a single condition can be added and measured
+func isolatedConfig(cfg *spaneventstologconnector.Config) *spaneventstologconnector.Config {
+	c := *cfg
+	c.SpanConditions, c.EventConditions, c.Conditions = nil, nil, nil
+	c.MoveData = false
+	c.Dedup = spaneventstologconnector.DedupConfig{}
+	c.RateLimit = spaneventstologconnector.RateLimitConfig{}
+	c.Routing = spaneventstologconnector.RoutingConfig{}
+	return &c
+}
+
+func eventCount(td ptrace.Traces) int {
+	n := 0
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		ss := td.ResourceSpans().At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			spans := ss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				n += spans.At(k).Events().Len()
+			}
+		}
+	}
+	return n
+}
+
+// probeResult is what one run of the connector over the sample traces produced
+type probeResult struct {
+	metrics  metricdata.ResourceMetrics
+	records  int
+	examples []exampleRecord
+	errors   []errorMessage
+}
+
+// sum adds up the data points of the metric called name that have all the given attributes
+func (p *probeResult) sum(name string, attrs ...attribute.KeyValue) int64 {
+	var total int64
+	for _, sm := range p.metrics.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if m.Name != name || !ok {
+				continue
+			}
+		points:
+			for _, dp := range sum.DataPoints {
+				for _, attr := range attrs {
+					if v, ok := dp.Attributes.Value(attr.Key); !ok || v != attr.Value {
+						continue points
+					}
+				}
+				total += dp.Value
+			}
+		}
+	}
+	return total
+}
+
+// probe converts the traces with cfg and reads the connector's own metrics and error logs
+func probe(ctx context.Context, cfg *spaneventstologconnector.Config, traces []ptrace.Traces, examples int) (*probeResult, error) {
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer meterProvider.Shutdown(ctx)
+	core, logs := observer.New(zapcore.WarnLevel)
+	telemetry := newTelemetrySettings(zap.New(core))
+	telemetry.MeterProvider = meterProvider
+
+	conv, err := newConversion(ctx, cfg, telemetry)
+	if err != nil {
+		return nil, err
+	}
+	defer conv.Shutdown(ctx)
+
+	result := &probeResult{}
+	for _, td := range traces {
+		if err := conv.Convert(ctx, td); err != nil {
+			return nil, err
+		}
+		_ = conv.Drain(func(l plog.Logs, _ string) error {
+			result.records += l.LogRecordCount()
+			rangeLogRecords(l, func(_ pcommon.Resource, _ pcommon.InstrumentationScope, lr plog.LogRecord) {
+				if len(result.examples) < examples {
+					result.examples = append(result.examples, newExampleRecord(lr))
+				}
+			})
+			return nil
+		})
+	}
+	if err := reader.Collect(ctx, &result.metrics); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	for _, entry := range logs.All() {
+		message := entry.Message
+		if err, ok := entry.ContextMap()["error"]; ok {
+			message = fmt.Sprintf("%s: %v", message, err)
+		}
+		if i, ok := index[message]; ok {
+			result.errors[i].Count++
+			continue
+		}
+		index[message] = len(result.errors)
+		result.errors = append(result.errors, errorMessage{Message: message, Count: 1})
+	}
+	return result, nil
+}
+
+func newExampleRecord(lr plog.LogRecord) exampleRecord {
+	attr := func(key string) string {
+		if v, ok := lr.Attributes().Get(key); ok {
+			return v.AsString()
+		}
+		return ""
+	}
+	return exampleRecord{
+		TraceID:   lr.TraceID().String(),
+		SpanID:    lr.SpanID().String(),
+		SpanName:  attr("span.name"),
+		EventName: attr("event.name"),
+		Severity:  lr.SeverityText(),
+		Body:      lr.Body().AsString(),
+	}
+}
+
+func (r *validateReport) write(w io.Writer) error {
+	fmt.Fprintf(w, "Sample: %d inputs, %d spans, %d span events\n\n", r.Inputs, r.Spans, r.Events)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONDITION\tSPANS\tEVENTS\tERRORS\t")
+	for i := range r.Conditions {
+		c := &r.Conditions[i]
+		spans := "-"
+		if c.Spans != nil {
+			spans = fmt.Sprint(*c.Spans)
+		}
+		status := ""
+		if !c.matched() {
+			status = "NO MATCH"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\t%d\t%d\t%s\n", c.Field, truncate(c.Condition, 60), spans, c.Events, c.OTTLErrors, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nWhole configuration: %d spans matched, %d span events converted to %d log records\n", r.SpansMatched, r.EventsMatched, r.LogRecords)
+	fmt.Fprintf(w, "OTTL runtime errors: %d, template errors: %d\n", r.OTTLErrors, r.TemplateErrors)
+
+	if len(r.Examples) > 0 {
+		fmt.Fprintln(w, "\nExample log records:")
+		for _, e := range r.Examples {
+			fmt.Fprintf(w, "  %s %s / %s (trace_id=%s span_id=%s)\n", e.Severity, e.SpanName, e.EventName, e.TraceID, e.SpanID)
+			fmt.Fprintf(w, "    %s\n", truncate(e.Body, 200))
+		}
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(w, "\nErrors:")
+		for _, e := range r.Errors {
+			fmt.Fprintf(w, "  %dx %s\n", e.Count, truncate(e.Message, 200))
+		}
+	}
+	if unmatched := r.unmatched(); len(unmatched) > 0 {
+		fmt.Fprintf(w, "\nConditions without a match: %s\n", strings.Join(unmatched, ", "))
+	}
+	return nil
+}
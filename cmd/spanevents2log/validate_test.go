@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		code   int
+		check  func(t *testing.T, report *validateReport)
+	}{
+		{
+			name:   "all conditions match",
+			config: testConfig,
+			check: func(t *testing.T, report *validateReport) {
+				if len(report.Conditions) != 1 || report.Conditions[0].Events != 3 {
+					t.Errorf("conditions = %+v, want event_conditions[0] with 3 events", report.Conditions)
+				}
+				if report.LogRecords != 3 || len(report.Examples) != 3 || report.Examples[0].Body != "GET: exception" {
+					t.Errorf("got %d records and examples %+v", report.LogRecords, report.Examples)
+				}
+			},
+		},
+		{
+			name: "condition without match",
+			config: `
+span_conditions:
+  - 'kind == SPAN_KIND_CLIENT'
+  - 'name == "POST /checkout"'
+`,
+			code: exitUnmatched,
+			check: func(t *testing.T, report *validateReport) {
+				if got := report.unmatched(); len(got) != 1 || got[0] != "span_conditions[1]" {
+					t.Errorf("unmatched = %v, want [span_conditions[1]]", got)
+				}
+				if spans := report.Conditions[0].Spans; spans == nil || *spans == 0 {
+					t.Error("span_conditions[0] matched no spans")
+				}
+			},
+		},
+		{
+			name: "allow unmatched",
+			config: `
+event_conditions:
+  - 'name == "retry"'
+`,
+			args: []string{"-allow-unmatched"},
+		},
+		{
+			name: "template errors",
+			config: `
+event_conditions:
+  - 'name == "exception"'
+log_body_template: '{{if eq .EventName "exception"}}{{index .SpanName 100}}{{end}}'
+`,
+			code: exitErrors,
+			check: func(t *testing.T, report *validateReport) {
+				if report.TemplateErrors != 3 {
+					t.Errorf("template errors = %d, want 3", report.TemplateErrors)
+				}
+				if len(report.Errors) != 1 || report.Errors[0].Count != 3 || !strings.Contains(report.Errors[0].Message, "template") {
+					t.Errorf("errors = %+v, want one template error logged 3 times", report.Errors)
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config := writeTestFile(t, "config.yaml", tc.config)
+			args := append([]string{"validate", "-config", config, "-json", "-against", exceptionTraces}, tc.args...)
+			var stdout, stderr bytes.Buffer
+			if code := run(args, nil, &stdout, &stderr); code != tc.code {
+				t.Fatalf("exit code = %d, want %d: %s", code, tc.code, stderr.String())
+			}
+			var report validateReport
+			if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+				t.Fatalf("output is not a JSON report: %v", err)
+			}
+			if tc.check != nil {
+				tc.check(t, &report)
+			}
+		})
+	}
+}
+
+func TestRunValidate_Text(t *testing.T) {
+	config := writeTestFile(t, "config.yaml", testConfig)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"validate", "-config", config, exceptionTraces}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{`event_conditions[0] name == "exception"`, "3 span events converted to 3 log records", "GET: exception"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+}
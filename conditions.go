@@ -0,0 +1,324 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlresource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlscope"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// MatchMode determines how the conditions of one condition list are combined
+type MatchMode string
+
+const (
+	// MatchAny matches when at least one condition is true
+	MatchAny MatchMode = "any"
+	// MatchAll matches when every condition is true
+	MatchAll MatchMode = "all"
+)
+
+func (m MatchMode) validate(field string) error {
+	switch m {
+	case "", MatchAny, MatchAll:
+		return nil
+	default:
+		return fmt.Errorf("invalid %s: %q, must be one of [%s %s]", field, m, MatchAny, MatchAll)
+	}
+}
+
+// parsedOTTL is the common result type of the connector's OTTL parser collection.
+// Only the fields of the parsed context are set.
+type parsedOTTL struct {
+	resource         []*ottl.Condition[ottlresource.TransformContext]
+	scope            []*ottl.Condition[ottlscope.TransformContext]
+	span             []*ottl.Condition[ottlspan.TransformContext]
+	spanEvent        []*ottl.Condition[ottlspanevent.TransformContext]
+	valueExpressions []*ottl.ValueExpression[ottlspanevent.TransformContext]
+}
+
+// newParserCollection builds the OTTL parser collection shared by Config.Validate and
+// NewSpanEventConnector. It supports the resource, scope, span and spanevent contexts.
+func newParserCollection(
+	settings component.TelemetrySettings,
+	spanFunctions map[string]ottl.Factory[ottlspan.TransformContext],
+	spanEventFunctions map[string]ottl.Factory[ottlspanevent.TransformContext],
+) (*ottl.ParserCollection[parsedOTTL], error) {
+	resourceParser, err := ottlresource.NewParser(ottlfuncs.StandardFuncs[ottlresource.TransformContext](), settings, ottlresource.EnablePathContextNames())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTTL resource parser: %w", err)
+	}
+	scopeParser, err := ottlscope.NewParser(ottlfuncs.StandardFuncs[ottlscope.TransformContext](), settings, ottlscope.EnablePathContextNames())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTTL scope parser: %w", err)
+	}
+	spanParser, err := ottlspan.NewParser(spanFunctions, settings, ottlspan.EnablePathContextNames())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTTL span parser: %w", err)
+	}
+	eventParser, err := ottlspanevent.NewParser(spanEventFunctions, settings, ottlspanevent.EnablePathContextNames())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTTL event parser: %w", err)
+	}
+
+	return ottl.NewParserCollection[parsedOTTL](
+		settings,
+		ottl.WithParserCollectionContext(ottlresource.ContextName, &resourceParser,
+			ottl.WithConditionConverter(func(_ *ottl.ParserCollection[parsedOTTL], _ ottl.ConditionsGetter, parsed []*ottl.Condition[ottlresource.TransformContext]) (parsedOTTL, error) {
+				return parsedOTTL{resource: parsed}, nil
+			})),
+		ottl.WithParserCollectionContext(ottlscope.ContextName, &scopeParser,
+			ottl.WithConditionConverter(func(_ *ottl.ParserCollection[parsedOTTL], _ ottl.ConditionsGetter, parsed []*ottl.Condition[ottlscope.TransformContext]) (parsedOTTL, error) {
+				return parsedOTTL{scope: parsed}, nil
+			})),
+		ottl.WithParserCollectionContext(ottlspan.ContextName, &spanParser,
+			ottl.WithConditionConverter(func(_ *ottl.ParserCollection[parsedOTTL], _ ottl.ConditionsGetter, parsed []*ottl.Condition[ottlspan.TransformContext]) (parsedOTTL, error) {
+				return parsedOTTL{span: parsed}, nil
+			})),
+		ottl.WithParserCollectionContext(ottlspanevent.ContextName, &eventParser,
+			ottl.WithConditionConverter(func(_ *ottl.ParserCollection[parsedOTTL], _ ottl.ConditionsGetter, parsed []*ottl.Condition[ottlspanevent.TransformContext]) (parsedOTTL, error) {
+				return parsedOTTL{spanEvent: parsed}, nil
+			}),
+			ottl.WithValueExpressionConverter(func(_ *ottl.ParserCollection[parsedOTTL], _ ottl.ValueExpressionsGetter, parsed []*ottl.ValueExpression[ottlspanevent.TransformContext]) (parsedOTTL, error) {
+				return parsedOTTL{valueExpressions: parsed}, nil
+			})),
+	)
+}
+
+// compiledOTTL holds everything the connector parses from OTTL in the config.
+// Config.Validate and NewSpanEventConnector both build it with compileOTTL so
+// that validation and runtime always agree.
+type compiledOTTL struct {
+	// spanConditions is nil when no span conditions are configured
+	spanConditions *conditionMatcher[ottlspan.TransformContext]
+	// eventConditions is nil when no event conditions are configured
+	eventConditions *conditionMatcher[ottlspanevent.TransformContext]
+	// conditions is nil when no context-inferred conditions are configured
+	conditions  *contextConditions
+	expressions *logExpressions
+	// routeConditions has one entry per route, nil for routes without a condition
+	routeConditions []*conditionMatcher[ottlspanevent.TransformContext]
+}
+
+// compileOTTL parses span_conditions, event_conditions, conditions, body_expression, attributes
+// and the routing conditions.
+// Paths in span_conditions and event_conditions may omit their context prefix, in which case
+// span and spanevent are assumed; paths in conditions must always be prefixed.
+// Every condition is parsed by OTTL, but simple ones are evaluated by native matchers.
+// telemetry records condition errors; it is nil when only validating.
+func compileOTTL(cfg *Config, settings component.TelemetrySettings, telemetry *metadata.TelemetryBuilder) (*compiledOTTL, error) {
+	errorMode := cfg.ErrorMode
+	if errorMode == "" {
+		errorMode = ottl.IgnoreError
+	}
+
+	spanFunctions := cfg.spanFunctions
+	if spanFunctions == nil {
+		spanFunctions = defaultSpanFunctions()
+	}
+	spanEventFunctions := cfg.spanEventFunctions
+	if spanEventFunctions == nil {
+		spanEventFunctions = defaultSpanEventFunctions()
+	}
+
+	pc, err := newParserCollection(settings, spanFunctions, spanEventFunctions)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &compiledOTTL{}
+
+	if len(cfg.SpanConditions) > 0 {
+		var conditions []matcherCondition[ottlspan.TransformContext]
+		for _, cond := range cfg.SpanConditions {
+			parsed, err := pc.ParseConditionsWithContext(ottlspan.ContextName, ottl.NewConditionsGetter([]string{cond}), true)
+			if err != nil {
+				return nil, fmt.Errorf("invalid span_condition OTTL: %q: %w", cond, err)
+			}
+			conditions = append(conditions, newMatcherCondition(cond, ottlspan.ContextName, parsed.span[0]))
+		}
+		compiled.spanConditions = newConditionMatcher(conditions, settings, telemetry, errorMode, cfg.SpanConditionsMatch)
+	}
+
+	if len(cfg.EventConditions) > 0 {
+		var conditions []matcherCondition[ottlspanevent.TransformContext]
+		for _, cond := range cfg.EventConditions {
+			parsed, err := pc.ParseConditionsWithContext(ottlspanevent.ContextName, ottl.NewConditionsGetter([]string{cond}), true)
+			if err != nil {
+				return nil, fmt.Errorf("invalid event_condition OTTL: %q: %w", cond, err)
+			}
+			conditions = append(conditions, newMatcherCondition(cond, ottlspanevent.ContextName, parsed.spanEvent[0]))
+		}
+		compiled.eventConditions = newConditionMatcher(conditions, settings, telemetry, errorMode, cfg.EventConditionsMatch)
+	}
+
+	if len(cfg.Conditions) > 0 {
+		compiled.conditions, err = newContextConditions(cfg, pc, settings, telemetry, errorMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	compiled.expressions, err = parseLogExpressions(cfg, pc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, route := range cfg.Routing.Routes {
+		var condition *conditionMatcher[ottlspanevent.TransformContext]
+		if route.Condition != "" {
+			parsed, err := pc.ParseConditionsWithContext(ottlspanevent.ContextName, ottl.NewConditionsGetter([]string{route.Condition}), true)
+			if err != nil {
+				return nil, fmt.Errorf("invalid routing.routes[%d] condition OTTL: %q: %w", i, route.Condition, err)
+			}
+			conditions := []matcherCondition[ottlspanevent.TransformContext]{newMatcherCondition(route.Condition, ottlspanevent.ContextName, parsed.spanEvent[0])}
+			condition = newConditionMatcher(conditions, settings, telemetry, errorMode, MatchAny)
+		}
+		compiled.routeConditions = append(compiled.routeConditions, condition)
+	}
+
+	return compiled, nil
+}
+
+// conditionsResult is the outcome of evaluating one level of the context-inferred conditions
+type conditionsResult int
+
+const (
+	// conditionsUndecided means lower levels decide whether a span event matches
+	conditionsUndecided conditionsResult = iota
+	// conditionsMatched means every span event below this level matches
+	conditionsMatched
+	// conditionsRejected means no span event below this level matches
+	conditionsRejected
+)
+
+// contextConditions evaluates the `conditions` list. Each condition is grouped by the lowest
+// context it references, so resource and scope conditions run once per ResourceSpans and
+// ScopeSpans and can skip whole subtrees.
+type contextConditions struct {
+	matchAll bool
+	resource *conditionMatcher[ottlresource.TransformContext]
+	scope    *conditionMatcher[ottlscope.TransformContext]
+	span     *conditionMatcher[ottlspan.TransformContext]
+	event    *conditionMatcher[ottlspanevent.TransformContext]
+}
+
+func newContextConditions(cfg *Config, pc *ottl.ParserCollection[parsedOTTL], settings component.TelemetrySettings, telemetry *metadata.TelemetryBuilder, errorMode ottl.ErrorMode) (*contextConditions, error) {
+	var (
+		resourceConds []matcherCondition[ottlresource.TransformContext]
+		scopeConds    []matcherCondition[ottlscope.TransformContext]
+		spanConds     []matcherCondition[ottlspan.TransformContext]
+		eventConds    []matcherCondition[ottlspanevent.TransformContext]
+	)
+	for _, cond := range cfg.Conditions {
+		parsed, err := pc.ParseConditions(ottl.NewConditionsGetter([]string{cond}))
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition OTTL: %q: %w", cond, err)
+		}
+		switch {
+		case len(parsed.resource) > 0:
+			resourceConds = append(resourceConds, newMatcherCondition(cond, ottlresource.ContextName, parsed.resource[0]))
+		case len(parsed.scope) > 0:
+			scopeConds = append(scopeConds, newMatcherCondition(cond, ottlscope.ContextName, parsed.scope[0]))
+		case len(parsed.span) > 0:
+			spanConds = append(spanConds, newMatcherCondition(cond, ottlspan.ContextName, parsed.span[0]))
+		case len(parsed.spanEvent) > 0:
+			eventConds = append(eventConds, newMatcherCondition(cond, ottlspanevent.ContextName, parsed.spanEvent[0]))
+		}
+	}
+
+	return &contextConditions{
+		matchAll: cfg.ConditionsMatch == MatchAll,
+		resource: newConditionMatcher(resourceConds, settings, telemetry, errorMode, cfg.ConditionsMatch),
+		scope:    newConditionMatcher(scopeConds, settings, telemetry, errorMode, cfg.ConditionsMatch),
+		span:     newConditionMatcher(spanConds, settings, telemetry, errorMode, cfg.ConditionsMatch),
+		event:    newConditionMatcher(eventConds, settings, telemetry, errorMode, cfg.ConditionsMatch),
+	}, nil
+}
+
+// evalLevel evaluates one level's conditions and turns their result into a conditionsResult
+func evalLevel[K any](ctx context.Context, c *contextConditions, m *conditionMatcher[K], target nativeTarget, newTCtx func() K) (conditionsResult, error) {
+	if m == nil {
+		return conditionsUndecided, nil
+	}
+	match, err := m.eval(ctx, target, newTCtx)
+	if err != nil {
+		return conditionsRejected, err
+	}
+	switch {
+	case c.matchAll && !match:
+		return conditionsRejected, nil
+	case !c.matchAll && match:
+		return conditionsMatched, nil
+	default:
+		return conditionsUndecided, nil
+	}
+}
+
+// evalResource evaluates the resource-level conditions once per ResourceSpans
+func (c *contextConditions) evalResource(ctx context.Context, rs ptrace.ResourceSpans) (conditionsResult, error) {
+	if c == nil {
+		return conditionsMatched, nil
+	}
+	return evalLevel(ctx, c, c.resource, nativeTarget{resource: rs.Resource()}, func() ottlresource.TransformContext {
+		return ottlresource.NewTransformContext(rs.Resource(), rs)
+	})
+}
+
+// evalScope evaluates the scope-level conditions once per ScopeSpans
+func (c *contextConditions) evalScope(ctx context.Context, rs ptrace.ResourceSpans, ss ptrace.ScopeSpans) (conditionsResult, error) {
+	if c == nil || c.scope == nil {
+		return conditionsUndecided, nil
+	}
+	return evalLevel(ctx, c, c.scope, nativeTarget{resource: rs.Resource()}, func() ottlscope.TransformContext {
+		return ottlscope.NewTransformContext(ss.Scope(), rs.Resource(), ss)
+	})
+}
+
+// evalSpan evaluates the span-level conditions once per span
+func (c *contextConditions) evalSpan(ctx context.Context, span ptrace.Span, rs ptrace.ResourceSpans, ss ptrace.ScopeSpans) (conditionsResult, error) {
+	if c == nil || c.span == nil {
+		return conditionsUndecided, nil
+	}
+	return evalLevel(ctx, c, c.span, nativeTarget{resource: rs.Resource(), span: span}, func() ottlspan.TransformContext {
+		return ottlspan.NewTransformContext(span, ss.Scope(), rs.Resource(), ss, rs)
+	})
+}
+
+// evalEvent evaluates the event-level conditions and resolves the final match for the event
+func (c *contextConditions) evalEvent(ctx context.Context, event ptrace.SpanEvent, span ptrace.Span, rs ptrace.ResourceSpans, ss ptrace.ScopeSpans) (bool, error) {
+	result := conditionsUndecided
+	if c != nil && c.event != nil {
+		var err error
+		result, err = evalLevel(ctx, c, c.event, nativeTarget{resource: rs.Resource(), span: span, event: event}, func() ottlspanevent.TransformContext {
+			return ottlspanevent.NewTransformContext(event, span, ss.Scope(), rs.Resource(), ss, rs)
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	return c.resolve(result), nil
+}
+
+// resolve turns a result that is still undecided after the event level into a match:
+// with "all" every condition passed, with "any" none did.
+func (c *contextConditions) resolve(result conditionsResult) bool {
+	switch result {
+	case conditionsMatched:
+		return true
+	case conditionsRejected:
+		return false
+	default:
+		return c == nil || c.matchAll
+	}
+}
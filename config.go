@@ -4,15 +4,12 @@
 package spaneventstologconnector
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"text/template"
-	"text/template/parse"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/confmap"
 	"go.uber.org/zap"
@@ -28,6 +25,24 @@ type Config struct {
 	// If empty, all events will be processed
 	EventConditions []string `mapstructure:"event_conditions"`
 
+	// Conditions defines OTTL conditions whose paths carry an explicit context prefix
+	// (resource, scope, span or spanevent). Each condition is evaluated at the lowest
+	// context it references, so resource and scope conditions can skip whole subtrees
+	Conditions []string `mapstructure:"conditions"`
+
+	// ConditionsMatch determines whether any (default) or all of Conditions must match
+	ConditionsMatch MatchMode `mapstructure:"conditions_match"`
+
+	// SpanConditionsMatch determines whether any (default) or all span conditions must match
+	SpanConditionsMatch MatchMode `mapstructure:"span_conditions_match"`
+
+	// EventConditionsMatch determines whether any (default) or all event conditions must match
+	EventConditionsMatch MatchMode `mapstructure:"event_conditions_match"`
+
+	// ErrorMode determines how OTTL runtime errors in conditions are handled:
+	// ignore (log and continue, default), silent (continue) or propagate (fail the batch)
+	ErrorMode ottl.ErrorMode `mapstructure:"error_mode"`
+
 	// IncludeSpanAttributes determines if span attributes should be included in the log record
 	IncludeSpanAttributes bool `mapstructure:"include_span_attributes"`
 
@@ -41,179 +56,169 @@ type Config struct {
 	// Available placeholders: {{.EventName}}, {{.SpanName}}, {{.EventAttributes}}, {{.SpanAttributes}}
 	LogBodyTemplate string `mapstructure:"log_body_template"`
 
+	// BodyTemplates maps span event names to log body templates, overriding LogBodyTemplate
+	// Keys may be exact event names, glob patterns (e.g. "cart.*") or "default"
+	BodyTemplates map[string]BodyTemplateConfig `mapstructure:"body_templates"`
+
+	// TemplateFiles lists files of shared template definitions ({{define "name"}})
+	// that body templates can include with {{template "name" .}}
+	TemplateFiles []string `mapstructure:"template_files"`
+
+	// BodyExpression is an OTTL value expression, evaluated in the span event context,
+	// whose result becomes the log body. When set it takes precedence over the templates
+	BodyExpression string `mapstructure:"body_expression"`
+
+	// Attributes maps log attribute keys to OTTL value expressions evaluated in the span event context
+	Attributes map[string]string `mapstructure:"attributes"`
+
+	// Workers is the number of goroutines that convert one batch concurrently, each taking
+	// whole ResourceSpans. 0 or 1 converts on the calling goroutine (default)
+	Workers int `mapstructure:"workers"`
+
+	// ParallelThreshold is the minimum number of spans a batch must have before Workers are used
+	ParallelThreshold int `mapstructure:"parallel_threshold"`
+
+	// MoveData moves event attributes into the log records instead of copying them.
+	// The connector then declares that it mutates its input, so the collector only
+	// hands it traces that no other consumer reads
+	MoveData bool `mapstructure:"move_data"`
+
+	// LogRecordUID adds a deterministic log.record.uid attribute, derived from the trace ID,
+	// span ID, event index, event timestamp and rule, so backends can deduplicate records
+	LogRecordUID bool `mapstructure:"log_record_uid"`
+
+	// Dedup drops log records whose log.record.uid was already emitted within a window
+	Dedup DedupConfig `mapstructure:"dedup"`
+
+	// Routing sends log records to different logs pipelines by rule, severity or OTTL condition.
+	// Without routes every record goes to all pipelines the connector feeds
+	Routing RoutingConfig `mapstructure:"routing"`
+
+	// Tenant stamps tenant identifiers from client metadata, auth data or resource attributes
+	// on the produced logs and forwards them as client metadata to the logs pipelines
+	Tenant TenantConfig `mapstructure:"tenant"`
+
+	// RateLimit limits the log records produced per service, or per value of another
+	// resource attribute, in records per second and bytes per minute
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+
+	// OnLogsError determines what happens when the next consumer rejects the log records:
+	// propagate the error to the traces pipeline (default), drop them or retry them
+	OnLogsError LogsErrorPolicy `mapstructure:"on_logs_error"`
+
+	// RetryQueue configures the queue used when OnLogsError is retry
+	RetryQueue RetryQueueConfig `mapstructure:"retry_queue"`
+
+	// Telemetry adds optional attributes to the connector's internal metrics
+	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+
+	// Transformers names the event transformers that run, in order, on every log record.
+	// Transformers are registered by custom collector builds with WithTransformers.
+	Transformers []string `mapstructure:"transformers"`
+
+	// spanFunctions and spanEventFunctions are the OTTL functions available to the
+	// span and spanevent contexts. They are set by the factory; nil means the defaults.
+	spanFunctions      map[string]ottl.Factory[ottlspan.TransformContext]
+	spanEventFunctions map[string]ottl.Factory[ottlspanevent.TransformContext]
+
+	// transformers are the event transformers registered with the factory
+	transformers map[string]EventTransformer
+
 	// prevent unkeyed literal initialization
 	_ struct{}
 }
 
 // Validate implements component.Config
 func (cfg *Config) Validate() error {
-	if len(cfg.SpanConditions) == 0 && len(cfg.EventConditions) == 0 {
-		return errors.New("at least one span condition or event condition must be specified")
-	}
-
-	if cfg.LogLevel != "" {
-		validLevels := []string{"Trace", "Debug", "Info", "Warn", "Error", "Fatal"}
-		found := false
-		for _, level := range validLevels {
-			if cfg.LogLevel == level {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("invalid log_level: %s, must be one of %v", cfg.LogLevel, validLevels)
-		}
+	if len(cfg.SpanConditions) == 0 && len(cfg.EventConditions) == 0 && len(cfg.Conditions) == 0 {
+		return errors.New("at least one span condition, event condition or condition must be specified")
 	}
 
-	logger := zap.NewNop()
-	settings := component.TelemetrySettings{Logger: logger}
+	if cfg.LogLevel != "" && !isValidLogLevel(cfg.LogLevel) {
+		return fmt.Errorf("invalid log_level: %s, must be one of %v", cfg.LogLevel, validLogLevels)
+	}
 
-	// Validate OTTL span conditions
-	if len(cfg.SpanConditions) > 0 {
-		parser, err := ottlspan.NewParser(ottlfuncs.StandardFuncs[ottlspan.TransformContext](), settings)
-		if err != nil {
-			return fmt.Errorf("failed to create OTTL span parser: %w", err)
-		}
-		for _, cond := range cfg.SpanConditions {
-			if _, err := parser.ParseCondition(cond); err != nil {
-				return fmt.Errorf("invalid span_condition OTTL: %q: %w", cond, err)
-			}
-		}
+	switch cfg.ErrorMode {
+	case "", ottl.IgnoreError, ottl.SilentError, ottl.PropagateError:
+	default:
+		return fmt.Errorf("invalid error_mode: %s, must be one of [%s %s %s]", cfg.ErrorMode, ottl.IgnoreError, ottl.SilentError, ottl.PropagateError)
 	}
 
-	// Validate OTTL event conditions
-	if len(cfg.EventConditions) > 0 {
-		parser, err := ottlspanevent.NewParser(ottlfuncs.StandardFuncs[ottlspanevent.TransformContext](), settings)
-		if err != nil {
-			return fmt.Errorf("failed to create OTTL event parser: %w", err)
-		}
-		for _, cond := range cfg.EventConditions {
-			if _, err := parser.ParseCondition(cond); err != nil {
-				return fmt.Errorf("invalid event_condition OTTL: %q: %w", cond, err)
-			}
-		}
+	if cfg.Workers < 0 {
+		return fmt.Errorf("invalid workers: %d, must not be negative", cfg.Workers)
+	}
+	if cfg.ParallelThreshold < 0 {
+		return fmt.Errorf("invalid parallel_threshold: %d, must not be negative", cfg.ParallelThreshold)
 	}
 
-	// Validate log body template
-	if cfg.LogBodyTemplate != "" {
-		tmpl, err := template.New("logBody").Parse(cfg.LogBodyTemplate)
-		if err != nil {
-			return fmt.Errorf("invalid log_body_template: %w", err)
-		}
-		// Try to execute the template with a dummy struct to catch invalid fields
-		dummy := struct {
-			EventName       string
-			SpanName        string
-			EventAttributes map[string]string
-			SpanAttributes  map[string]string
-		}{
-			EventName:       "event",
-			SpanName:        "span",
-			EventAttributes: map[string]string{"key": "value"},
-			SpanAttributes:  map[string]string{"key": "value"},
-		}
-		var buf bytes.Buffer
-		if err := tmpl.Execute(&buf, dummy); err != nil {
-			return fmt.Errorf("invalid log_body_template (execution): %w", err)
-		}
+	if err := cfg.Dedup.validate(); err != nil {
+		return err
+	}
 
-		// Strict validation: walk the template AST and ensure only allowed fields are referenced
-		allowed := map[string]struct{}{
-			"EventName":       {},
-			"SpanName":        {},
-			"EventAttributes": {},
-			"SpanAttributes":  {},
-		}
-		for _, tree := range tmpl.Templates() {
-			if tree == nil || tree.Tree == nil {
-				continue
-			}
-			var walkNodes func(n parse.Node) error
-			walkNodes = func(n parse.Node) error {
-				if n == nil {
-					return nil
-				}
-				switch node := n.(type) {
-				case *parse.FieldNode:
-					if len(node.Ident) > 0 {
-						field := node.Ident[0]
-						fmt.Printf("DEBUG: Found field node: .%s\n", field)
-						if _, ok := allowed[field]; !ok {
-							return fmt.Errorf("invalid field in log_body_template: .%s is not allowed", field)
-						}
-					}
-				case *parse.VariableNode:
-					if len(node.Ident) > 0 {
-						field := node.Ident[0]
-						fmt.Printf("DEBUG: Found variable node: .%s\n", field)
-						if _, ok := allowed[field]; !ok {
-							return fmt.Errorf("invalid field in log_body_template: .%s is not allowed", field)
-						}
-					}
-				case *parse.ListNode:
-					for _, child := range node.Nodes {
-						if err := walkNodes(child); err != nil {
-							return err
-						}
-					}
-				case *parse.ActionNode:
-					if err := walkNodes(node.Pipe); err != nil {
-						return err
-					}
-				case *parse.PipeNode:
-					for _, cmd := range node.Cmds {
-						if err := walkNodes(cmd); err != nil {
-							return err
-						}
-					}
-				case *parse.CommandNode:
-					for _, arg := range node.Args {
-						if err := walkNodes(arg); err != nil {
-							return err
-						}
-					}
-				case *parse.IfNode:
-					if err := walkNodes(node.Pipe); err != nil {
-						return err
-					}
-					if err := walkNodes(node.List); err != nil {
-						return err
-					}
-					if err := walkNodes(node.ElseList); err != nil {
-						return err
-					}
-				case *parse.RangeNode:
-					if err := walkNodes(node.Pipe); err != nil {
-						return err
-					}
-					if err := walkNodes(node.List); err != nil {
-						return err
-					}
-					if err := walkNodes(node.ElseList); err != nil {
-						return err
-					}
-				case *parse.WithNode:
-					if err := walkNodes(node.Pipe); err != nil {
-						return err
-					}
-					if err := walkNodes(node.List); err != nil {
-						return err
-					}
-					if err := walkNodes(node.ElseList); err != nil {
-						return err
-					}
-				}
-				return nil
-			}
-			if err := walkNodes(tree.Tree.Root); err != nil {
-				return err
-			}
+	if err := cfg.Routing.validate(cfg.BodyTemplates); err != nil {
+		return err
+	}
+
+	if err := cfg.Tenant.validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RateLimit.validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.OnLogsError.validate(); err != nil {
+		return err
+	}
+	if cfg.OnLogsError == LogsErrorRetry {
+		if err := cfg.RetryQueue.validate(); err != nil {
+			return err
 		}
 	}
 
+	if err := cfg.SpanConditionsMatch.validate("span_conditions_match"); err != nil {
+		return err
+	}
+	if err := cfg.EventConditionsMatch.validate("event_conditions_match"); err != nil {
+		return err
+	}
+	if err := cfg.ConditionsMatch.validate("conditions_match"); err != nil {
+		return err
+	}
+
+	if err := validateTransformers(cfg.Transformers, cfg.transformers); err != nil {
+		return err
+	}
+
+	// Validate OTTL conditions and value expressions exactly as the connector parses them
+	settings := component.TelemetrySettings{Logger: zap.NewNop()}
+	if _, err := compileOTTL(cfg, settings, nil); err != nil {
+		return err
+	}
+
+	// Validate log body templates
+	if _, err := compileBodyTemplates(cfg); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// validLogLevels are the accepted values of log_level and routing min_severity
+var validLogLevels = []string{"Trace", "Debug", "Info", "Warn", "Error", "Fatal"}
+
+func isValidLogLevel(level string) bool {
+	for _, valid := range validLogLevels {
+		if level == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultParallelThreshold is the default minimum batch size, in spans, for using Workers
+const defaultParallelThreshold = 1000
+
 var _ confmap.Unmarshaler = (*Config)(nil)
 
 // Unmarshal with custom logic to set default values
@@ -235,6 +240,56 @@ func (c *Config) Unmarshal(componentParser *confmap.Conf) error {
 	if !componentParser.IsSet("log_level") {
 		c.LogLevel = "Info"
 	}
+	if !componentParser.IsSet("conditions_match") {
+		c.ConditionsMatch = MatchAny
+	}
+	if !componentParser.IsSet("span_conditions_match") {
+		c.SpanConditionsMatch = MatchAny
+	}
+	if !componentParser.IsSet("event_conditions_match") {
+		c.EventConditionsMatch = MatchAny
+	}
+	if !componentParser.IsSet("error_mode") {
+		c.ErrorMode = ottl.IgnoreError
+	}
+	if !componentParser.IsSet("parallel_threshold") {
+		c.ParallelThreshold = defaultParallelThreshold
+	}
+	if !componentParser.IsSet("dedup::max_entries") {
+		c.Dedup.MaxEntries = defaultDedupMaxEntries
+	}
+	rateLimitDefaults := defaultRateLimitConfig()
+	if !componentParser.IsSet("rate_limit::key_attribute") {
+		c.RateLimit.KeyAttribute = rateLimitDefaults.KeyAttribute
+	}
+	if !componentParser.IsSet("rate_limit::overflow") {
+		c.RateLimit.Overflow = rateLimitDefaults.Overflow
+	}
+	if !componentParser.IsSet("rate_limit::sample_rate") {
+		c.RateLimit.SampleRate = rateLimitDefaults.SampleRate
+	}
+	if !componentParser.IsSet("rate_limit::marker_interval") {
+		c.RateLimit.MarkerInterval = rateLimitDefaults.MarkerInterval
+	}
+	if !componentParser.IsSet("rate_limit::max_keys") {
+		c.RateLimit.MaxKeys = rateLimitDefaults.MaxKeys
+	}
+	if !componentParser.IsSet("on_logs_error") {
+		c.OnLogsError = LogsErrorPropagate
+	}
+	retryDefaults := defaultRetryQueueConfig()
+	if !componentParser.IsSet("retry_queue::queue_size") {
+		c.RetryQueue.QueueSize = retryDefaults.QueueSize
+	}
+	if !componentParser.IsSet("retry_queue::initial_interval") {
+		c.RetryQueue.InitialInterval = retryDefaults.InitialInterval
+	}
+	if !componentParser.IsSet("retry_queue::max_interval") {
+		c.RetryQueue.MaxInterval = retryDefaults.MaxInterval
+	}
+	if !componentParser.IsSet("retry_queue::max_elapsed_time") {
+		c.RetryQueue.MaxElapsedTime = retryDefaults.MaxElapsedTime
+	}
 	if !componentParser.IsSet("log_body_template") {
 		c.LogBodyTemplate = "Span Event: {{.EventName}}"
 	}
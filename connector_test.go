@@ -0,0 +1,500 @@
+package spaneventstologconnector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"go.opentelemetry.io/collector/connector/connectortest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// newTestTraces builds one span named spanName carrying one event per name in eventNames.
+// Every event has exception.type and exception.message attributes.
+func newTestTraces(spanName string, eventNames ...string) ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "loadgenerator")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName(spanName)
+	span.SetTraceID(pcommon.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID(pcommon.SpanID{1, 2, 3, 4, 5, 6, 7, 8})
+	span.SetKind(ptrace.SpanKindClient)
+	span.Attributes().PutInt("http.status_code", 503)
+	for _, name := range eventNames {
+		event := span.Events().AppendEmpty()
+		event.SetName(name)
+		event.Attributes().PutStr("exception.type", "requests.exceptions.ConnectionError")
+		event.Attributes().PutStr("exception.message", "Connection refused")
+	}
+	return td
+}
+
+// newTestConnector creates a connector for cfg that sends its logs to a sink
+func newTestConnector(t *testing.T, cfg *Config) (*SpanEventConnector, *consumertest.LogsSink) {
+	t.Helper()
+	sink := new(consumertest.LogsSink)
+	conn, err := NewSpanEventConnector(connectortest.NewNopSettings(metadata.Type), cfg, sink)
+	if err != nil {
+		t.Fatalf("NewSpanEventConnector() error = %v", err)
+	}
+	return conn.(*SpanEventConnector), sink
+}
+
+// consumeTestTraces runs td through a new connector for cfg and returns the produced log records
+func consumeTestTraces(t *testing.T, cfg *Config, td ptrace.Traces) []plog.LogRecord {
+	t.Helper()
+	conn, sink := newTestConnector(t, cfg)
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	var records []plog.LogRecord
+	for _, logs := range sink.AllLogs() {
+		for i := 0; i < logs.ResourceLogs().Len(); i++ {
+			sls := logs.ResourceLogs().At(i).ScopeLogs()
+			for j := 0; j < sls.Len(); j++ {
+				lrs := sls.At(j).LogRecords()
+				for k := 0; k < lrs.Len(); k++ {
+					records = append(records, lrs.At(k))
+				}
+			}
+		}
+	}
+	return records
+}
+
+func TestConnector_BodyTemplates(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.BodyTemplates = map[string]BodyTemplateConfig{
+		"exception": {Template: `{{.SpanName}}: {{index .EventAttributes "exception.message"}}`},
+		"cart.*":    {Template: "cart event {{.EventName}}"},
+	}
+
+	records := consumeTestTraces(t, cfg, newTestTraces("GET /api/cart", "exception", "cart.item_added", "other"))
+	want := []string{"GET /api/cart: Connection refused", "cart event cart.item_added", "Span Event: other"}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, lr := range records {
+		if got := lr.Body().Str(); got != want[i] {
+			t.Errorf("record %d body = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestConnector_Expressions(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.BodyExpression = `Concat([span.name, ": ", attributes["exception.message"]], "")`
+	cfg.Attributes = map[string]string{
+		"error.kind":    `Substring(attributes["exception.type"], 0, 8)`,
+		"http.status":   `span.attributes["http.status_code"]`,
+		"missing.value": `attributes["does.not.exist"]`,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	records := consumeTestTraces(t, cfg, newTestTraces("GET /api/cart", "exception", "other"))
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	lr := records[0]
+	if got := lr.Body().Str(); got != "GET /api/cart: Connection refused" {
+		t.Errorf("body = %q", got)
+	}
+	if v, _ := lr.Attributes().Get("error.kind"); v.Str() != "requests" {
+		t.Errorf("error.kind = %q", v.Str())
+	}
+	if v, _ := lr.Attributes().Get("http.status"); v.Int() != 503 {
+		t.Errorf("http.status = %d", v.Int())
+	}
+	if _, ok := lr.Attributes().Get("missing.value"); ok {
+		t.Error("attribute for nil expression result should not be set")
+	}
+}
+
+func TestConnector_NilBodyExpression(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.BodyExpression = `attributes["does.not.exist"]`
+	cfg.LogBodyTemplate = "{{.SpanName}}: {{.EventName}}"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	records := consumeTestTraces(t, cfg, newTestTraces("GET /api/cart", "exception"))
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if got := records[0].Body().Str(); got != "GET /api/cart: exception" {
+		t.Errorf("body = %q, want the log_body_template fallback", got)
+	}
+}
+
+func TestConfig_InvalidExpressions(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.BodyExpression = `Concat([span.name`
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for invalid body_expression")
+	}
+
+	cfg.BodyExpression = ""
+	cfg.Attributes = map[string]string{"bad": `NoSuchFunction(name)`}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for invalid attributes expression")
+	}
+}
+
+func TestConnector_ConditionMatchModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		match     MatchMode
+		wantCount int
+	}{
+		{name: "any", match: MatchAny, wantCount: 2},
+		{name: "all", match: MatchAll, wantCount: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.EventConditions = []string{
+				`name == "exception"`,
+				`IsMatch(name, "^exc")`,
+				`attributes["exception.type"] == "requests.exceptions.ConnectionError"`,
+			}
+			cfg.EventConditionsMatch = tt.match
+			cfg.SpanConditions = []string{`name == "GET /api/cart"`, `attributes["http.status_code"] == 503`}
+			cfg.SpanConditionsMatch = MatchAll
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			td := newTestTraces("GET /api/cart", "exception", "exception.handled")
+			td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Events().At(1).Attributes().PutStr("exception.type", "other")
+			records := consumeTestTraces(t, cfg, td)
+			if len(records) != tt.wantCount {
+				t.Errorf("got %d records, want %d", len(records), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestConnector_ErrorModes(t *testing.T) {
+	for _, mode := range []ottl.ErrorMode{ottl.IgnoreError, ottl.SilentError, ottl.PropagateError} {
+		t.Run(string(mode), func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			// Substring fails at runtime because the range exceeds the event name
+			cfg.EventConditions = []string{`Substring(name, 100, 5) == "x"`, `name == "exception"`}
+			cfg.ErrorMode = mode
+
+			conn, sink := newTestConnector(t, cfg)
+			err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception"))
+			if mode == ottl.PropagateError {
+				if err == nil {
+					t.Fatal("ConsumeTraces() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+			if sink.LogRecordCount() != 1 {
+				t.Errorf("got %d records, want 1", sink.LogRecordCount())
+			}
+		})
+	}
+}
+
+func TestConfig_ConditionOptions(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.EventConditionsMatch = "most"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for invalid event_conditions_match")
+	}
+
+	cfg.EventConditionsMatch = MatchAll
+	cfg.ErrorMode = "explode"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for invalid error_mode")
+	}
+
+	// Statements are not conditions: validation must reject what the connector cannot run
+	cfg.ErrorMode = ottl.IgnoreError
+	cfg.EventConditions = []string{`set(attributes["x"], "y")`}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for an editor statement")
+	}
+}
+
+func TestConnector_ContextInferredConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []string
+		match      MatchMode
+		wantCount  int
+	}{
+		{
+			name:       "all_levels_match",
+			conditions: []string{`resource.attributes["service.name"] == "loadgenerator"`, `span.name == "GET /api/cart"`, `spanevent.name == "exception"`},
+			match:      MatchAll,
+			wantCount:  1,
+		},
+		{
+			name:       "resource_rejects_subtree",
+			conditions: []string{`resource.attributes["service.name"] == "frontend"`, `spanevent.name == "exception"`},
+			match:      MatchAll,
+			wantCount:  0,
+		},
+		{
+			name:       "resource_matches_subtree",
+			conditions: []string{`resource.attributes["service.name"] == "loadgenerator"`, `spanevent.name == "exception"`},
+			match:      MatchAny,
+			wantCount:  2,
+		},
+		{
+			name:       "event_level_any",
+			conditions: []string{`scope.name == "nope"`, `spanevent.name == "exception"`},
+			match:      MatchAny,
+			wantCount:  1,
+		},
+		{
+			name:       "mixed_contexts_in_one_condition",
+			conditions: []string{`resource.attributes["service.name"] == "loadgenerator" and spanevent.name == "checkout"`},
+			match:      MatchAll,
+			wantCount:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Conditions = tt.conditions
+			cfg.ConditionsMatch = tt.match
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			records := consumeTestTraces(t, cfg, newTestTraces("GET /api/cart", "exception", "checkout"))
+			if len(records) != tt.wantCount {
+				t.Errorf("got %d records, want %d", len(records), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestConfig_ConditionPathContexts(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+
+	// span_conditions and event_conditions accept both prefixed and legacy paths
+	cfg.SpanConditions = []string{`span.name == "GET"`, `IsMatch(name, "GET")`, `status.code == STATUS_CODE_ERROR`}
+	cfg.EventConditions = []string{`spanevent.name == "exception"`, `span.name == "GET" and name == "exception"`}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	// conditions require an explicit context prefix
+	cfg.Conditions = []string{`name == "exception"`}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for a condition without path context")
+	}
+}
+
+func TestConnector_WorkersMatchSequential(t *testing.T) {
+	td := ptrace.NewTraces()
+	for i := 0; i < 50; i++ {
+		events := []string{"exception", "checkout", "retry"}[:i%3+1]
+		batch := newTestTraces(fmt.Sprintf("span-%d", i), events...)
+		batch.ResourceSpans().At(0).Resource().Attributes().PutInt("index", int64(i))
+		batch.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	}
+
+	convert := func(workers int) []byte {
+		cfg := createDefaultConfig().(*Config)
+		cfg.EventConditions = []string{`name != "retry"`}
+		cfg.Workers = workers
+		cfg.ParallelThreshold = 10
+		conn, sink := newTestConnector(t, cfg)
+		if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+			t.Fatalf("ConsumeTraces() error = %v", err)
+		}
+		if len(sink.AllLogs()) != 1 {
+			t.Fatalf("got %d batches, want 1", len(sink.AllLogs()))
+		}
+		data, err := (&plog.JSONMarshaler{}).MarshalLogs(sink.AllLogs()[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	sequential := convert(0)
+	for _, workers := range []int{2, 8, 64} {
+		if parallel := convert(workers); !bytes.Equal(parallel, sequential) {
+			t.Errorf("workers=%d produced different logs than sequential conversion", workers)
+		}
+	}
+}
+
+func TestConfig_Workers(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.Workers = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for negative workers")
+	}
+	cfg.Workers = 4
+	cfg.ParallelThreshold = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for negative parallel_threshold")
+	}
+}
+
+func BenchmarkConsumeTraces_Workers(b *testing.B) {
+	// Merge the corpus into one large batch, as a batch processor would
+	td := ptrace.NewTraces()
+	for _, batch := range loadRealisticTraces(b, 1000) {
+		batch.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	}
+	for _, workers := range []int{0, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.SpanConditions = []string{`IsMatch(name, "hipster-shop")`}
+			cfg.Workers = workers
+			conn, err := NewSpanEventConnector(connectortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestConnector_MoveData(t *testing.T) {
+	convert := func(move bool) (ptrace.Traces, []plog.LogRecord, consumer.Capabilities) {
+		cfg := createDefaultConfig().(*Config)
+		cfg.EventConditions = []string{`name == "exception"`}
+		cfg.BodyTemplates = map[string]BodyTemplateConfig{"exception": {Template: `{{index .EventAttributes "exception.message"}}`}}
+		cfg.Attributes = map[string]string{
+			"event.exception.type": `"overridden"`,
+			"root_cause":           `ExceptionRootCause()`,
+		}
+		cfg.MoveData = move
+		conn, _ := newTestConnector(t, cfg)
+		td := newTestTraces("GET /api/cart", "exception", "other")
+		td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Events().At(0).Attributes().PutEmptyMap("payload").PutStr("id", "42")
+		return td, consumeTestTraces(t, cfg, td), conn.Capabilities()
+	}
+
+	_, copied, copyCaps := convert(false)
+	td, moved, moveCaps := convert(true)
+	if copyCaps.MutatesData || !moveCaps.MutatesData {
+		t.Errorf("MutatesData = %v (copy), %v (move)", copyCaps.MutatesData, moveCaps.MutatesData)
+	}
+	if len(copied) != 1 || len(moved) != 1 {
+		t.Fatalf("got %d copied and %d moved records, want 1 each", len(copied), len(moved))
+	}
+	if !copied[0].Attributes().Equal(moved[0].Attributes()) || copied[0].Body().Str() != moved[0].Body().Str() {
+		t.Errorf("moved record %v differs from copied record %v", moved[0].Attributes().AsRaw(), copied[0].Attributes().AsRaw())
+	}
+	if v, _ := moved[0].Attributes().Get("event.exception.type"); v.Str() != "overridden" {
+		t.Errorf("event.exception.type = %q, want expression result", v.Str())
+	}
+
+	events := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Events()
+	if events.At(0).Attributes().Len() != 0 {
+		t.Errorf("converted event still has %d attributes", events.At(0).Attributes().Len())
+	}
+	if events.At(1).Attributes().Len() == 0 {
+		t.Error("event that was not converted lost its attributes")
+	}
+}
+
+func TestConnector_MoveDataKeepsDroppedEvents(t *testing.T) {
+	// The transformer raises exceptions to Error and drops retries
+	transformer := funcTransformer{name: "triage", fn: func(event EventContext, record plog.LogRecord) (bool, error) {
+		switch event.Event.Name() {
+		case "exception":
+			record.SetSeverityNumber(plog.SeverityNumberError)
+		case "retry":
+			return false, nil
+		}
+		return true, nil
+	}}
+	tests := []struct {
+		name     string
+		routing  RoutingConfig
+		produced map[string]bool
+	}{
+		{name: "transformer", produced: map[string]bool{"exception": true, "checkout": true}},
+		// Only Error records are routed, so checkout is not produced
+		{
+			name:     "min_severity",
+			routing:  RoutingConfig{Routes: []RouteConfig{{MinSeverity: "Error", Pipelines: []pipeline.ID{auditPipeline}}}},
+			produced: map[string]bool{"exception": true},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewFactoryWithOptions(WithTransformers(transformer)).CreateDefaultConfig().(*Config)
+			cfg.EventConditions = []string{`name != "health"`}
+			cfg.Transformers = []string{"triage"}
+			cfg.MoveData = true
+			cfg.Routing = tc.routing
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			router, sinks := newRoutingTestSinks()
+			var next consumer.Logs = router
+			if len(tc.routing.Routes) == 0 {
+				next = sinks[defaultPipeline]
+			}
+			conn, _ := newTelemetryTestConnector(t, cfg, next)
+			td := newTestTraces("GET /api/cart", "exception", "retry", "checkout")
+			if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+
+			events := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Events()
+			for i := 0; i < events.Len(); i++ {
+				event := events.At(i)
+				if moved := event.Attributes().Len() == 0; moved != tc.produced[event.Name()] {
+					t.Errorf("%s: attributes moved = %v, want %v", event.Name(), moved, tc.produced[event.Name()])
+				}
+			}
+			records := 0
+			for _, sink := range sinks {
+				for _, logs := range sink.AllLogs() {
+					for i := 0; i < logs.ResourceLogs().Len(); i++ {
+						lr := logs.ResourceLogs().At(i).ScopeLogs().At(0).LogRecords().At(0)
+						if _, ok := lr.Attributes().Get("event.exception.type"); !ok {
+							t.Errorf("record of %s has no event attributes", lr.Attributes().AsRaw()["event.name"])
+						}
+						records++
+					}
+				}
+			}
+			if records != len(tc.produced) {
+				t.Errorf("got %d records, want %d", records, len(tc.produced))
+			}
+		})
+	}
+}
@@ -0,0 +1,780 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// Converter converts span events into log records outside the collector. It is what the
+// SpanEventConnector runs for every batch, so it produces the same logs as the connector
+// with the same configuration. A Converter is safe for concurrent use.
+type Converter struct {
+	config        *Config
+	logger        *zap.Logger
+	bodyTemplates *bodyTemplateSet
+	spanOttl      *conditionMatcher[ottlspan.TransformContext]
+	eventOttl     *conditionMatcher[ottlspanevent.TransformContext]
+	conditions    *contextConditions
+	expressions   *logExpressions
+	telemetry     *connectorTelemetry
+	// router picks the output of every log record; it is nil without routing
+	router *logsRouter
+	// tenant is nil when no tenant keys are configured
+	tenant *tenantStamper
+	// dedup is only set when dedup.window is positive
+	dedup *dedupCache
+	// limiter is nil when no rate limit is configured
+	limiter *rateLimiter
+	// transformers is nil when no transformers are configured
+	transformers *transformerChain
+}
+
+// Option configures a Converter
+type Option func(options *converterOptions)
+
+type converterOptions struct {
+	logger             *zap.Logger
+	meterProvider      metric.MeterProvider
+	spanFunctions      []ottl.Factory[ottlspan.TransformContext]
+	spanEventFunctions []ottl.Factory[ottlspanevent.TransformContext]
+	transformers       []EventTransformer
+}
+
+// WithLogger sets the logger for condition, expression and template errors. They are
+// discarded by default.
+func WithLogger(logger *zap.Logger) Option {
+	return func(options *converterOptions) {
+		options.logger = logger
+	}
+}
+
+// WithMeterProvider records the connector's internal metrics, such as
+// otelcol_connector_spaneventstolog_events_matched, with meterProvider. No metrics are recorded by default.
+func WithMeterProvider(meterProvider metric.MeterProvider) Option {
+	return func(options *converterOptions) {
+		options.meterProvider = meterProvider
+	}
+}
+
+// WithOTTLSpanFunctions adds OTTL functions to the span context, like WithSpanFunctions
+// does for the factory
+func WithOTTLSpanFunctions(functions []ottl.Factory[ottlspan.TransformContext]) Option {
+	return func(options *converterOptions) {
+		options.spanFunctions = append(options.spanFunctions, functions...)
+	}
+}
+
+// WithOTTLSpanEventFunctions adds OTTL functions to the spanevent context, like
+// WithSpanEventFunctions does for the factory
+func WithOTTLSpanEventFunctions(functions []ottl.Factory[ottlspanevent.TransformContext]) Option {
+	return func(options *converterOptions) {
+		options.spanEventFunctions = append(options.spanEventFunctions, functions...)
+	}
+}
+
+// WithEventTransformers registers event transformers, like WithTransformers does for the
+// factory. The transformers option of the configuration enables them.
+func WithEventTransformers(transformers ...EventTransformer) Option {
+	return func(options *converterOptions) {
+		options.transformers = append(options.transformers, transformers...)
+	}
+}
+
+// NewConverter validates cfg and compiles its conditions, expressions and templates.
+// cfg usually comes from NewFactory().CreateDefaultConfig(), so unset fields have their
+// defaults. cfg is copied; later changes to it do not affect the Converter.
+func NewConverter(cfg *Config, opts ...Option) (*Converter, error) {
+	options := converterOptions{
+		logger:        zap.NewNop(),
+		meterProvider: metricnoop.NewMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	config := *cfg
+	if config.spanFunctions == nil {
+		config.spanFunctions = defaultSpanFunctions()
+	}
+	config.spanFunctions = mergeFunctions(config.spanFunctions, options.spanFunctions...)
+	if config.spanEventFunctions == nil {
+		config.spanEventFunctions = defaultSpanEventFunctions()
+	}
+	config.spanEventFunctions = mergeFunctions(config.spanEventFunctions, options.spanEventFunctions...)
+	config.transformers = mergeTransformers(config.transformers, options.transformers...)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return newConverter(&config, component.TelemetrySettings{
+		Logger:         options.logger,
+		TracerProvider: tracenoop.NewTracerProvider(),
+		MeterProvider:  options.meterProvider,
+		Resource:       pcommon.NewResource(),
+	})
+}
+
+// newConverter builds the Converter of cfg without validating it, as the collector
+// validates configurations before creating components
+func newConverter(cfg *Config, settings component.TelemetrySettings) (*Converter, error) {
+	// Parse log body templates
+	bodyTemplates, err := compileBodyTemplates(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse log body templates: %w", err)
+	}
+
+	telemetryBuilder, err := metadata.NewTelemetryBuilder(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry: %w", err)
+	}
+
+	// Parse OTTL conditions and value expressions
+	compiled, err := compileOTTL(cfg, settings, telemetryBuilder)
+	if err != nil {
+		return nil, err
+	}
+
+	transformers, err := newTransformerChain(cfg.Transformers, cfg.transformers, settings.Logger, telemetryBuilder.ConnectorSpaneventstologTransformerErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	var dedup *dedupCache
+	if cfg.Dedup.Window > 0 {
+		dedup = newDedupCache(cfg.Dedup)
+	}
+
+	telemetry := newConnectorTelemetry(telemetryBuilder, cfg.Telemetry)
+	return &Converter{
+		config:        cfg,
+		logger:        settings.Logger,
+		bodyTemplates: bodyTemplates,
+		spanOttl:      compiled.spanConditions,
+		eventOttl:     compiled.eventConditions,
+		conditions:    compiled.conditions,
+		expressions:   compiled.expressions,
+		telemetry:     telemetry,
+		router:        newLogsRouter(&cfg.Routing, compiled.routeConditions),
+		tenant:        newTenantStamper(cfg.Tenant),
+		dedup:         dedup,
+		limiter:       newRateLimiter(cfg.RateLimit, telemetry),
+		transformers:  transformers,
+	}, nil
+}
+
+// Stats counts what one Convert call did
+type Stats struct {
+	// SpansEvaluated and EventsEvaluated count the spans and span events of the input
+	SpansEvaluated  int64
+	EventsEvaluated int64
+	// SpansMatched counts the spans that passed the span-level conditions
+	SpansMatched int64
+	// EventsMatched counts the span events converted into log records
+	EventsMatched int64
+	// EventsDropped counts the span events the conditions rejected, no route accepted or
+	// a transformer dropped
+	EventsDropped int64
+	// DuplicatesSuppressed counts the matching span events dedup suppressed
+	DuplicatesSuppressed int64
+	// RateLimited counts the log records the rate limits suppressed
+	RateLimited int64
+	// TemplateErrors, ExpressionErrors and TransformerErrors count failed body templates,
+	// OTTL expressions and event transformer calls
+	TemplateErrors    int64
+	ExpressionErrors  int64
+	TransformerErrors int64
+	// LogRecords counts the produced log records, including rate limit markers
+	LogRecords int
+}
+
+func (s *Stats) add(other *Stats) {
+	s.SpansEvaluated += other.SpansEvaluated
+	s.EventsEvaluated += other.EventsEvaluated
+	s.SpansMatched += other.SpansMatched
+	s.EventsMatched += other.EventsMatched
+	s.EventsDropped += other.EventsDropped
+	s.DuplicatesSuppressed += other.DuplicatesSuppressed
+	s.RateLimited += other.RateLimited
+	s.TemplateErrors += other.TemplateErrors
+	s.ExpressionErrors += other.ExpressionErrors
+	s.TransformerErrors += other.TransformerErrors
+	s.LogRecords += other.LogRecords
+}
+
+// addTo adds the stats of one ResourceSpans to total
+func (s *conversionStats) addTo(total *Stats) {
+	total.SpansEvaluated += s.spansEvaluated
+	total.EventsEvaluated += s.eventsEvaluated
+	total.SpansMatched += s.spansMatched
+	total.EventsMatched += s.eventsMatched
+	for _, n := range s.eventsDropped {
+		total.EventsDropped += n
+	}
+	total.DuplicatesSuppressed += s.duplicatesSuppressed
+	for _, n := range s.rateLimited {
+		total.RateLimited += n
+	}
+	total.TemplateErrors += s.templateErrors
+	total.ExpressionErrors += s.expressionErrors
+	total.TransformerErrors += s.transformerErrors
+}
+
+// Convert converts the matching span events of td into log records. With move_data, the
+// event attributes of converted events are moved out of td. With routing, the records of
+// all routes are returned together, ordered by route; records that match no route are only
+// returned when default pipelines are configured.
+func (c *Converter) Convert(ctx context.Context, td ptrace.Traces) (plog.Logs, Stats, error) {
+	out, pending, stats, err := c.convert(ctx, td)
+	if err != nil {
+		return plog.NewLogs(), stats, err
+	}
+	// The records are handed to the caller, so they count as emitted
+	for i := range out {
+		c.remember(pending, i, nil, 0)
+	}
+	logs := out[0]
+	for _, other := range out[1:] {
+		other.ResourceLogs().MoveAndAppendTo(logs.ResourceLogs())
+	}
+	return logs, stats, nil
+}
+
+// convert converts td into one plog.Logs per output of the router. The UIDs of the
+// produced records are returned separately, to be remembered by dedup once delivered.
+func (c *Converter) convert(ctx context.Context, td ptrace.Traces) (routedLogs, *pendingUIDs, Stats, error) {
+	start := time.Now()
+	var out routedLogs
+	var pending *pendingUIDs
+	var stats Stats
+	var err error
+	if c.useWorkers(td) {
+		out, pending, err = c.convertParallel(ctx, td, &stats)
+	} else {
+		out = newRoutedLogs(c.router.outputs())
+		pending = newPendingUIDs(c.dedup, c.router.outputs())
+		resourceSpansSlice := td.ResourceSpans()
+		for i := 0; i < resourceSpansSlice.Len() && err == nil; i++ {
+			err = c.convertResourceSpans(ctx, resourceSpansSlice.At(i), out, pending, &stats)
+		}
+	}
+	c.telemetry.recordDuration(ctx, time.Since(start))
+	if err != nil {
+		return nil, nil, stats, err
+	}
+	for _, logs := range out {
+		stats.LogRecords += logs.LogRecordCount()
+	}
+	return out, pending, stats, nil
+}
+
+// remember records the UIDs of the records of output that were delivered as emitted.
+// parts and part select the records of one tenant, as for pendingUIDs.part.
+func (c *Converter) remember(pending *pendingUIDs, output int, parts []int, part int) {
+	if c.dedup != nil {
+		c.dedup.remember(pending.part(output, parts, part))
+	}
+}
+
+// useWorkers reports whether td is large enough to be converted by the worker pool
+func (c *Converter) useWorkers(td ptrace.Traces) bool {
+	return c.config.Workers > 1 && td.ResourceSpans().Len() > 1 && td.SpanCount() >= c.config.ParallelThreshold
+}
+
+// convertParallel converts td with up to Workers goroutines. Each ResourceSpans is
+// converted into its own shard, and the shards are merged in input order so the
+// result is identical to a sequential conversion.
+func (c *Converter) convertParallel(ctx context.Context, td ptrace.Traces, stats *Stats) (routedLogs, *pendingUIDs, error) {
+	resourceSpansSlice := td.ResourceSpans()
+	n := resourceSpansSlice.Len()
+	type shard struct {
+		out     routedLogs
+		pending *pendingUIDs
+		stats   Stats
+		err     error
+	}
+	shards := make([]shard, n)
+
+	workers := min(c.config.Workers, n)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				s := &shards[i]
+				s.out = newRoutedLogs(c.router.outputs())
+				s.pending = newPendingUIDs(c.dedup, c.router.outputs())
+				s.err = c.convertResourceSpans(ctx, resourceSpansSlice.At(i), s.out, s.pending, &s.stats)
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := newRoutedLogs(c.router.outputs())
+	pending := newPendingUIDs(c.dedup, c.router.outputs())
+	for i := range shards {
+		stats.add(&shards[i].stats)
+		// Report the error of the first failing ResourceSpans, like the sequential path
+		if shards[i].err != nil {
+			return nil, nil, shards[i].err
+		}
+		shards[i].out.moveAndAppendTo(out)
+		shards[i].pending.moveAndAppendTo(pending)
+	}
+	return out, pending, nil
+}
+
+// convertResourceSpans converts the matching span events of one ResourceSpans into logs
+// and records what happened on the connector's metrics and in total.
+func (c *Converter) convertResourceSpans(ctx context.Context, resourceSpans ptrace.ResourceSpans, out routedLogs, pending *pendingUIDs, total *Stats) error {
+	stats := c.telemetry.newStats()
+	err := c.convertResourceSpansWithStats(ctx, resourceSpans, out, pending, &stats)
+	c.telemetry.record(ctx, resourceSpans.Resource(), &stats)
+	stats.addTo(total)
+	return err
+}
+
+func (c *Converter) convertResourceSpansWithStats(ctx context.Context, resourceSpans ptrace.ResourceSpans, out routedLogs, pending *pendingUIDs, stats *conversionStats) error {
+	resource := resourceSpans.Resource()
+	// All records of a ResourceSpans share the rate limit of its resource
+	var limit *rateLimitEntry
+	if c.limiter != nil {
+		limit = c.limiter.entry(ctx, resource)
+		stats.rateLimitKey = limit.key
+	}
+	err := c.matchResourceSpans(ctx, resourceSpans, stats, nil, func(scopeSpans ptrace.ScopeSpans, span ptrace.Span, l int, event ptrace.SpanEvent) error {
+		scope := scopeSpans.Scope()
+		bodyTemplate := c.bodyTemplates.lookup(event.Name())
+		rule := bodyTemplate.ruleName()
+		var uid string
+		if c.config.LogRecordUID || c.dedup != nil {
+			uid = logRecordUID(span, l, event, rule)
+			if c.dedup != nil && pending.duplicate(c.dedup, uid) {
+				stats.duplicatesSuppressed++
+				return nil
+			}
+		}
+		target := nativeTarget{resource: resource, span: span, event: event}
+		newTCtx := func() ottlspanevent.TransformContext {
+			return ottlspanevent.NewTransformContext(event, span, scope, resource, scopeSpans, resourceSpans)
+		}
+		// Routes by severity need the built record, which transformers may have
+		// changed. Otherwise the output is picked first.
+		output, exceeded := -1, rateLimitNone
+		var err error
+		if !c.router.bySeverity() {
+			if output, exceeded, err = c.pickOutput(ctx, rule, plog.SeverityNumberUnspecified, target, newTCtx, limit, stats); err != nil {
+				return err
+			}
+			if output < 0 {
+				return nil
+			}
+		}
+		// Under move_data the event attributes are only moved when the record is certain to
+		// be produced. Otherwise they are copied and removed once it is, so that events a
+		// route, rate limit or transformer drops keep them.
+		move := c.config.MoveData && output >= 0 && c.transformers == nil
+		logRecord, ok := c.buildLogRecord(ctx, event, span, resource, scope, scopeSpans, resourceSpans, bodyTemplate, uid, move, stats)
+		if !ok {
+			stats.eventsDropped[dropTransformer]++
+			return nil
+		}
+		if output < 0 {
+			if output, exceeded, err = c.pickOutput(ctx, rule, logRecord.SeverityNumber(), target, newTCtx, limit, stats); err != nil {
+				return err
+			}
+			if output < 0 {
+				return nil
+			}
+		}
+		if limit != nil {
+			c.limiter.charge(limit, logRecord, exceeded)
+		}
+		logRecord.MoveTo(c.appendLogRecord(ctx, out[output], resource, scope))
+		if c.config.MoveData && !move && c.config.IncludeEventAttributes {
+			event.Attributes().Clear()
+		}
+		pending.add(output, uid)
+		stats.eventsMatched++
+		if stats.matchedByRule != nil {
+			stats.matchedByRule[rule]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if limit != nil {
+		if c.limiter.needsMarkerResource(limit) {
+			// The marker carries the resource of the first records it reports
+			markerResource := pcommon.NewResource()
+			resource.CopyTo(markerResource)
+			c.tenant.stamp(ctx, resource, markerResource)
+			c.limiter.setMarkerResource(limit, markerResource)
+		}
+		if marker, ok := c.limiter.takeMarker(limit, false); ok {
+			appendRateLimitMarker(out[marker.output], marker, c.config.RateLimit.KeyAttribute, time.Now())
+			pending.add(marker.output, "")
+		}
+	}
+	return nil
+}
+
+// Match calls span for every span of td that passes the resource, scope and span
+// conditions, and event for every span event that passes all conditions, without
+// converting anything. It evaluates conditions, span_conditions and event_conditions with
+// their match modes as Convert does. Either function may be nil.
+func (c *Converter) Match(
+	ctx context.Context,
+	td ptrace.Traces,
+	span func(ptrace.ResourceSpans, ptrace.Span),
+	event func(ptrace.ResourceSpans, ptrace.Span, ptrace.SpanEvent),
+) error {
+	stats := c.telemetry.newStats()
+	resourceSpansSlice := td.ResourceSpans()
+	for i := 0; i < resourceSpansSlice.Len(); i++ {
+		resourceSpans := resourceSpansSlice.At(i)
+		var onSpan func(ptrace.ScopeSpans, ptrace.Span)
+		if span != nil {
+			onSpan = func(_ ptrace.ScopeSpans, s ptrace.Span) { span(resourceSpans, s) }
+		}
+		onEvent := func(_ ptrace.ScopeSpans, s ptrace.Span, _ int, e ptrace.SpanEvent) error {
+			if event != nil {
+				event(resourceSpans, s, e)
+			}
+			return nil
+		}
+		if err := c.matchResourceSpans(ctx, resourceSpans, &stats, onSpan, onEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchResourceSpans evaluates the conditions on resourceSpans, calls onSpan, which may be
+// nil, for every span that passes the span-level conditions and onEvent for every span
+// event that passes all of them. Rejected spans and events are counted in stats.
+func (c *Converter) matchResourceSpans(
+	ctx context.Context,
+	resourceSpans ptrace.ResourceSpans,
+	stats *conversionStats,
+	onSpan func(ptrace.ScopeSpans, ptrace.Span),
+	onEvent func(ptrace.ScopeSpans, ptrace.Span, int, ptrace.SpanEvent) error,
+) error {
+	resource := resourceSpans.Resource()
+	resourceResult, err := c.conditions.evalResource(ctx, resourceSpans)
+	if err != nil {
+		return err
+	}
+	scopeSpansSlice := resourceSpans.ScopeSpans()
+	for j := 0; j < scopeSpansSlice.Len(); j++ {
+		scopeSpans := scopeSpansSlice.At(j)
+		scope := scopeSpans.Scope()
+		scopeResult := resourceResult
+		if scopeResult == conditionsUndecided {
+			if scopeResult, err = c.conditions.evalScope(ctx, resourceSpans, scopeSpans); err != nil {
+				return err
+			}
+		}
+		spansSlice := scopeSpans.Spans()
+		for k := 0; k < spansSlice.Len(); k++ {
+			span := spansSlice.At(k)
+			events := span.Events()
+			stats.spansEvaluated++
+			stats.eventsEvaluated += int64(events.Len())
+
+			// Rejected subtrees are only walked to count their spans and events
+			spanResult := scopeResult
+			if spanResult == conditionsUndecided {
+				if spanResult, err = c.conditions.evalSpan(ctx, span, resourceSpans, scopeSpans); err != nil {
+					return err
+				}
+			}
+			if spanResult == conditionsRejected {
+				stats.dropSpan(events.Len(), dropConditions)
+				continue
+			}
+			spanMatch, err := c.matchesSpanConditionsWithContext(ctx, span, resource, scope, scopeSpans, resourceSpans)
+			if err != nil {
+				return err
+			}
+			if !spanMatch {
+				stats.dropSpan(events.Len(), dropSpanConditions)
+				continue
+			}
+			stats.spansMatched++
+			if onSpan != nil {
+				onSpan(scopeSpans, span)
+			}
+			for l := 0; l < events.Len(); l++ {
+				event := events.At(l)
+				eventMatch := c.conditions.resolve(spanResult)
+				if spanResult == conditionsUndecided {
+					if eventMatch, err = c.conditions.evalEvent(ctx, event, span, resourceSpans, scopeSpans); err != nil {
+						return err
+					}
+				}
+				if !eventMatch {
+					stats.eventsDropped[dropConditions]++
+					continue
+				}
+				eventMatch, err = c.matchesEventConditionsWithContext(ctx, event, span, resource, scope, scopeSpans, resourceSpans)
+				if err != nil {
+					return err
+				}
+				if !eventMatch {
+					stats.eventsDropped[dropEventConditions]++
+					continue
+				}
+				if err := onEvent(scopeSpans, span, l, event); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesSpanConditionsWithContext evaluates the span conditions. An error is only
+// returned when error_mode is propagate.
+func (c *Converter) matchesSpanConditionsWithContext(ctx context.Context, span ptrace.Span, resource pcommon.Resource, scope pcommon.InstrumentationScope, scopeSpans ptrace.ScopeSpans, resourceSpans ptrace.ResourceSpans) (bool, error) {
+	if c.spanOttl == nil {
+		return true, nil
+	}
+	return c.spanOttl.eval(ctx, nativeTarget{resource: resource, span: span}, func() ottlspan.TransformContext {
+		return ottlspan.NewTransformContext(span, scope, resource, scopeSpans, resourceSpans)
+	})
+}
+
+// matchesEventConditionsWithContext evaluates the event conditions. An error is only
+// returned when error_mode is propagate.
+func (c *Converter) matchesEventConditionsWithContext(ctx context.Context, event ptrace.SpanEvent, span ptrace.Span, resource pcommon.Resource, scope pcommon.InstrumentationScope, scopeSpans ptrace.ScopeSpans, resourceSpans ptrace.ResourceSpans) (bool, error) {
+	if c.eventOttl == nil {
+		return true, nil
+	}
+	return c.eventOttl.eval(ctx, nativeTarget{resource: resource, span: span, event: event}, func() ottlspanevent.TransformContext {
+		return ottlspanevent.NewTransformContext(event, span, scope, resource, scopeSpans, resourceSpans)
+	})
+}
+
+// pickOutput returns the output of a record with severity, or -1 when it is not produced
+// because no route matched or its key exceeded the rate limit. Routes that do not
+// filter by severity ignore it. The limit a sampled record exceeded is returned for charge.
+func (c *Converter) pickOutput(
+	ctx context.Context,
+	rule string,
+	severity plog.SeverityNumber,
+	target nativeTarget,
+	newTCtx func() ottlspanevent.TransformContext,
+	limit *rateLimitEntry,
+	stats *conversionStats,
+) (int, rateLimitKind, error) {
+	output, err := c.router.route(ctx, rule, severity, target, newTCtx)
+	if err != nil {
+		return -1, rateLimitNone, err
+	}
+	if output < 0 {
+		stats.eventsDropped[dropUnrouted]++
+		return -1, rateLimitNone, nil
+	}
+	if limit == nil {
+		return output, rateLimitNone, nil
+	}
+	produce, exceeded := c.limiter.allow(limit, output)
+	if !produce {
+		stats.rateLimited[exceeded]++
+		return -1, exceeded, nil
+	}
+	return output, exceeded, nil
+}
+
+// buildLogRecord drafts the log record for event outside of any plog.Logs, so a record
+// that is not produced leaves nothing behind. It returns false when a transformer dropped
+// the record. uid is the log.record.uid, which is only added when log_record_uid is enabled.
+func (c *Converter) buildLogRecord(
+	ctx context.Context,
+	event ptrace.SpanEvent,
+	span ptrace.Span,
+	resource pcommon.Resource,
+	scope pcommon.InstrumentationScope,
+	scopeSpans ptrace.ScopeSpans,
+	resourceSpans ptrace.ResourceSpans,
+	bodyTemplate *bodyTemplate,
+	uid string,
+	move bool,
+	stats *conversionStats,
+) (plog.LogRecord, bool) {
+	logRecord := plog.NewLogRecord()
+	c.fillLogRecord(ctx, logRecord, event, span, resource, scope, scopeSpans, resourceSpans, bodyTemplate, uid, move, stats)
+	if c.transformers != nil {
+		eventContext := EventContext{Event: event, Span: span, Scope: scope, Resource: resource, Rule: bodyTemplate.ruleName()}
+		if !c.transformers.transform(ctx, eventContext, logRecord, stats) {
+			return plog.LogRecord{}, false
+		}
+	}
+	return logRecord, true
+}
+
+// appendLogRecord appends an empty log record with its own resource and scope to logs
+func (c *Converter) appendLogRecord(ctx context.Context, logs plog.Logs, resource pcommon.Resource, scope pcommon.InstrumentationScope) plog.LogRecord {
+	rl := logs.ResourceLogs().AppendEmpty()
+	resource.CopyTo(rl.Resource())
+	c.tenant.stamp(ctx, resource, rl.Resource())
+
+	sl := rl.ScopeLogs().AppendEmpty()
+	scope.CopyTo(sl.Scope())
+
+	return sl.LogRecords().AppendEmpty()
+}
+
+// fillLogRecord sets the fields of the log record for event. With move, the event
+// attributes are moved into the record instead of copied.
+func (c *Converter) fillLogRecord(
+	ctx context.Context,
+	logRecord plog.LogRecord,
+	event ptrace.SpanEvent,
+	span ptrace.Span,
+	resource pcommon.Resource,
+	scope pcommon.InstrumentationScope,
+	scopeSpans ptrace.ScopeSpans,
+	resourceSpans ptrace.ResourceSpans,
+	bodyTemplate *bodyTemplate,
+	uid string,
+	move bool,
+	stats *conversionStats,
+) {
+	// Set basic log record fields
+	logRecord.SetTimestamp(event.Timestamp())
+	logRecord.SetSeverityText(c.config.LogLevel)
+	logRecord.SetSeverityNumber(severityNumber(c.config.LogLevel))
+
+	var tCtx ottlspanevent.TransformContext
+	if c.expressions != nil {
+		tCtx = ottlspanevent.NewTransformContext(event, span, scope, resource, scopeSpans, resourceSpans)
+	}
+
+	// Set the log body from body_expression, falling back to the templates
+	ok, err := c.expressions.evalBody(ctx, tCtx, logRecord.Body())
+	if err != nil {
+		stats.expressionErrors++
+		c.logger.Error("Failed to execute log body expression", zap.Error(err))
+	}
+	if !ok {
+		logRecord.Body().SetStr(c.generateLogBody(bodyTemplate, event, span, stats))
+	}
+
+	// Add trace context
+	logRecord.SetTraceID(span.TraceID())
+	logRecord.SetSpanID(span.SpanID())
+	logRecord.SetFlags(0) // Simplified: don't use trace state
+
+	// Add basic attributes
+	attrs := logRecord.Attributes()
+	attrs.PutStr("span.name", span.Name())
+	attrs.PutStr("span.kind", span.Kind().String())
+	attrs.PutStr("event.name", event.Name())
+	if c.config.LogRecordUID {
+		attrs.PutStr(logRecordUIDAttribute, uid)
+	}
+
+	// Include span attributes if configured
+	if c.config.IncludeSpanAttributes {
+		span.Attributes().Range(func(k string, v pcommon.Value) bool {
+			v.CopyTo(attrs.PutEmpty("span." + k))
+			return true
+		})
+	}
+
+	if move {
+		// Expressions must read the event attributes before they are moved; moved
+		// attributes skip the keys set by expressions so that those still win
+		if err := c.expressions.evalAttributes(ctx, tCtx, attrs); err != nil {
+			stats.expressionErrors += errorCount(err)
+			c.logger.Error("Failed to execute log attribute expressions", zap.Error(err))
+		}
+		if c.config.IncludeEventAttributes {
+			event.Attributes().Range(func(k string, v pcommon.Value) bool {
+				if key := "event." + k; !c.expressions.setsAttribute(key) {
+					v.MoveTo(attrs.PutEmpty(key))
+				}
+				return true
+			})
+			event.Attributes().Clear()
+		}
+		return
+	}
+
+	// Include event attributes if configured
+	if c.config.IncludeEventAttributes {
+		event.Attributes().Range(func(k string, v pcommon.Value) bool {
+			v.CopyTo(attrs.PutEmpty("event." + k))
+			return true
+		})
+	}
+
+	// Expression attributes are applied last so they can override the copied ones
+	if err := c.expressions.evalAttributes(ctx, tCtx, attrs); err != nil {
+		stats.expressionErrors += errorCount(err)
+		c.logger.Error("Failed to execute log attribute expressions", zap.Error(err))
+	}
+}
+
+func (c *Converter) generateLogBody(bodyTemplate *bodyTemplate, event ptrace.SpanEvent, span ptrace.Span, stats *conversionStats) string {
+	if bodyTemplate == nil {
+		return "Span Event: " + event.Name()
+	}
+
+	body, err := bodyTemplate.render(event, span)
+	if err != nil {
+		stats.templateErrors++
+		c.logger.Error("Failed to execute log body template", zap.Error(err))
+		return "Span Event: " + event.Name()
+	}
+
+	return body
+}
+
+// severityNumber returns the severity number of a log_level value
+func severityNumber(level string) plog.SeverityNumber {
+	switch level {
+	case "Trace":
+		return plog.SeverityNumberTrace
+	case "Debug":
+		return plog.SeverityNumberDebug
+	case "Info":
+		return plog.SeverityNumberInfo
+	case "Warn":
+		return plog.SeverityNumberWarn
+	case "Error":
+		return plog.SeverityNumberError
+	case "Fatal":
+		return plog.SeverityNumberFatal
+	default:
+		return plog.SeverityNumberInfo
+	}
+}
@@ -0,0 +1,187 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// logBodies returns the bodies of all log records in logs
+func logBodies(logs plog.Logs) []string {
+	var bodies []string
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		sls := logs.ResourceLogs().At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				bodies = append(bodies, lrs.At(k).Body().AsString())
+			}
+		}
+	}
+	return bodies
+}
+
+func TestConverter_MatchesConnector(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.LogBodyTemplate = `{{.SpanName}}: {{index .EventAttributes "exception.message"}}`
+
+	converter, err := NewConverter(cfg)
+	if err != nil {
+		t.Fatalf("NewConverter() error = %v", err)
+	}
+	logs, stats, err := converter.Convert(context.Background(), newTestTraces("GET /api/cart", "exception", "other", "exception"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := Stats{SpansEvaluated: 1, EventsEvaluated: 3, SpansMatched: 1, EventsMatched: 2, EventsDropped: 1, LogRecords: 2}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	var fromConnector []string
+	for _, lr := range consumeTestTraces(t, cfg, newTestTraces("GET /api/cart", "exception", "other", "exception")) {
+		fromConnector = append(fromConnector, lr.Body().AsString())
+	}
+	if got := logBodies(logs); !slices.Equal(got, fromConnector) {
+		t.Errorf("bodies = %q, connector produced %q", got, fromConnector)
+	}
+}
+
+func TestConverter_Routing(t *testing.T) {
+	converter, err := NewConverter(newRoutingTestConfig())
+	if err != nil {
+		t.Fatalf("NewConverter() error = %v", err)
+	}
+	logs, stats, err := converter.Convert(context.Background(), newRoutingTestTraces())
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	// Records are ordered by route: audit, observability, then the default pipelines
+	want := []string{"Span Event: login", "exception", "exception", "Span Event: checkout"}
+	if got := logBodies(logs); !slices.Equal(got, want) {
+		t.Errorf("bodies = %q, want %q", got, want)
+	}
+	if stats.LogRecords != len(want) {
+		t.Errorf("stats.LogRecords = %d, want %d", stats.LogRecords, len(want))
+	}
+}
+
+func TestConverter_Options(t *testing.T) {
+	isInternal := ottl.NewFactory("IsInternalError", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[ottlspanevent.TransformContext], error) {
+		return func(_ context.Context, tCtx ottlspanevent.TransformContext) (any, error) {
+			v, _ := tCtx.GetSpanEvent().Attributes().Get("exception.type")
+			return v.Str() == "requests.exceptions.ConnectionError", nil
+		}, nil
+	})
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`IsInternalError()`}
+	// The message is shorter than 100 characters, so the expression fails for every event
+	cfg.BodyExpression = `Substring(attributes["exception.message"], 0, 100)`
+
+	if _, err := NewConverter(cfg); err == nil {
+		t.Fatal("NewConverter() expected error for an unregistered function")
+	}
+
+	core, logged := observer.New(zap.ErrorLevel)
+	reader := sdkmetric.NewManualReader()
+	converter, err := NewConverter(cfg,
+		WithLogger(zap.New(core)),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithOTTLSpanEventFunctions([]ottl.Factory[ottlspanevent.TransformContext]{isInternal}),
+	)
+	if err != nil {
+		t.Fatalf("NewConverter() error = %v", err)
+	}
+	_, stats, err := converter.Convert(context.Background(), newTestTraces("GET /api/cart", "exception", "retry"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if stats.EventsMatched != 2 || stats.ExpressionErrors != 2 {
+		t.Errorf("stats = %+v, want 2 matched events and 2 expression errors", stats)
+	}
+	if n := logged.FilterMessage("Failed to execute log body expression").Len(); n != 2 {
+		t.Errorf("logged %d expression errors, want 2", n)
+	}
+
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	var matched int64
+	for _, sm := range metrics.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "otelcol_connector_spaneventstolog_events_matched" {
+				for _, dp := range sum.DataPoints {
+					matched += dp.Value
+				}
+			}
+		}
+	}
+	if matched != 2 {
+		t.Errorf("otelcol_connector_spaneventstolog_events_matched = %d, want 2", matched)
+	}
+}
+
+func TestConverter_Match(t *testing.T) {
+	tests := []struct {
+		name       string
+		match      MatchMode
+		wantSpans  int
+		wantEvents []string
+	}{
+		{name: "any", match: MatchAny, wantSpans: 1, wantEvents: []string{"exception", "other", "exception"}},
+		{name: "all", match: MatchAll, wantSpans: 1, wantEvents: []string{"exception", "exception"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			cfg.Conditions = []string{`span.kind == SPAN_KIND_CLIENT`, `spanevent.name == "exception"`}
+			cfg.ConditionsMatch = tc.match
+			converter, err := NewConverter(cfg)
+			if err != nil {
+				t.Fatalf("NewConverter() error = %v", err)
+			}
+			td := newTestTraces("GET /api/cart", "exception", "other", "exception")
+			spans := 0
+			var events []string
+			err = converter.Match(context.Background(), td,
+				func(_ ptrace.ResourceSpans, _ ptrace.Span) { spans++ },
+				func(_ ptrace.ResourceSpans, _ ptrace.Span, event ptrace.SpanEvent) {
+					events = append(events, event.Name())
+				},
+			)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if spans != tc.wantSpans || !slices.Equal(events, tc.wantEvents) {
+				t.Errorf("matched %d spans and events %q, want %d and %q", spans, events, tc.wantSpans, tc.wantEvents)
+			}
+
+			// Match selects exactly the events Convert converts
+			_, stats, err := converter.Convert(context.Background(), td)
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if stats.EventsMatched != int64(len(events)) {
+				t.Errorf("Convert() matched %d events, Match() %d", stats.EventsMatched, len(events))
+			}
+		})
+	}
+}
+
+func TestNewConverter_InvalidConfig(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	if _, err := NewConverter(cfg); err == nil {
+		t.Error("NewConverter() expected error for a configuration without conditions")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// logRecordUIDAttribute is the semantic convention attribute for a unique log record identifier
+const logRecordUIDAttribute = "log.record.uid"
+
+// DedupConfig configures dropping of log records whose log.record.uid was already emitted
+type DedupConfig struct {
+	// Window is how long an emitted UID suppresses identical records. 0 disables deduplication
+	Window time.Duration `mapstructure:"window"`
+
+	// MaxEntries bounds the number of remembered UIDs; the least recently seen are evicted first
+	MaxEntries int `mapstructure:"max_entries"`
+}
+
+const defaultDedupMaxEntries = 100000
+
+func (cfg DedupConfig) validate() error {
+	if cfg.Window < 0 {
+		return fmt.Errorf("invalid dedup.window: %s, must not be negative", cfg.Window)
+	}
+	if cfg.Window > 0 && cfg.MaxEntries <= 0 {
+		return fmt.Errorf("invalid dedup.max_entries: %d, must be positive", cfg.MaxEntries)
+	}
+	return nil
+}
+
+// logRecordUID derives the log.record.uid of the record produced for the event at
+// eventIndex of span. The same event converted by the same rule always gets the same UID,
+// so records regenerated by upstream retries can be recognized.
+func logRecordUID(span ptrace.Span, eventIndex int, event ptrace.SpanEvent, rule string) string {
+	h := fnv.New128a()
+	traceID := span.TraceID()
+	spanID := span.SpanID()
+	var buf [12]byte
+	binary.BigEndian.PutUint32(buf[:4], uint32(eventIndex))
+	binary.BigEndian.PutUint64(buf[4:], uint64(event.Timestamp()))
+	_, _ = h.Write(traceID[:])
+	_, _ = h.Write(spanID[:])
+	_, _ = h.Write(buf[:])
+	_, _ = h.Write([]byte(rule))
+	var sum [16]byte
+	return hex.EncodeToString(h.Sum(sum[:0]))
+}
+
+// dedupCache is an LRU set of recently emitted log record UIDs. It is shared by all
+// workers, so it is safe for concurrent use.
+type dedupCache struct {
+	window     time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // of *dedupEntry, most recently seen first
+	entries map[string]*list.Element
+}
+
+type dedupEntry struct {
+	uid     string
+	emitted time.Time
+}
+
+func newDedupCache(cfg DedupConfig) *dedupCache {
+	return &dedupCache{
+		window:     cfg.Window,
+		maxEntries: cfg.MaxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// duplicate reports whether uid was emitted within the window
+func (d *dedupCache) duplicate(uid string) bool {
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elem, ok := d.entries[uid]
+	if !ok {
+		return false
+	}
+	d.order.MoveToFront(elem)
+	return now.Sub(elem.Value.(*dedupEntry).emitted) < d.window
+}
+
+// remember records uids as emitted now. Empty UIDs are skipped.
+func (d *dedupCache) remember(uids []string) {
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, uid := range uids {
+		if uid == "" {
+			continue
+		}
+		if elem, ok := d.entries[uid]; ok {
+			d.order.MoveToFront(elem)
+			elem.Value.(*dedupEntry).emitted = now
+			continue
+		}
+		d.entries[uid] = d.order.PushFront(&dedupEntry{uid: uid, emitted: now})
+	}
+	for d.order.Len() > d.maxEntries {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*dedupEntry).uid)
+	}
+}
+
+// pendingUIDs holds the UIDs of the records produced for one batch until they are
+// accepted, so records that are never delivered do not suppress their retries. uids has
+// one slice per output with an entry per ResourceLogs, which is empty for rate limit
+// markers. It is nil without dedup.
+type pendingUIDs struct {
+	uids     [][]string
+	produced map[string]struct{}
+}
+
+func newPendingUIDs(dedup *dedupCache, outputs int) *pendingUIDs {
+	if dedup == nil {
+		return nil
+	}
+	return &pendingUIDs{uids: make([][]string, outputs), produced: make(map[string]struct{})}
+}
+
+// duplicate reports whether uid was emitted before or already produced in this batch
+func (p *pendingUIDs) duplicate(dedup *dedupCache, uid string) bool {
+	if _, ok := p.produced[uid]; ok {
+		return true
+	}
+	return dedup.duplicate(uid)
+}
+
+// add records the UID of the ResourceLogs just appended to output
+func (p *pendingUIDs) add(output int, uid string) {
+	if p == nil {
+		return
+	}
+	p.uids[output] = append(p.uids[output], uid)
+	if uid != "" {
+		p.produced[uid] = struct{}{}
+	}
+}
+
+// moveAndAppendTo moves the UIDs of every output to the end of dest's
+func (p *pendingUIDs) moveAndAppendTo(dest *pendingUIDs) {
+	if p == nil {
+		return
+	}
+	for i, uids := range p.uids {
+		dest.uids[i] = append(dest.uids[i], uids...)
+	}
+	for uid := range p.produced {
+		dest.produced[uid] = struct{}{}
+	}
+}
+
+// part returns the UIDs of output that the tenant split put into part, given the part
+// of every ResourceLogs. nil parts means the output was not split.
+func (p *pendingUIDs) part(output int, parts []int, part int) []string {
+	if p == nil {
+		return nil
+	}
+	if parts == nil {
+		return p.uids[output]
+	}
+	var uids []string
+	for i, uid := range p.uids[output] {
+		if parts[i] == part {
+			uids = append(uids, uid)
+		}
+	}
+	return uids
+}
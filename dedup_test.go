@@ -0,0 +1,140 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestLogRecordUID(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.LogRecordUID = true
+	td := newTestTraces("GET /api/cart", "exception", "exception")
+	events := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Events()
+	for i := 0; i < events.Len(); i++ {
+		events.At(i).SetTimestamp(pcommon.Timestamp(1700000000000000000))
+	}
+
+	records := consumeTestTraces(t, cfg, td)
+	again := consumeTestTraces(t, cfg, td)
+	if len(records) != 2 || len(again) != 2 {
+		t.Fatalf("got %d and %d records, want 2", len(records), len(again))
+	}
+	uid := func(i int, records []plog.LogRecord) string {
+		v, ok := records[i].Attributes().Get(logRecordUIDAttribute)
+		if !ok {
+			t.Fatalf("record %d has no %s", i, logRecordUIDAttribute)
+		}
+		return v.Str()
+	}
+	if got := uid(0, records); len(got) != 32 {
+		t.Errorf("uid = %q, want 32 hex characters", got)
+	}
+	if uid(0, records) != uid(0, again) || uid(1, records) != uid(1, again) {
+		t.Error("uids differ between identical conversions")
+	}
+	// Events with the same name and timestamp are told apart by their index
+	if uid(0, records) == uid(1, records) {
+		t.Error("uids of different events are equal")
+	}
+
+	span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	event := span.Events().At(0)
+	if logRecordUID(span, 0, event, "default") == logRecordUID(span, 0, event, "exception") {
+		t.Error("uids of different rules are equal")
+	}
+}
+
+func TestConnector_Dedup(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.Dedup = DedupConfig{Window: time.Minute, MaxEntries: 10}
+	sink := new(consumertest.LogsSink)
+	conn, tt := newTelemetryTestConnector(t, cfg, sink)
+	now := time.Unix(1700000000, 0)
+	conn.converter.dedup.now = func() time.Time { return now }
+
+	td := newTestTraces("GET /api/cart", "exception", "exception")
+	for i := 0; i < 2; i++ {
+		if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+			t.Fatalf("ConsumeTraces() error = %v", err)
+		}
+	}
+	if got := sink.LogRecordCount(); got != 2 {
+		t.Errorf("got %d records within the window, want 2", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_duplicates_suppressed"); got != 2 {
+		t.Errorf("duplicates suppressed = %d, want 2", got)
+	}
+
+	now = now.Add(time.Minute)
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	if got := sink.LogRecordCount(); got != 4 {
+		t.Errorf("got %d records after the window, want 4", got)
+	}
+}
+
+func TestConnector_DedupRetriedBatch(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.Dedup = DedupConfig{Window: time.Minute, MaxEntries: 10}
+	next := &flakyLogsConsumer{failures: 1}
+	conn, tt := newTelemetryTestConnector(t, cfg, next)
+
+	// The logs pipeline rejects the first attempt, so upstream retries the batch
+	td := newTestTraces("GET /api/cart", "exception", "exception")
+	if err := conn.ConsumeTraces(context.Background(), td); err == nil {
+		t.Fatal("ConsumeTraces() expected the error of the logs pipeline")
+	}
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	if got := next.LogRecordCount(); got != 2 {
+		t.Errorf("got %d records from the retried batch, want 2", got)
+	}
+
+	// Once delivered, the records are duplicates
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	if got := next.LogRecordCount(); got != 2 {
+		t.Errorf("got %d records after a delivered batch was resent, want 2", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_duplicates_suppressed"); got != 2 {
+		t.Errorf("duplicates suppressed = %d, want only the 2 records of the resent batch", got)
+	}
+}
+
+func TestDedupCache_EvictsLeastRecentlySeen(t *testing.T) {
+	d := newDedupCache(DedupConfig{Window: time.Hour, MaxEntries: 2})
+	for _, uid := range []string{"a", "b", "a", "c"} {
+		d.remember([]string{uid})
+	}
+	// "b" was evicted by "c"; "a" was seen more recently
+	if d.duplicate("b") {
+		t.Error("evicted uid b reported as duplicate")
+	}
+	if !d.duplicate("c") {
+		t.Error("uid c not reported as duplicate")
+	}
+}
+
+func TestConfig_Dedup(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.Dedup.Window = -time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for negative dedup.window")
+	}
+	cfg.Dedup = DedupConfig{Window: time.Minute}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for dedup without max_entries")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+type attributeExpression struct {
+	key  string
+	expr *ottl.ValueExpression[ottlspanevent.TransformContext]
+}
+
+// logExpressions holds the parsed OTTL value expressions that shape produced log records
+type logExpressions struct {
+	body       *ottl.ValueExpression[ottlspanevent.TransformContext]
+	attributes []attributeExpression
+}
+
+// parseLogExpressions parses body_expression and the attributes map in the spanevent context
+// of the given parser collection. It returns nil when no expressions are configured.
+func parseLogExpressions(cfg *Config, pc *ottl.ParserCollection[parsedOTTL]) (*logExpressions, error) {
+	if cfg.BodyExpression == "" && len(cfg.Attributes) == 0 {
+		return nil, nil
+	}
+
+	exprs := &logExpressions{}
+	if cfg.BodyExpression != "" {
+		expr, err := parseValueExpression(pc, cfg.BodyExpression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body_expression OTTL: %q: %w", cfg.BodyExpression, err)
+		}
+		exprs.body = expr
+	}
+
+	// Sort keys so attributes are always written in the same order
+	keys := make([]string, 0, len(cfg.Attributes))
+	for key := range cfg.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		expr, err := parseValueExpression(pc, cfg.Attributes[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid attributes[%q] OTTL: %q: %w", key, cfg.Attributes[key], err)
+		}
+		exprs.attributes = append(exprs.attributes, attributeExpression{key: key, expr: expr})
+	}
+
+	return exprs, nil
+}
+
+// parseValueExpression parses one value expression, prefixing context-less paths with spanevent
+func parseValueExpression(pc *ottl.ParserCollection[parsedOTTL], expr string) (*ottl.ValueExpression[ottlspanevent.TransformContext], error) {
+	parsed, err := pc.ParseValueExpressionsWithContext(ottlspanevent.ContextName, ottl.NewValueExpressionsGetter([]string{expr}), true)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.valueExpressions[0], nil
+}
+
+// evalBody evaluates body_expression into body. It reports false when no body
+// expression is configured or it resolves to nil, so the caller can fall back to
+// the body templates.
+func (e *logExpressions) evalBody(ctx context.Context, tCtx ottlspanevent.TransformContext, body pcommon.Value) (bool, error) {
+	if e == nil || e.body == nil {
+		return false, nil
+	}
+	val, err := e.body.Eval(ctx, tCtx)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate body_expression: %w", err)
+	}
+	if val == nil {
+		return false, nil
+	}
+	if err := setValue(body, val); err != nil {
+		return false, fmt.Errorf("failed to set log body: %w", err)
+	}
+	return true, nil
+}
+
+// evalAttributes evaluates every attribute expression into attrs. Expressions that
+// resolve to nil are skipped; evaluation errors are returned after all expressions ran.
+func (e *logExpressions) evalAttributes(ctx context.Context, tCtx ottlspanevent.TransformContext, attrs pcommon.Map) error {
+	if e == nil {
+		return nil
+	}
+	var errs []error
+	for _, a := range e.attributes {
+		val, err := a.expr.Eval(ctx, tCtx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to evaluate attributes[%q]: %w", a.key, err))
+			continue
+		}
+		if val == nil {
+			continue
+		}
+		if err := setValue(attrs.PutEmpty(a.key), val); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set attributes[%q]: %w", a.key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// errorCount returns how many errors err joins
+func errorCount(err error) int64 {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return int64(len(joined.Unwrap()))
+	}
+	return 1
+}
+
+// setsAttribute reports whether an attribute expression writes key
+func (e *logExpressions) setsAttribute(key string) bool {
+	if e == nil {
+		return false
+	}
+	for _, a := range e.attributes {
+		if a.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setValue stores an OTTL result in a pcommon.Value
+func setValue(dst pcommon.Value, val any) error {
+	switch v := val.(type) {
+	case nil:
+		return nil
+	case string:
+		dst.SetStr(v)
+	case bool:
+		dst.SetBool(v)
+	case int64:
+		dst.SetInt(v)
+	case float64:
+		dst.SetDouble(v)
+	case []byte:
+		dst.SetEmptyBytes().FromRaw(v)
+	case time.Duration:
+		dst.SetInt(v.Nanoseconds())
+	case pcommon.Value:
+		v.CopyTo(dst)
+	case pcommon.Map:
+		v.CopyTo(dst.SetEmptyMap())
+	case pcommon.Slice:
+		v.CopyTo(dst.SetEmptySlice())
+	default:
+		return dst.FromRaw(v)
+	}
+	return nil
+}
@@ -4,28 +4,96 @@ import (
 	"context"
 
 	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/connector"
 	"go.opentelemetry.io/collector/consumer"
 )
 
+// FactoryOption applies changes to the connector factory
+type FactoryOption func(factory *spanEventsToLogFactory)
+
+type spanEventsToLogFactory struct {
+	spanFunctions      map[string]ottl.Factory[ottlspan.TransformContext]
+	spanEventFunctions map[string]ottl.Factory[ottlspanevent.TransformContext]
+	transformers       map[string]EventTransformer
+}
+
+// WithSpanFunctions adds OTTL functions to the span context, used by span_conditions and
+// span-level conditions. Functions replace built-in functions of the same name.
+func WithSpanFunctions(functions []ottl.Factory[ottlspan.TransformContext]) FactoryOption {
+	return func(factory *spanEventsToLogFactory) {
+		factory.spanFunctions = mergeFunctions(factory.spanFunctions, functions...)
+	}
+}
+
+// WithSpanEventFunctions adds OTTL functions to the spanevent context, used by event_conditions,
+// event-level conditions, body_expression and attributes. Functions replace built-in
+// functions of the same name.
+func WithSpanEventFunctions(functions []ottl.Factory[ottlspanevent.TransformContext]) FactoryOption {
+	return func(factory *spanEventsToLogFactory) {
+		factory.spanEventFunctions = mergeFunctions(factory.spanEventFunctions, functions...)
+	}
+}
+
+// WithTransformers registers event transformers. The transformers option of the configuration
+// enables them by name. A transformer replaces one registered earlier under the same name.
+func WithTransformers(transformers ...EventTransformer) FactoryOption {
+	return func(factory *spanEventsToLogFactory) {
+		factory.transformers = mergeTransformers(factory.transformers, transformers...)
+	}
+}
+
 // NewFactory returns a connector.Factory for the SpanEventConnector
 func NewFactory() connector.Factory {
+	return NewFactoryWithOptions()
+}
+
+// NewFactoryWithOptions returns a connector.Factory for the SpanEventConnector with the given options.
+// Custom collector builds use it to register additional OTTL functions and event transformers.
+func NewFactoryWithOptions(options ...FactoryOption) connector.Factory {
+	f := &spanEventsToLogFactory{
+		spanFunctions:      defaultSpanFunctions(),
+		spanEventFunctions: defaultSpanEventFunctions(),
+	}
+	for _, option := range options {
+		option(f)
+	}
 	return connector.NewFactory(
 		metadata.Type,
-		createDefaultConfig,
+		f.createDefaultConfig,
 		connector.WithTracesToLogs(createTracesToLogs, metadata.TracesToLogsStability),
 	)
 }
 
+func (f *spanEventsToLogFactory) createDefaultConfig() component.Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.spanFunctions = f.spanFunctions
+	cfg.spanEventFunctions = f.spanEventFunctions
+	cfg.transformers = f.transformers
+	return cfg
+}
+
 func createDefaultConfig() component.Config {
 	return &Config{
 		SpanConditions:         []string{},
 		EventConditions:        []string{},
+		Conditions:             []string{},
+		ConditionsMatch:        MatchAny,
+		SpanConditionsMatch:    MatchAny,
+		EventConditionsMatch:   MatchAny,
+		ErrorMode:              ottl.IgnoreError,
 		IncludeSpanAttributes:  true,
 		IncludeEventAttributes: true,
 		LogLevel:               "Info",
 		LogBodyTemplate:        "Span Event: {{.EventName}}",
+		ParallelThreshold:      defaultParallelThreshold,
+		Dedup:                  DedupConfig{MaxEntries: defaultDedupMaxEntries},
+		RateLimit:              defaultRateLimitConfig(),
+		OnLogsError:            LogsErrorPropagate,
+		RetryQueue:             defaultRetryQueueConfig(),
 	}
 }
 
@@ -0,0 +1,194 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// spanTransformContext is implemented by every OTTL context that has a span
+type spanTransformContext interface {
+	GetSpan() ptrace.Span
+}
+
+// spanEventTransformContext is implemented by every OTTL context that has a span event
+type spanEventTransformContext interface {
+	spanTransformContext
+	GetSpanEvent() ptrace.SpanEvent
+}
+
+// defaultSpanFunctions returns the OTTL functions available in the span context:
+// the standard OTTL functions plus the connector functions that only need a span.
+func defaultSpanFunctions() map[string]ottl.Factory[ottlspan.TransformContext] {
+	return mergeFunctions(ottlfuncs.StandardFuncs[ottlspan.TransformContext](),
+		newSpanDurationFactory[ottlspan.TransformContext](),
+		newEventCountFactory[ottlspan.TransformContext](),
+	)
+}
+
+// defaultSpanEventFunctions returns the OTTL functions available in the span event context:
+// the standard OTTL functions plus all connector functions.
+func defaultSpanEventFunctions() map[string]ottl.Factory[ottlspanevent.TransformContext] {
+	return mergeFunctions(ottlfuncs.StandardFuncs[ottlspanevent.TransformContext](),
+		newSpanDurationFactory[ottlspanevent.TransformContext](),
+		newEventCountFactory[ottlspanevent.TransformContext](),
+		newEventOffsetFactory[ottlspanevent.TransformContext](),
+		newIsExceptionFactory[ottlspanevent.TransformContext](),
+		newHasStacktraceFactory[ottlspanevent.TransformContext](),
+		newExceptionRootCauseFactory[ottlspanevent.TransformContext](),
+	)
+}
+
+// mergeFunctions returns a copy of functions with the given factories added.
+// Factories replace existing functions of the same name.
+func mergeFunctions[K any](functions map[string]ottl.Factory[K], factories ...ottl.Factory[K]) map[string]ottl.Factory[K] {
+	merged := make(map[string]ottl.Factory[K], len(functions)+len(factories))
+	for name, f := range functions {
+		merged[name] = f
+	}
+	for _, f := range factories {
+		merged[f.Name()] = f
+	}
+	return merged
+}
+
+// SpanDuration() returns the duration of the span.
+func newSpanDurationFactory[K spanTransformContext]() ottl.Factory[K] {
+	return ottl.NewFactory("SpanDuration", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[K], error) {
+		return func(_ context.Context, tCtx K) (any, error) {
+			span := tCtx.GetSpan()
+			return span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime()), nil
+		}, nil
+	})
+}
+
+type eventCountArguments[K any] struct {
+	Name ottl.StringGetter[K]
+}
+
+// EventCount(name) returns how many events with the given name the span has.
+func newEventCountFactory[K spanTransformContext]() ottl.Factory[K] {
+	return ottl.NewFactory("EventCount", &eventCountArguments[K]{}, func(_ ottl.FunctionContext, oArgs ottl.Arguments) (ottl.ExprFunc[K], error) {
+		args, ok := oArgs.(*eventCountArguments[K])
+		if !ok {
+			return nil, errors.New("EventCountFactory args must be of type *eventCountArguments[K]")
+		}
+		return func(ctx context.Context, tCtx K) (any, error) {
+			name, err := args.Name.Get(ctx, tCtx)
+			if err != nil {
+				return nil, err
+			}
+			events := tCtx.GetSpan().Events()
+			var count int64
+			for i := 0; i < events.Len(); i++ {
+				if events.At(i).Name() == name {
+					count++
+				}
+			}
+			return count, nil
+		}, nil
+	})
+}
+
+// EventOffset() returns the time between the span start and the span event.
+func newEventOffsetFactory[K spanEventTransformContext]() ottl.Factory[K] {
+	return ottl.NewFactory("EventOffset", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[K], error) {
+		return func(_ context.Context, tCtx K) (any, error) {
+			return tCtx.GetSpanEvent().Timestamp().AsTime().Sub(tCtx.GetSpan().StartTimestamp().AsTime()), nil
+		}, nil
+	})
+}
+
+// IsException() reports whether the span event is an OpenTelemetry exception event.
+func newIsExceptionFactory[K spanEventTransformContext]() ottl.Factory[K] {
+	return ottl.NewFactory("IsException", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[K], error) {
+		return func(_ context.Context, tCtx K) (any, error) {
+			return tCtx.GetSpanEvent().Name() == "exception", nil
+		}, nil
+	})
+}
+
+// HasStacktrace() reports whether the span event has a non-empty exception.stacktrace attribute.
+func newHasStacktraceFactory[K spanEventTransformContext]() ottl.Factory[K] {
+	return ottl.NewFactory("HasStacktrace", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[K], error) {
+		return func(_ context.Context, tCtx K) (any, error) {
+			v, ok := tCtx.GetSpanEvent().Attributes().Get("exception.stacktrace")
+			return ok && v.AsString() != "", nil
+		}, nil
+	})
+}
+
+// ExceptionRootCause() returns the innermost cause recorded in the exception stacktrace,
+// falling back to "exception.type: exception.message". It returns nil for events
+// without exception attributes.
+func newExceptionRootCauseFactory[K spanEventTransformContext]() ottl.Factory[K] {
+	return ottl.NewFactory("ExceptionRootCause", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[K], error) {
+		return func(_ context.Context, tCtx K) (any, error) {
+			attrs := tCtx.GetSpanEvent().Attributes()
+			if v, ok := attrs.Get("exception.stacktrace"); ok {
+				if cause := rootCauseFromStacktrace(v.AsString()); cause != "" {
+					return cause, nil
+				}
+			}
+			excType, hasType := attrs.Get("exception.type")
+			excMessage, hasMessage := attrs.Get("exception.message")
+			switch {
+			case hasType && hasMessage:
+				return excType.AsString() + ": " + excMessage.AsString(), nil
+			case hasType:
+				return excType.AsString(), nil
+			case hasMessage:
+				return excMessage.AsString(), nil
+			default:
+				return nil, nil
+			}
+		}, nil
+	})
+}
+
+// pythonChainSeparators separate chained exceptions in Python tracebacks; the root cause comes first
+var pythonChainSeparators = []string{
+	"The above exception was the direct cause of the following exception:",
+	"During handling of the above exception, another exception occurred:",
+}
+
+// rootCauseFromStacktrace extracts the innermost cause from Java ("Caused by:"),
+// .NET (" ---> ") and Python (chained traceback) stacktraces. It returns "" when
+// the stacktrace records no chained cause.
+func rootCauseFromStacktrace(stacktrace string) string {
+	lines := strings.Split(stacktrace, "\n")
+
+	// Java and other JVM languages list causes outermost first
+	for i := len(lines) - 1; i >= 0; i-- {
+		if cause, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "Caused by: "); ok {
+			return cause
+		}
+	}
+
+	// .NET nests inner exceptions on the first line
+	if idx := strings.LastIndex(lines[0], " ---> "); idx >= 0 {
+		return strings.TrimSpace(lines[0][idx+len(" ---> "):])
+	}
+
+	// Python prints the root cause first, ending with its "Type: message" line
+	end := -1
+	for _, sep := range pythonChainSeparators {
+		if idx := strings.Index(stacktrace, sep); idx >= 0 && (end < 0 || idx < end) {
+			end = idx
+		}
+	}
+	if end < 0 {
+		return ""
+	}
+	first := strings.Split(strings.TrimSpace(stacktrace[:end]), "\n")
+	return strings.TrimSpace(first[len(first)-1])
+}
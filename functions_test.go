@@ -0,0 +1,116 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestFunctions_InConditionsAndExpressions(t *testing.T) {
+	td := newTestTraces("GET /api/cart", "exception", "exception", "retry")
+	span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	start := time.Unix(1700000000, 0)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(2 * time.Second)))
+	for i := 0; i < span.Events().Len(); i++ {
+		span.Events().At(i).SetTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Duration(i+1) * 100 * time.Millisecond)))
+	}
+	span.Events().At(0).Attributes().PutStr("exception.stacktrace", "Traceback (most recent call last):\n  File \"a.py\", line 1\nValueError: bad\n\nThe above exception was the direct cause of the following exception:\n\nRuntimeError: wrapped")
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.SpanConditions = []string{`SpanDuration() > Duration("1s") and EventCount("exception") == 2`}
+	cfg.EventConditions = []string{`IsException()`}
+	cfg.Attributes = map[string]string{
+		"root_cause":     `ExceptionRootCause()`,
+		"has_stacktrace": `HasStacktrace()`,
+		"offset":         `EventOffset()`,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	records := consumeTestTraces(t, cfg, td)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	want := []struct {
+		rootCause     string
+		hasStacktrace bool
+		offset        time.Duration
+	}{
+		{rootCause: "ValueError: bad", hasStacktrace: true, offset: 100 * time.Millisecond},
+		{rootCause: "requests.exceptions.ConnectionError: Connection refused", hasStacktrace: false, offset: 200 * time.Millisecond},
+	}
+	for i, lr := range records {
+		attrs := lr.Attributes()
+		if v, _ := attrs.Get("root_cause"); v.Str() != want[i].rootCause {
+			t.Errorf("record %d root_cause = %q, want %q", i, v.Str(), want[i].rootCause)
+		}
+		if v, _ := attrs.Get("has_stacktrace"); v.Bool() != want[i].hasStacktrace {
+			t.Errorf("record %d has_stacktrace = %v", i, v.Bool())
+		}
+		if v, _ := attrs.Get("offset"); v.Int() != want[i].offset.Nanoseconds() {
+			t.Errorf("record %d offset = %d, want %d", i, v.Int(), want[i].offset.Nanoseconds())
+		}
+	}
+}
+
+func TestRootCauseFromStacktrace(t *testing.T) {
+	tests := map[string]struct {
+		stacktrace string
+		want       string
+	}{
+		"java": {
+			stacktrace: "java.lang.RuntimeException: outer\n\tat A.a(A.java:1)\nCaused by: java.io.IOException: middle\n\tat B.b(B.java:2)\nCaused by: java.net.ConnectException: Connection refused\n\t... 3 more",
+			want:       "java.net.ConnectException: Connection refused",
+		},
+		"dotnet": {
+			stacktrace: "System.Exception: outer ---> System.Net.Http.HttpRequestException: inner\n   at Foo.Bar()",
+			want:       "System.Net.Http.HttpRequestException: inner",
+		},
+		"python_chained": {
+			stacktrace: "Traceback (most recent call last):\n  File \"x.py\", line 3\nConnectionRefusedError: [Errno 111] refused\n\nDuring handling of the above exception, another exception occurred:\n\nTraceback (most recent call last):\nurllib3.exceptions.NewConnectionError: failed",
+			want:       "ConnectionRefusedError: [Errno 111] refused",
+		},
+		"no_cause": {
+			stacktrace: "Traceback (most recent call last):\n  File \"x.py\", line 3\nValueError: bad",
+			want:       "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := rootCauseFromStacktrace(tt.stacktrace); got != tt.want {
+				t.Errorf("rootCauseFromStacktrace() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFactory_CustomFunctions(t *testing.T) {
+	isInternal := ottl.NewFactory("IsInternalError", nil, func(ottl.FunctionContext, ottl.Arguments) (ottl.ExprFunc[ottlspanevent.TransformContext], error) {
+		return func(_ context.Context, tCtx ottlspanevent.TransformContext) (any, error) {
+			v, _ := tCtx.GetSpanEvent().Attributes().Get("exception.type")
+			return v.Str() == "requests.exceptions.ConnectionError", nil
+		}, nil
+	})
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`IsInternalError()`}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Validate() expected error for an unregistered function")
+	}
+
+	factory := NewFactoryWithOptions(WithSpanEventFunctions([]ottl.Factory[ottlspanevent.TransformContext]{isInternal}))
+	cfg = factory.CreateDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`IsInternalError()`}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if records := consumeTestTraces(t, cfg, newTestTraces("GET /api/cart", "exception")); len(records) != 1 {
+		t.Errorf("got %d records, want 1", len(records))
+	}
+}
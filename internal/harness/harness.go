@@ -0,0 +1,192 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package harness runs the spaneventstolog connector in memory. It is shared by the
+// spanevents2log command and the spaneventstologtest package.
+package harness
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// LoadConfig reads the connector configuration from a YAML file. The file holds either the
+// connector's own settings or a whole collector configuration; in the latter case the
+// connector is looked up under connectors by name, which defaults to the only
+// spaneventstolog connector. The configuration starts from the default configuration of
+// factory, so custom functions and transformers registered with it are available.
+func LoadConfig(path, name string, factory connector.Factory) (*spaneventstologconnector.Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	retrieved, err := confmap.NewRetrievedFromYAML(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	conf, err := retrieved.AsConf()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if conf.IsSet("connectors") {
+		if conf, err = connectorConf(conf, name); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	cfg := factory.CreateDefaultConfig().(*spaneventstologconnector.Config)
+	if err := cfg.Unmarshal(conf); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// connectorConf returns the settings of the spaneventstolog connector called name in a
+// collector configuration
+func connectorConf(conf *confmap.Conf, name string) (*confmap.Conf, error) {
+	connectors, err := conf.Sub("connectors")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, key := range connectors.AllKeys() {
+		id, _, _ := strings.Cut(key, confmap.KeyDelimiter)
+		if id == metadata.Type.String() || strings.HasPrefix(id, metadata.Type.String()+"/") {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	switch {
+	case name != "":
+		for _, id := range ids {
+			if id == name {
+				return connectors.Sub(id)
+			}
+		}
+		return nil, fmt.Errorf("connector %q not found, the file has %v", name, ids)
+	case len(ids) == 1:
+		return connectors.Sub(ids[0])
+	case len(ids) == 0:
+		return nil, fmt.Errorf("no %s connector found", metadata.Type)
+	default:
+		return nil, fmt.Errorf("the file has several %s connectors %v, select one by name", metadata.Type, ids)
+	}
+}
+
+// pipelineSink collects the logs routed to one logs pipeline
+type pipelineSink struct {
+	// name is empty when the configuration has no routes
+	name string
+	sink *consumertest.LogsSink
+}
+
+// Conversion runs the connector in memory. Every logs pipeline the routes reference gets
+// its own sink, so routing behaves as in the collector.
+type Conversion struct {
+	conn    connector.Traces
+	sinks   []pipelineSink
+	stopped bool
+}
+
+// New creates and starts a connector from factory with cfg
+func New(ctx context.Context, factory connector.Factory, cfg *spaneventstologconnector.Config, telemetry component.TelemetrySettings) (*Conversion, error) {
+	c := &Conversion{}
+	var next consumer.Logs
+	if ids := RoutedPipelines(&cfg.Routing); len(ids) > 0 {
+		consumers := make(map[pipeline.ID]consumer.Logs, len(ids))
+		for _, id := range ids {
+			sink := new(consumertest.LogsSink)
+			consumers[id] = sink
+			c.sinks = append(c.sinks, pipelineSink{name: id.String(), sink: sink})
+		}
+		next = connector.NewLogsRouter(consumers)
+	} else {
+		sink := new(consumertest.LogsSink)
+		c.sinks = []pipelineSink{{sink: sink}}
+		next = sink
+	}
+
+	set := connector.Settings{
+		ID:                component.NewID(metadata.Type),
+		TelemetrySettings: telemetry,
+		BuildInfo:         component.NewDefaultBuildInfo(),
+	}
+	conn, err := factory.CreateTracesToLogs(ctx, set, cfg, next)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Start(ctx, componenttest.NewNopHost()); err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// RoutedPipelines returns the logs pipelines referenced by the routes, in order of appearance
+func RoutedPipelines(cfg *spaneventstologconnector.RoutingConfig) []pipeline.ID {
+	var ids []pipeline.ID
+	seen := make(map[pipeline.ID]bool)
+	add := func(pipelines []pipeline.ID) {
+		for _, id := range pipelines {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, route := range cfg.Routes {
+		add(route.Pipelines)
+	}
+	add(cfg.DefaultPipelines)
+	return ids
+}
+
+// Convert passes td to the connector
+func (c *Conversion) Convert(ctx context.Context, td ptrace.Traces) error {
+	return c.conn.ConsumeTraces(ctx, td)
+}
+
+// Drain passes the logs each pipeline received since the last drain to fn
+func (c *Conversion) Drain(fn func(logs plog.Logs, pipeline string) error) error {
+	for _, s := range c.sinks {
+		all := s.sink.AllLogs()
+		s.sink.Reset()
+		for _, logs := range all {
+			if err := fn(logs, s.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Shutdown stops the connector. The records it emits while stopping, such as the last
+// rate limit markers, are kept for the next Drain. Later calls do nothing, so Shutdown can
+// also be deferred for early returns.
+func (c *Conversion) Shutdown(ctx context.Context) error {
+	if c.stopped {
+		return nil
+	}
+	c.stopped = true
+	return c.conn.Shutdown(ctx)
+}
@@ -0,0 +1,221 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package harness
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// jaegerExport is the JSON the Jaeger UI downloads and the Jaeger query API returns. A single
+// trace without the data wrapper is accepted as well.
+type jaegerExport struct {
+	Data []jaegerTrace `json:"data"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+}
+
+type jaegerProcess struct {
+	ServiceName string      `json:"serviceName"`
+	Tags        []jaegerTag `json:"tags"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	// StartTime and Duration are in microseconds
+	StartTime int64       `json:"startTime"`
+	Duration  int64       `json:"duration"`
+	Tags      []jaegerTag `json:"tags"`
+	Logs      []jaegerLog `json:"logs"`
+	ProcessID string      `json:"processID"`
+	// Process is set instead of ProcessID by some exporters
+	Process *jaegerProcess `json:"process"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerLog struct {
+	Timestamp int64       `json:"timestamp"`
+	Fields    []jaegerTag `json:"fields"`
+}
+
+type jaegerTag struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// isJaeger reports whether the keys of a JSON object are those of a Jaeger export
+func isJaeger(keys map[string]json.RawMessage) bool {
+	if _, ok := keys["data"]; ok {
+		return true
+	}
+	_, spans := keys["spans"]
+	_, processes := keys["processes"]
+	return spans && processes
+}
+
+// jaegerToTraces translates a Jaeger JSON export. Span logs become span events named by
+// their event field, and the tags the OpenTelemetry Jaeger exporter writes for the span
+// kind, status and instrumentation scope are mapped back.
+func jaegerToTraces(doc []byte) (ptrace.Traces, error) {
+	var export jaegerExport
+	if err := json.Unmarshal(doc, &export); err != nil {
+		return ptrace.Traces{}, err
+	}
+	if export.Data == nil {
+		var trace jaegerTrace
+		if err := json.Unmarshal(doc, &trace); err != nil {
+			return ptrace.Traces{}, err
+		}
+		export.Data = []jaegerTrace{trace}
+	}
+
+	b := newResourceBuilder()
+	for _, trace := range export.Data {
+		for _, js := range trace.Spans {
+			process, ok := trace.Processes[js.ProcessID]
+			if js.Process != nil {
+				process, ok = *js.Process, true
+			}
+			if !ok {
+				return ptrace.Traces{}, fmt.Errorf("span %s refers to unknown process %q", js.SpanID, js.ProcessID)
+			}
+			resource := pcommon.NewMap()
+			resource.PutStr("service.name", process.ServiceName)
+			for _, tag := range process.Tags {
+				if err := putJaegerTag(resource, tag); err != nil {
+					return ptrace.Traces{}, err
+				}
+			}
+			if err := b.addJaegerSpan(resource, js); err != nil {
+				return ptrace.Traces{}, fmt.Errorf("span %s: %w", js.SpanID, err)
+			}
+		}
+	}
+	return b.traces, nil
+}
+
+func (b *resourceBuilder) addJaegerSpan(resource pcommon.Map, js jaegerSpan) error {
+	attrs := pcommon.NewMap()
+	for _, tag := range js.Tags {
+		if err := putJaegerTag(attrs, tag); err != nil {
+			return err
+		}
+	}
+	span := b.span(resource, attrs)
+	span.SetName(js.OperationName)
+	traceID, err := parseTraceID(js.TraceID)
+	if err != nil {
+		return err
+	}
+	span.SetTraceID(traceID)
+	spanID, err := parseSpanID(js.SpanID)
+	if err != nil {
+		return err
+	}
+	span.SetSpanID(spanID)
+	for _, ref := range js.References {
+		if ref.RefType == "CHILD_OF" || ref.RefType == "FOLLOWS_FROM" {
+			parent, err := parseSpanID(ref.SpanID)
+			if err != nil {
+				return err
+			}
+			span.SetParentSpanID(parent)
+			break
+		}
+	}
+	span.SetStartTimestamp(microsToTimestamp(js.StartTime))
+	span.SetEndTimestamp(microsToTimestamp(js.StartTime + js.Duration))
+
+	for _, log := range js.Logs {
+		event := span.Events().AppendEmpty()
+		event.SetTimestamp(microsToTimestamp(log.Timestamp))
+		for _, field := range log.Fields {
+			if field.Key == "event" {
+				var name string
+				if err := json.Unmarshal(field.Value, &name); err == nil {
+					event.SetName(name)
+					continue
+				}
+			}
+			if err := putJaegerTag(event.Attributes(), field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// putJaegerTag adds tag to attrs with the type the tag declares
+func putJaegerTag(attrs pcommon.Map, tag jaegerTag) error {
+	var err error
+	switch strings.ToLower(tag.Type) {
+	case "bool":
+		var v bool
+		if err = unmarshalTagValue(tag.Value, &v); err == nil {
+			attrs.PutBool(tag.Key, v)
+		}
+	case "int64":
+		var v int64
+		if err = unmarshalTagValue(tag.Value, &v); err == nil {
+			attrs.PutInt(tag.Key, v)
+		}
+	case "float64":
+		var v float64
+		if err = unmarshalTagValue(tag.Value, &v); err == nil {
+			attrs.PutDouble(tag.Key, v)
+		}
+	case "binary":
+		var s string
+		if err = json.Unmarshal(tag.Value, &s); err == nil {
+			var v []byte
+			if v, err = base64.StdEncoding.DecodeString(s); err == nil {
+				attrs.PutEmptyBytes(tag.Key).FromRaw(v)
+			}
+		}
+	default:
+		var v any
+		if err = json.Unmarshal(tag.Value, &v); err == nil {
+			if s, ok := v.(string); ok {
+				attrs.PutStr(tag.Key, s)
+			} else {
+				attrs.PutStr(tag.Key, string(tag.Value))
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s tag %q: %w", tag.Type, tag.Key, err)
+	}
+	return nil
+}
+
+// unmarshalTagValue reads a tag value that may also be written as a JSON string
+func unmarshalTagValue(raw json.RawMessage, v any) error {
+	if err := json.Unmarshal(raw, v); err == nil {
+		return nil
+	}
+	s, err := strconv.Unquote(string(raw))
+	if err != nil {
+		return fmt.Errorf("unexpected value %s", raw)
+	}
+	return json.Unmarshal([]byte(s), v)
+}
@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package harness
+
+import (
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// Record is the flattened form of one log record, as the ndjson output and golden files
+// show it
+type Record struct {
+	Pipeline       string         `json:"pipeline,omitempty"`
+	Timestamp      string         `json:"timestamp,omitempty"`
+	SeverityText   string         `json:"severity_text,omitempty"`
+	SeverityNumber int32          `json:"severity_number,omitempty"`
+	Body           any            `json:"body"`
+	TraceID        string         `json:"trace_id,omitempty"`
+	SpanID         string         `json:"span_id,omitempty"`
+	Attributes     map[string]any `json:"attributes,omitempty"`
+	Resource       map[string]any `json:"resource,omitempty"`
+	Scope          string         `json:"scope,omitempty"`
+}
+
+// Records flattens the log records of logs, which were routed to pipeline
+func Records(logs plog.Logs, pipeline string) []Record {
+	var records []Record
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		rl := logs.ResourceLogs().At(i)
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				lr := sl.LogRecords().At(k)
+				record := Record{
+					Pipeline:       pipeline,
+					SeverityText:   lr.SeverityText(),
+					SeverityNumber: int32(lr.SeverityNumber()),
+					Body:           lr.Body().AsRaw(),
+					Attributes:     lr.Attributes().AsRaw(),
+					Resource:       rl.Resource().Attributes().AsRaw(),
+					Scope:          sl.Scope().Name(),
+				}
+				if lr.Timestamp() != 0 {
+					record.Timestamp = lr.Timestamp().AsTime().Format(time.RFC3339Nano)
+				}
+				if !lr.TraceID().IsEmpty() {
+					record.TraceID = lr.TraceID().String()
+				}
+				if !lr.SpanID().IsEmpty() {
+					record.SpanID = lr.SpanID().String()
+				}
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
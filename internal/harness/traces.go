@@ -0,0 +1,215 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package harness
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// DecodeTraces detects the encoding of content. JSON may hold several documents one after
+// the other, as the file exporter writes them, and each is detected on its own: OTLP/JSON,
+// a Jaeger JSON export or Zipkin v2 JSON. Anything else is read as one OTLP/protobuf
+// message.
+func DecodeTraces(content []byte) ([]ptrace.Traces, error) {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	if trimmed[0] != '{' && trimmed[0] != '[' {
+		td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTLP/protobuf traces: %w", err)
+		}
+		return []ptrace.Traces{td}, nil
+	}
+
+	var batches []ptrace.Traces
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		var doc json.RawMessage
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return batches, nil
+			}
+			return nil, fmt.Errorf("failed to read JSON traces: %w", err)
+		}
+		td, err := decodeJSONTraces(doc)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, td)
+	}
+}
+
+// decodeJSONTraces detects the format of one JSON document and translates it
+func decodeJSONTraces(doc json.RawMessage) (ptrace.Traces, error) {
+	if doc[0] == '[' {
+		td, err := zipkinToTraces(doc)
+		if err != nil {
+			return td, fmt.Errorf("failed to read Zipkin v2 JSON traces: %w", err)
+		}
+		return td, nil
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &keys); err != nil {
+		return ptrace.Traces{}, fmt.Errorf("failed to read JSON traces: %w", err)
+	}
+	if isJaeger(keys) {
+		td, err := jaegerToTraces(doc)
+		if err != nil {
+			return td, fmt.Errorf("failed to read Jaeger JSON traces: %w", err)
+		}
+		return td, nil
+	}
+	// The Jaeger v3 query API wraps OTLP/JSON in a result object
+	if result, ok := keys["result"]; ok {
+		doc = result
+	}
+	td, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(doc)
+	if err != nil {
+		return td, fmt.Errorf("failed to read OTLP/JSON traces: %w", err)
+	}
+	return td, nil
+}
+
+// resourceBuilder groups translated spans by resource and instrumentation scope
+type resourceBuilder struct {
+	traces    ptrace.Traces
+	resources map[string]ptrace.ResourceSpans
+	scopes    map[string]ptrace.ScopeSpans
+}
+
+func newResourceBuilder() *resourceBuilder {
+	return &resourceBuilder{
+		traces:    ptrace.NewTraces(),
+		resources: make(map[string]ptrace.ResourceSpans),
+		scopes:    make(map[string]ptrace.ScopeSpans),
+	}
+}
+
+// span appends a span for resource. The tags in attrs that describe the span kind, status
+// and instrumentation scope are applied and removed; the others become span attributes.
+func (b *resourceBuilder) span(resource, attrs pcommon.Map) ptrace.Span {
+	resourceKey := mapKey(resource)
+	rs, ok := b.resources[resourceKey]
+	if !ok {
+		rs = b.traces.ResourceSpans().AppendEmpty()
+		resource.CopyTo(rs.Resource().Attributes())
+		b.resources[resourceKey] = rs
+	}
+
+	scopeName, scopeVersion := takeStr(attrs, "otel.scope.name", "otel.library.name"), takeStr(attrs, "otel.scope.version", "otel.library.version")
+	scopeKey := resourceKey + "\x00" + scopeName + "\x00" + scopeVersion
+	ss, ok := b.scopes[scopeKey]
+	if !ok {
+		ss = rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName(scopeName)
+		ss.Scope().SetVersion(scopeVersion)
+		b.scopes[scopeKey] = ss
+	}
+
+	span := ss.Spans().AppendEmpty()
+	switch strings.ToLower(takeStr(attrs, "span.kind")) {
+	case "client":
+		span.SetKind(ptrace.SpanKindClient)
+	case "server":
+		span.SetKind(ptrace.SpanKindServer)
+	case "producer":
+		span.SetKind(ptrace.SpanKindProducer)
+	case "consumer":
+		span.SetKind(ptrace.SpanKindConsumer)
+	case "internal":
+		span.SetKind(ptrace.SpanKindInternal)
+	}
+
+	statusCode := strings.ToUpper(takeStr(attrs, "otel.status_code"))
+	message := takeStr(attrs, "otel.status_description")
+	errorTag, hasError := attrs.Get("error")
+	switch {
+	case statusCode == "ERROR":
+		span.Status().SetCode(ptrace.StatusCodeError)
+	case statusCode == "OK":
+		span.Status().SetCode(ptrace.StatusCodeOk)
+	case hasError && (errorTag.Type() == pcommon.ValueTypeBool && errorTag.Bool() || errorTag.Type() == pcommon.ValueTypeStr):
+		span.Status().SetCode(ptrace.StatusCodeError)
+		// Zipkin records the error message as the value of the error tag
+		if errorTag.Type() == pcommon.ValueTypeStr && message == "" && errorTag.Str() != "true" {
+			message = errorTag.Str()
+		}
+	}
+	if span.Status().Code() == ptrace.StatusCodeError {
+		span.Status().SetMessage(message)
+		attrs.Remove("error")
+	}
+	attrs.CopyTo(span.Attributes())
+	return span
+}
+
+// takeStr removes the first of keys from attrs and returns its value
+func takeStr(attrs pcommon.Map, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := attrs.Get(key); ok {
+			s := v.AsString()
+			attrs.Remove(key)
+			return s
+		}
+	}
+	return ""
+}
+
+// mapKey returns a string identifying the contents of m
+func mapKey(m pcommon.Map) string {
+	keys := make([]string, 0, m.Len())
+	m.Range(func(k string, v pcommon.Value) bool {
+		keys = append(keys, k+"="+v.Type().String()+":"+v.AsString())
+		return true
+	})
+	slices.Sort(keys)
+	return strings.Join(keys, "\x00")
+}
+
+func microsToTimestamp(micros int64) pcommon.Timestamp {
+	return pcommon.Timestamp(micros * 1000)
+}
+
+// parseTraceID reads a hex trace ID. 64-bit IDs, as older Jaeger and Zipkin clients create,
+// are padded with zeros.
+func parseTraceID(s string) (pcommon.TraceID, error) {
+	var id pcommon.TraceID
+	b, err := decodeHexID(s)
+	if err != nil || len(b) == 0 || len(b) > len(id) {
+		return id, fmt.Errorf("invalid trace ID %q", s)
+	}
+	copy(id[len(id)-len(b):], b)
+	return id, nil
+}
+
+func parseSpanID(s string) (pcommon.SpanID, error) {
+	var id pcommon.SpanID
+	b, err := decodeHexID(s)
+	if err != nil || len(b) == 0 || len(b) > len(id) {
+		return id, fmt.Errorf("invalid span ID %q", s)
+	}
+	copy(id[len(id)-len(b):], b)
+	return id, nil
+}
+
+// decodeHexID decodes a hex ID whose leading zero may have been dropped
+func decodeHexID(s string) ([]byte, error) {
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return hex.DecodeString(s)
+}
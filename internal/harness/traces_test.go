@@ -0,0 +1,176 @@
+package harness
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// decodeFile decodes a file that holds one batch
+func decodeFile(t *testing.T, path string) ptrace.Traces {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches, err := DecodeTraces(content)
+	if err != nil {
+		t.Fatalf("DecodeTraces() error = %v", err)
+	}
+	if len(batches) != 1 {
+		t.Fatalf("got %d batches, want 1", len(batches))
+	}
+	return batches[0]
+}
+
+func TestDecodeTraces_Jaeger(t *testing.T) {
+	td := decodeFile(t, "testdata/jaeger.json")
+	if n := td.ResourceSpans().Len(); n != 2 {
+		t.Fatalf("got %d resources, want 2", n)
+	}
+
+	rs := td.ResourceSpans().At(0)
+	if got := rs.Resource().Attributes().AsRaw(); got["service.name"] != "loadgenerator" || got["telemetry.sdk.language"] != "python" {
+		t.Errorf("resource = %v", got)
+	}
+	if got := rs.ScopeSpans().At(0).Scope().Name(); got != "opentelemetry.instrumentation.requests" {
+		t.Errorf("scope = %q", got)
+	}
+	span := rs.ScopeSpans().At(0).Spans().At(0)
+	if span.TraceID().String() != "5b8efff798038103d269b633813fc60c" || span.SpanID().String() != "eee19b7ec3c1b174" {
+		t.Errorf("IDs = %s %s", span.TraceID(), span.SpanID())
+	}
+	if span.Kind() != ptrace.SpanKindServer || span.Status().Code() != ptrace.StatusCodeError || span.Status().Message() != "Connection refused" {
+		t.Errorf("kind = %v, status = %v %q", span.Kind(), span.Status().Code(), span.Status().Message())
+	}
+	if got := span.Attributes().AsRaw(); len(got) != 1 || got["http.status_code"] != int64(503) {
+		t.Errorf("span attributes = %v, want only http.status_code", got)
+	}
+	if got := span.EndTimestamp() - span.StartTimestamp(); got != 250_000_000 {
+		t.Errorf("duration = %d ns, want 250ms", got)
+	}
+	event := span.Events().At(0)
+	if event.Name() != "exception" || event.Timestamp() != 1684847166200000000 {
+		t.Errorf("event = %q at %d", event.Name(), event.Timestamp())
+	}
+	if got := event.Attributes().AsRaw(); got["exception.type"] != "requests.exceptions.ConnectionError" || got["exception.escaped"] != false {
+		t.Errorf("event attributes = %v", got)
+	}
+
+	child := td.ResourceSpans().At(1).ScopeSpans().At(0).Spans().At(0)
+	if child.ParentSpanID() != span.SpanID() || child.Kind() != ptrace.SpanKindClient {
+		t.Errorf("child parent = %s, kind = %v", child.ParentSpanID(), child.Kind())
+	}
+	if got := child.Attributes().AsRaw(); got["db.rows"] != int64(12) || got["cache.ratio"] != 0.25 {
+		t.Errorf("child attributes = %v", got)
+	}
+	if got := child.Events().At(0); got.Name() != "" || got.Attributes().AsRaw()["message"] != "cache miss" {
+		t.Errorf("log without event field = %q %v", got.Name(), got.Attributes().AsRaw())
+	}
+}
+
+func TestDecodeTraces_Zipkin(t *testing.T) {
+	td := decodeFile(t, "testdata/zipkin.json")
+	if n := td.ResourceSpans().Len(); n != 1 {
+		t.Fatalf("got %d resources, want 1", n)
+	}
+	rs := td.ResourceSpans().At(0)
+	if got, _ := rs.Resource().Attributes().Get("service.name"); got.Str() != "checkout" {
+		t.Errorf("service.name = %q", got.Str())
+	}
+	if n := rs.ScopeSpans().Len(); n != 2 {
+		t.Fatalf("got %d scopes, want 2", n)
+	}
+
+	server := rs.ScopeSpans().At(0).Spans().At(0)
+	if rs.ScopeSpans().At(0).Scope().Name() != "checkout-instrumentation" {
+		t.Errorf("scope = %q", rs.ScopeSpans().At(0).Scope().Name())
+	}
+	// 64-bit trace IDs are padded
+	if server.TraceID().String() != "0000000000000000d269b633813fc60c" {
+		t.Errorf("trace ID = %s", server.TraceID())
+	}
+	if server.Kind() != ptrace.SpanKindServer || server.Status().Code() != ptrace.StatusCodeError || server.Status().Message() != "payment timed out" {
+		t.Errorf("kind = %v, status = %v %q", server.Kind(), server.Status().Code(), server.Status().Message())
+	}
+	if got := server.Attributes().AsRaw(); len(got) != 1 || got["http.method"] != "POST" {
+		t.Errorf("span attributes = %v, want only http.method", got)
+	}
+	if n := server.Events().Len(); n != 2 || server.Events().At(0).Name() != "cache.miss" {
+		t.Fatalf("got %d events", n)
+	}
+	exception := server.Events().At(1)
+	if got := exception.Attributes().AsRaw(); exception.Name() != "exception" || got["exception.type"] != "TimeoutError" || got["exception.message"] != "payment timed out" {
+		t.Errorf("collector annotation = %q %v", exception.Name(), got)
+	}
+
+	client := rs.ScopeSpans().At(1).Spans().At(0)
+	if client.ParentSpanID() != server.SpanID() || client.Kind() != ptrace.SpanKindClient || client.Status().Code() != ptrace.StatusCodeUnset {
+		t.Errorf("client parent = %s, kind = %v, status = %v", client.ParentSpanID(), client.Kind(), client.Status().Code())
+	}
+	if got := client.Attributes().AsRaw(); got["peer.service"] != "payment" || got["network.peer.port"] != int64(50051) {
+		t.Errorf("client attributes = %v", got)
+	}
+	if got := client.Events().At(0); got.Name() != "exception" || got.Attributes().AsRaw()["exception.type"] != "grpc.DeadlineExceeded" {
+		t.Errorf("SDK annotation = %q %v", got.Name(), got.Attributes().AsRaw())
+	}
+}
+
+func TestDecodeTraces_Formats(t *testing.T) {
+	otlp := `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174","name":"otlp"}]}]}]}`
+	jaeger := `{"traceID":"1","spans":[{"traceID":"1","spanID":"2","operationName":"jaeger","processID":"p1"}],"processes":{"p1":{"serviceName":"svc"}}}`
+	zipkin := `[{"traceId":"1","id":"2","name":"zipkin"}]`
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr string
+	}{
+		{name: "mixed documents", content: otlp + "\n" + jaeger + "\n" + zipkin, want: []string{"otlp", "jaeger", "zipkin"}},
+		{name: "jaeger v3 query API", content: `{"result":` + otlp + `}`, want: []string{"otlp"}},
+		{name: "empty", content: " \n"},
+		{name: "unknown process", content: strings.Replace(jaeger, `"processID":"p1"`, `"processID":"p9"`, 1), wantErr: `failed to read Jaeger JSON traces: span 2 refers to unknown process "p9"`},
+		{name: "invalid zipkin ID", content: `[{"traceId":"xyz","id":"2"}]`, wantErr: `failed to read Zipkin v2 JSON traces: span 2: invalid trace ID "xyz"`},
+		{name: "invalid jaeger tag", content: strings.Replace(jaeger, `"processID"`, `"tags":[{"key":"n","type":"int64","value":"x"}],"processID"`, 1), wantErr: `invalid int64 tag "n"`},
+		{name: "invalid json", content: `{"resourceSpans":`, wantErr: "failed to read JSON traces"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			batches, err := DecodeTraces([]byte(tc.content))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("DecodeTraces() error = %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeTraces() error = %v", err)
+			}
+			var names []string
+			for _, td := range batches {
+				names = append(names, td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name())
+			}
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got spans %v, want %v", names, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTraces_Protobuf(t *testing.T) {
+	td := ptrace.NewTraces()
+	td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("GET")
+	content, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches, err := DecodeTraces(content)
+	if err != nil {
+		t.Fatalf("DecodeTraces() error = %v", err)
+	}
+	if len(batches) != 1 || batches[0].SpanCount() != 1 {
+		t.Errorf("got %d batches, want one with one span", len(batches))
+	}
+}
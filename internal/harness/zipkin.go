@@ -0,0 +1,148 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package harness
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// zipkinSpan is a span in the Zipkin v2 JSON format
+type zipkinSpan struct {
+	TraceID  string `json:"traceId"`
+	ID       string `json:"id"`
+	ParentID string `json:"parentId"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	// Timestamp and Duration are in microseconds
+	Timestamp      int64              `json:"timestamp"`
+	Duration       int64              `json:"duration"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int64  `json:"port"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// zipkinToTraces translates Zipkin v2 JSON: a list of spans, as the Zipkin API receives
+// them, or a list of traces, as it returns them. Annotations become span events named by
+// their value, and the local endpoint becomes the resource.
+func zipkinToTraces(doc []byte) (ptrace.Traces, error) {
+	var spans []zipkinSpan
+	if inner := bytes.TrimLeft(doc[1:], " \t\r\n"); len(inner) > 0 && inner[0] == '[' {
+		var traces [][]zipkinSpan
+		if err := json.Unmarshal(doc, &traces); err != nil {
+			return ptrace.Traces{}, err
+		}
+		for _, trace := range traces {
+			spans = append(spans, trace...)
+		}
+	} else if err := json.Unmarshal(doc, &spans); err != nil {
+		return ptrace.Traces{}, err
+	}
+
+	b := newResourceBuilder()
+	for _, zs := range spans {
+		if err := b.addZipkinSpan(zs); err != nil {
+			return ptrace.Traces{}, fmt.Errorf("span %s: %w", zs.ID, err)
+		}
+	}
+	return b.traces, nil
+}
+
+func (b *resourceBuilder) addZipkinSpan(zs zipkinSpan) error {
+	resource := pcommon.NewMap()
+	if zs.LocalEndpoint != nil && zs.LocalEndpoint.ServiceName != "" {
+		resource.PutStr("service.name", zs.LocalEndpoint.ServiceName)
+	}
+	attrs := pcommon.NewMap()
+	for key, value := range zs.Tags {
+		attrs.PutStr(key, value)
+	}
+	if zs.Kind != "" {
+		attrs.PutStr("span.kind", zs.Kind)
+	} else if _, ok := attrs.Get("span.kind"); !ok {
+		attrs.PutStr("span.kind", "internal")
+	}
+	if remote := zs.RemoteEndpoint; remote != nil {
+		if remote.ServiceName != "" {
+			attrs.PutStr("peer.service", remote.ServiceName)
+		}
+		if address := remote.IPv4 + remote.IPv6; address != "" {
+			attrs.PutStr("network.peer.address", address)
+		}
+		if remote.Port != 0 {
+			attrs.PutInt("network.peer.port", remote.Port)
+		}
+	}
+
+	span := b.span(resource, attrs)
+	span.SetName(zs.Name)
+	traceID, err := parseTraceID(zs.TraceID)
+	if err != nil {
+		return err
+	}
+	span.SetTraceID(traceID)
+	spanID, err := parseSpanID(zs.ID)
+	if err != nil {
+		return err
+	}
+	span.SetSpanID(spanID)
+	if zs.ParentID != "" {
+		parent, err := parseSpanID(zs.ParentID)
+		if err != nil {
+			return err
+		}
+		span.SetParentSpanID(parent)
+	}
+	span.SetStartTimestamp(microsToTimestamp(zs.Timestamp))
+	span.SetEndTimestamp(microsToTimestamp(zs.Timestamp + zs.Duration))
+
+	for _, annotation := range zs.Annotations {
+		event := span.Events().AppendEmpty()
+		event.SetTimestamp(microsToTimestamp(annotation.Timestamp))
+		name, attributes := parseAnnotation(annotation.Value)
+		event.SetName(name)
+		if err := event.Attributes().FromRaw(attributes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseAnnotation splits the value of an annotation into the event name and attributes.
+// OpenTelemetry exporters append the attributes of span events as a JSON object, either
+// as "name|{...}|dropped" (collector) or "name: {...}" (SDKs). Other values are the name.
+func parseAnnotation(value string) (string, map[string]any) {
+	for _, sep := range []string{"|{", ": {"} {
+		idx := strings.Index(value, sep)
+		if idx < 0 {
+			continue
+		}
+		rest := value[idx+len(sep)-1:]
+		end := strings.LastIndex(rest, "}")
+		var attributes map[string]any
+		if end < 0 || json.Unmarshal([]byte(rest[:end+1]), &attributes) != nil {
+			continue
+		}
+		return value[:idx], attributes
+	}
+	return strings.TrimSpace(value), nil
+}
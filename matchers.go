@@ -0,0 +1,563 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlresource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspan"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// nativeTarget is the telemetry a native matcher inspects. Only the fields
+// reachable from the matcher's context are set.
+type nativeTarget struct {
+	resource pcommon.Resource
+	span     ptrace.Span
+	event    ptrace.SpanEvent
+}
+
+// nativeMatcher is a condition compiled to Go. It must give the same answer as
+// the OTTL condition it replaces.
+type nativeMatcher func(nativeTarget) bool
+
+// matcherCondition is one configured condition. native is set when the condition has a
+// simple shape; the parsed OTTL condition is always kept for the ConditionSequence.
+type matcherCondition[K any] struct {
+	text   string
+	native nativeMatcher
+	ottl   *ottl.Condition[K]
+}
+
+// newMatcherCondition compiles a native matcher for condition when it has a simple shape
+func newMatcherCondition[K any](condition, contextName string, parsed *ottl.Condition[K]) matcherCondition[K] {
+	native, _ := compileNativeCondition(condition, contextName)
+	return matcherCondition[K]{text: condition, native: native, ottl: parsed}
+}
+
+// conditionErrorMessage is what ottl.ConditionSequence logs for a condition that failed
+// under the ignore error mode
+const conditionErrorMessage = "failed to eval condition"
+
+// conditionMatcher evaluates one condition list with an OTTL ConditionSequence. The native
+// matchers of the conditions that lead the list run first as a pre-filter: they decide the
+// result where the sequence would stop at them, and the OTTL transform context is only
+// built when the sequence has to run.
+type conditionMatcher[K any] struct {
+	matchAll bool
+	// native holds the matchers of the leading native conditions
+	native []nativeMatcher
+	// sequence evaluates the conditions the pre-filter cannot decide; it is nil when every
+	// condition is native
+	sequence  *ottl.ConditionSequence[K]
+	errorMode ottl.ErrorMode
+	// errorCounter counts evaluation errors; it is nil when only validating
+	errorCounter metric.Int64Counter
+}
+
+// newConditionMatcher returns nil when there are no conditions
+func newConditionMatcher[K any](conditions []matcherCondition[K], settings component.TelemetrySettings, telemetry *metadata.TelemetryBuilder, errorMode ottl.ErrorMode, match MatchMode) *conditionMatcher[K] {
+	if len(conditions) == 0 {
+		return nil
+	}
+	m := &conditionMatcher[K]{
+		matchAll:  match == MatchAll,
+		errorMode: errorMode,
+	}
+	if telemetry != nil {
+		m.errorCounter = telemetry.ConnectorSpaneventstologOttlErrors
+	}
+	leading := 0
+	for leading < len(conditions) && conditions[leading].native != nil {
+		m.native = append(m.native, conditions[leading].native)
+		leading++
+	}
+	if leading == len(conditions) {
+		return m
+	}
+
+	// With "any" the leading native conditions did not match, so the sequence starts after
+	// them. With "all" they matched, which the sequence has to know when the remaining
+	// conditions all fail, so it evaluates every condition.
+	rest := conditions[leading:]
+	if m.matchAll {
+		rest = conditions
+	}
+	parsed := make([]*ottl.Condition[K], len(rest))
+	for i, cond := range rest {
+		parsed[i] = cond.ottl
+	}
+	logicOp := ottl.Or
+	if m.matchAll {
+		logicOp = ottl.And
+	}
+	// Silent runs as ignore with the failure logs dropped, so both count their errors
+	sequenceErrorMode := errorMode
+	if errorMode == ottl.SilentError {
+		sequenceErrorMode = ottl.IgnoreError
+	}
+	if errorMode != ottl.PropagateError {
+		settings.Logger = settings.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &conditionErrorCore{Core: core, counter: m.errorCounter, silent: errorMode == ottl.SilentError}
+		}))
+	}
+	sequence := ottl.NewConditionSequence(parsed, settings,
+		ottl.WithConditionSequenceErrorMode[K](sequenceErrorMode),
+		ottl.WithLogicOperation[K](logicOp),
+	)
+	m.sequence = &sequence
+	return m
+}
+
+// eval evaluates the conditions in order. An error is only returned when error_mode is propagate.
+func (m *conditionMatcher[K]) eval(ctx context.Context, target nativeTarget, newTCtx func() K) (bool, error) {
+	for _, native := range m.native {
+		match := native(target)
+		if match != m.matchAll {
+			return match, nil
+		}
+	}
+	if m.sequence == nil {
+		// Every condition is native: none matched with "any", all matched with "all"
+		return m.matchAll, nil
+	}
+	match, err := m.sequence.Eval(ctx, newTCtx())
+	if err != nil && m.errorCounter != nil {
+		m.errorCounter.Add(ctx, 1, conditionErrorAttributes)
+	}
+	return match, err
+}
+
+// conditionErrorCore counts the condition failures a ConditionSequence logs under the ignore
+// error mode, and drops their logs for the silent error mode
+type conditionErrorCore struct {
+	zapcore.Core
+	counter metric.Int64Counter
+	silent  bool
+}
+
+// Enabled is true for warnings, so that failures are counted when warnings are not logged
+func (c *conditionErrorCore) Enabled(level zapcore.Level) bool {
+	return level == zapcore.WarnLevel || c.Core.Enabled(level)
+}
+
+func (c *conditionErrorCore) With(fields []zapcore.Field) zapcore.Core {
+	return &conditionErrorCore{Core: c.Core.With(fields), counter: c.counter, silent: c.silent}
+}
+
+func (c *conditionErrorCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level == zapcore.WarnLevel && entry.Message == conditionErrorMessage {
+		if c.counter != nil {
+			c.counter.Add(context.Background(), 1, conditionErrorAttributes)
+		}
+		if c.silent {
+			return checked
+		}
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// compileNativeCondition compiles condition to a native matcher when it has one of these shapes:
+//
+//	name == "checkout"                          (also !=, and span.name, spanevent.name)
+//	name == "exception" or name == "error"      (name sets, any comparisons joined by or)
+//	attributes["http.method"] == "GET"          (string, int, bool and nil literals)
+//	attributes["exception.stacktrace"] != nil   (attribute existence)
+//	status.code == STATUS_CODE_ERROR            (span status code, enum or int)
+//
+// contextName is the OTTL context the condition was parsed in; it resolves paths without
+// a context prefix. The condition must already have been parsed successfully by OTTL.
+// It reports false for every other shape, which then runs through OTTL.
+func compileNativeCondition(condition, contextName string) (nativeMatcher, bool) {
+	tokens, ok := tokenizeCondition(condition)
+	if !ok {
+		return nil, false
+	}
+	p := &conditionParser{tokens: tokens}
+
+	var clauses []nativeClause
+	for {
+		clause, ok := p.parseClause(contextName)
+		if !ok {
+			return nil, false
+		}
+		clauses = append(clauses, clause)
+		if p.done() {
+			break
+		}
+		if !p.accept(tokenIdent, "or") {
+			return nil, false
+		}
+	}
+
+	if len(clauses) == 1 {
+		return clauses[0].matcher(), true
+	}
+	if set, ok := newNameSetMatcher(clauses); ok {
+		return set, true
+	}
+	matchers := make([]nativeMatcher, len(clauses))
+	for i, clause := range clauses {
+		matchers[i] = clause.matcher()
+	}
+	return func(t nativeTarget) bool {
+		for _, m := range matchers {
+			if m(t) {
+				return true
+			}
+		}
+		return false
+	}, true
+}
+
+// fieldKind is the kind of telemetry field a native clause compares
+type fieldKind int
+
+const (
+	fieldName fieldKind = iota
+	fieldAttribute
+	fieldStatusCode
+)
+
+// nativeField is a resolved path such as span.name or resource.attributes["service.name"]
+type nativeField struct {
+	kind       fieldKind
+	context    string
+	key        string
+	name       func(nativeTarget) string
+	attributes func(nativeTarget) pcommon.Map
+}
+
+func (f nativeField) sameAs(other nativeField) bool {
+	return f.kind == other.kind && f.context == other.context && f.key == other.key
+}
+
+type literalKind int
+
+const (
+	literalString literalKind = iota
+	literalInt
+	literalBool
+	literalNil
+)
+
+type nativeLiteral struct {
+	kind literalKind
+	str  string
+	num  int64
+	bool bool
+}
+
+// nativeClause is a single `path == literal` or `path != literal` comparison
+type nativeClause struct {
+	field   nativeField
+	negate  bool
+	literal nativeLiteral
+}
+
+func (c nativeClause) matcher() nativeMatcher {
+	negate := c.negate
+	switch c.field.kind {
+	case fieldName:
+		name, want := c.field.name, c.literal.str
+		return func(t nativeTarget) bool {
+			return (name(t) == want) != negate
+		}
+	case fieldStatusCode:
+		want := c.literal.num
+		return func(t nativeTarget) bool {
+			return (int64(t.span.Status().Code()) == want) != negate
+		}
+	default:
+		attributes, key, literal := c.field.attributes, c.field.key, c.literal
+		return func(t nativeTarget) bool {
+			v, ok := attributes(t).Get(key)
+			return attributeEquals(v, ok, literal) != negate
+		}
+	}
+}
+
+// attributeEquals compares an attribute value with a literal following the OTTL comparison rules
+func attributeEquals(v pcommon.Value, ok bool, literal nativeLiteral) bool {
+	switch literal.kind {
+	case literalNil:
+		// OTTL reads empty values and empty byte slices as nil
+		return !ok || v.Type() == pcommon.ValueTypeEmpty || (v.Type() == pcommon.ValueTypeBytes && v.Bytes().Len() == 0)
+	case literalString:
+		return ok && v.Type() == pcommon.ValueTypeStr && v.Str() == literal.str
+	case literalInt:
+		if !ok {
+			return false
+		}
+		switch v.Type() {
+		case pcommon.ValueTypeInt:
+			return v.Int() == literal.num
+		case pcommon.ValueTypeDouble:
+			return v.Double() == float64(literal.num)
+		default:
+			return false
+		}
+	case literalBool:
+		return ok && v.Type() == pcommon.ValueTypeBool && v.Bool() == literal.bool
+	default:
+		return false
+	}
+}
+
+// newNameSetMatcher turns `name == "a" or name == "b" or ...` into a single set lookup
+func newNameSetMatcher(clauses []nativeClause) (nativeMatcher, bool) {
+	first := clauses[0].field
+	if first.kind != fieldName {
+		return nil, false
+	}
+	names := make(map[string]struct{}, len(clauses))
+	for _, clause := range clauses {
+		if clause.negate || !clause.field.sameAs(first) {
+			return nil, false
+		}
+		names[clause.literal.str] = struct{}{}
+	}
+	name := first.name
+	return func(t nativeTarget) bool {
+		_, ok := names[name(t)]
+		return ok
+	}, true
+}
+
+// statusCodes are the OTTL enum symbols accepted for status.code
+var statusCodes = map[string]int64{
+	"STATUS_CODE_UNSET": int64(ptrace.StatusCodeUnset),
+	"STATUS_CODE_OK":    int64(ptrace.StatusCodeOk),
+	"STATUS_CODE_ERROR": int64(ptrace.StatusCodeError),
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenInt
+	tokenPunct
+)
+
+type conditionToken struct {
+	kind  tokenKind
+	value string
+}
+
+// tokenizeCondition splits a condition into the few tokens the native shapes use.
+// It reports false for any other syntax, including escaped strings, floats and calls.
+func tokenizeCondition(condition string) ([]conditionToken, bool) {
+	var tokens []conditionToken
+	for i := 0; i < len(condition); {
+		ch := condition[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '"':
+			end := strings.IndexAny(condition[i+1:], `"\`)
+			if end < 0 || condition[i+1+end] != '"' {
+				return nil, false
+			}
+			tokens = append(tokens, conditionToken{kind: tokenString, value: condition[i+1 : i+1+end]})
+			i += end + 2
+		case ch == '=' || ch == '!':
+			if i+1 >= len(condition) || condition[i+1] != '=' {
+				return nil, false
+			}
+			tokens = append(tokens, conditionToken{kind: tokenPunct, value: condition[i : i+2]})
+			i += 2
+		case ch == '.' || ch == '[' || ch == ']':
+			tokens = append(tokens, conditionToken{kind: tokenPunct, value: condition[i : i+1]})
+			i++
+		case ch == '-' || isDigit(ch):
+			start := i
+			i++
+			for i < len(condition) && isDigit(condition[i]) {
+				i++
+			}
+			if i < len(condition) && (condition[i] == '.' || isIdentChar(condition[i])) {
+				return nil, false
+			}
+			tokens = append(tokens, conditionToken{kind: tokenInt, value: condition[start:i]})
+		case isIdentChar(ch):
+			start := i
+			for i < len(condition) && isIdentChar(condition[i]) {
+				i++
+			}
+			tokens = append(tokens, conditionToken{kind: tokenIdent, value: condition[start:i]})
+		default:
+			return nil, false
+		}
+	}
+	return tokens, true
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || isDigit(ch) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+func (p *conditionParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *conditionParser) accept(kind tokenKind, value string) bool {
+	if p.done() || p.tokens[p.pos].kind != kind || p.tokens[p.pos].value != value {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *conditionParser) next(kind tokenKind) (string, bool) {
+	if p.done() || p.tokens[p.pos].kind != kind {
+		return "", false
+	}
+	p.pos++
+	return p.tokens[p.pos-1].value, true
+}
+
+// parseClause parses `path (==|!=) literal`
+func (p *conditionParser) parseClause(contextName string) (nativeClause, bool) {
+	var segments []string
+	for {
+		segment, ok := p.next(tokenIdent)
+		if !ok {
+			return nativeClause{}, false
+		}
+		segments = append(segments, segment)
+		if !p.accept(tokenPunct, ".") {
+			break
+		}
+	}
+	var key string
+	hasKey := p.accept(tokenPunct, "[")
+	if hasKey {
+		var ok bool
+		if key, ok = p.next(tokenString); !ok || !p.accept(tokenPunct, "]") {
+			return nativeClause{}, false
+		}
+	}
+
+	field, ok := resolveNativeField(segments, hasKey, key, contextName)
+	if !ok {
+		return nativeClause{}, false
+	}
+
+	var clause nativeClause
+	switch {
+	case p.accept(tokenPunct, "=="):
+	case p.accept(tokenPunct, "!="):
+		clause.negate = true
+	default:
+		return nativeClause{}, false
+	}
+
+	literal, ok := p.parseLiteral()
+	if !ok {
+		return nativeClause{}, false
+	}
+
+	// Only comparisons whose outcome depends on the data are compiled
+	switch field.kind {
+	case fieldName:
+		ok = literal.kind == literalString
+	case fieldStatusCode:
+		ok = literal.kind == literalInt
+	}
+	if !ok {
+		return nativeClause{}, false
+	}
+
+	clause.field = field
+	clause.literal = literal
+	return clause, true
+}
+
+func (p *conditionParser) parseLiteral() (nativeLiteral, bool) {
+	if p.done() {
+		return nativeLiteral{}, false
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch tok.kind {
+	case tokenString:
+		return nativeLiteral{kind: literalString, str: tok.value}, true
+	case tokenInt:
+		num, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nativeLiteral{}, false
+		}
+		return nativeLiteral{kind: literalInt, num: num}, true
+	case tokenIdent:
+		switch tok.value {
+		case "nil":
+			return nativeLiteral{kind: literalNil}, true
+		case "true", "false":
+			return nativeLiteral{kind: literalBool, bool: tok.value == "true"}, true
+		}
+		if code, ok := statusCodes[tok.value]; ok {
+			return nativeLiteral{kind: literalInt, num: code}, true
+		}
+	}
+	return nativeLiteral{}, false
+}
+
+// resolveNativeField maps a path to the telemetry it reads. Paths without a known context
+// prefix belong to contextName, exactly like OTTL resolves them.
+func resolveNativeField(segments []string, hasKey bool, key, contextName string) (nativeField, bool) {
+	pathContext := contextName
+	switch segments[0] {
+	case ottlresource.ContextName, ottlspan.ContextName, ottlspanevent.ContextName:
+		if len(segments) > 1 {
+			pathContext = segments[0]
+			segments = segments[1:]
+		}
+	}
+	path := strings.Join(segments, ".")
+
+	field := nativeField{context: pathContext, key: key}
+	switch {
+	case pathContext == ottlresource.ContextName && path == "attributes" && hasKey:
+		field.kind = fieldAttribute
+		field.attributes = func(t nativeTarget) pcommon.Map { return t.resource.Attributes() }
+	case pathContext == ottlspan.ContextName && path == "name" && !hasKey:
+		field.kind = fieldName
+		field.name = func(t nativeTarget) string { return t.span.Name() }
+	case pathContext == ottlspan.ContextName && path == "attributes" && hasKey:
+		field.kind = fieldAttribute
+		field.attributes = func(t nativeTarget) pcommon.Map { return t.span.Attributes() }
+	case pathContext == ottlspan.ContextName && path == "status.code" && !hasKey:
+		field.kind = fieldStatusCode
+	case pathContext == ottlspanevent.ContextName && path == "name" && !hasKey:
+		field.kind = fieldName
+		field.name = func(t nativeTarget) string { return t.event.Name() }
+	case pathContext == ottlspanevent.ContextName && path == "attributes" && hasKey:
+		field.kind = fieldAttribute
+		field.attributes = func(t nativeTarget) pcommon.Map { return t.event.Attributes() }
+	default:
+		return nativeField{}, false
+	}
+	return field, true
+}
@@ -0,0 +1,250 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/connector/connectortest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestNativeConditions_MatchOTTL(t *testing.T) {
+	tests := []struct {
+		condition string
+		native    bool
+	}{
+		{condition: `name == "exception"`, native: true},
+		{condition: `spanevent.name != "exception"`, native: true},
+		{condition: `name == "exception" or name == "checkout"`, native: true},
+		{condition: `span.name == "GET /api/cart" or attributes["retry"] == true`, native: true},
+		{condition: `attributes["exception.type"] == "requests.exceptions.ConnectionError"`, native: true},
+		{condition: `attributes["exception.stacktrace"] != nil`, native: true},
+		{condition: `attributes["missing"] == nil`, native: true},
+		{condition: `attributes["empty"] == nil`, native: true},
+		{condition: `attributes["count"] == 3`, native: true},
+		{condition: `attributes["ratio"] == 3`, native: true},
+		{condition: `attributes["count"] != -1`, native: true},
+		{condition: `attributes["count"] == "3"`, native: true},
+		{condition: `span.attributes["http.status_code"] == 503`, native: true},
+		{condition: `span.status.code == STATUS_CODE_ERROR`, native: true},
+		{condition: `span.status.code != 0`, native: true},
+		{condition: `resource.attributes["service.name"] == "loadgenerator"`, native: true},
+		{condition: `name == "exception" and attributes["count"] == 3`, native: false},
+		{condition: `IsMatch(name, "^exc")`, native: false},
+		{condition: `attributes["ratio"] == 3.0`, native: false},
+		{condition: `name == "with \"quotes\""`, native: false},
+		{condition: `"exception" == name`, native: false},
+		{condition: `attributes["count"] > 2`, native: false},
+	}
+
+	td := newTestTraces("GET /api/cart", "exception", "checkout", "retry")
+	rs := td.ResourceSpans().At(0)
+	ss := rs.ScopeSpans().At(0)
+	span := ss.Spans().At(0)
+	span.Status().SetCode(ptrace.StatusCodeError)
+	events := span.Events()
+	events.At(0).Attributes().PutStr("exception.stacktrace", "Traceback")
+	events.At(0).Attributes().PutInt("count", 3)
+	events.At(1).Attributes().PutDouble("ratio", 3)
+	events.At(1).Attributes().PutEmpty("empty")
+	events.At(2).Attributes().PutBool("retry", true)
+	events.At(2).Attributes().PutStr("count", "3")
+
+	pc, err := newParserCollection(componenttest.NewNopTelemetrySettings(), defaultSpanFunctions(), defaultSpanEventFunctions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			parsed, err := pc.ParseConditionsWithContext(ottlspanevent.ContextName, ottl.NewConditionsGetter([]string{tt.condition}), true)
+			if err != nil {
+				t.Fatalf("ParseConditionsWithContext() error = %v", err)
+			}
+			native, ok := compileNativeCondition(tt.condition, ottlspanevent.ContextName)
+			if ok != tt.native {
+				t.Fatalf("compileNativeCondition() ok = %v, want %v", ok, tt.native)
+			}
+			if !ok {
+				return
+			}
+			for i := 0; i < events.Len(); i++ {
+				event := events.At(i)
+				want, err := parsed.spanEvent[0].Eval(context.Background(), ottlspanevent.NewTransformContext(event, span, ss.Scope(), rs.Resource(), ss, rs))
+				if err != nil {
+					t.Fatalf("Eval() error = %v", err)
+				}
+				if got := native(nativeTarget{resource: rs.Resource(), span: span, event: event}); got != want {
+					t.Errorf("event %q: native = %v, OTTL = %v", event.Name(), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConditionMatcher_MatchesConditionSequence(t *testing.T) {
+	const (
+		nativeTrue  = `name == "exception"`
+		nativeFalse = `name == "checkout"`
+		ottlTrue    = `IsMatch(name, "^exc")`
+		ottlFalse   = `IsMatch(name, "^check")`
+		ottlError   = `Substring(name, 100, 5) == "x"`
+	)
+	lists := [][]string{
+		{nativeTrue},
+		{nativeFalse},
+		{nativeTrue, nativeFalse},
+		{nativeFalse, ottlTrue},
+		{nativeTrue, ottlError},
+		{nativeFalse, ottlError},
+		{ottlError, nativeTrue},
+		{ottlError, nativeFalse},
+		{ottlError, ottlError},
+		{ottlFalse, nativeTrue, ottlError},
+		{nativeTrue, ottlTrue, nativeFalse},
+		{nativeTrue, nativeTrue, ottlError},
+	}
+
+	td := newTestTraces("GET /api/cart", "exception")
+	rs := td.ResourceSpans().At(0)
+	ss := rs.ScopeSpans().At(0)
+	span := ss.Spans().At(0)
+	event := span.Events().At(0)
+	newTCtx := func() ottlspanevent.TransformContext {
+		return ottlspanevent.NewTransformContext(event, span, ss.Scope(), rs.Resource(), ss, rs)
+	}
+	settings := componenttest.NewNopTelemetrySettings()
+	pc, err := newParserCollection(settings, defaultSpanFunctions(), defaultSpanEventFunctions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, errorMode := range []ottl.ErrorMode{ottl.IgnoreError, ottl.SilentError, ottl.PropagateError} {
+		for _, match := range []MatchMode{MatchAny, MatchAll} {
+			for _, list := range lists {
+				t.Run(string(errorMode)+"/"+string(match)+"/"+strings.Join(list, ","), func(t *testing.T) {
+					var conditions []matcherCondition[ottlspanevent.TransformContext]
+					var parsed []*ottl.Condition[ottlspanevent.TransformContext]
+					for _, text := range list {
+						p, err := pc.ParseConditionsWithContext(ottlspanevent.ContextName, ottl.NewConditionsGetter([]string{text}), true)
+						if err != nil {
+							t.Fatal(err)
+						}
+						conditions = append(conditions, newMatcherCondition(text, ottlspanevent.ContextName, p.spanEvent[0]))
+						parsed = append(parsed, p.spanEvent[0])
+					}
+					logicOp := ottl.Or
+					if match == MatchAll {
+						logicOp = ottl.And
+					}
+					sequence := ottl.NewConditionSequence(parsed, settings,
+						ottl.WithConditionSequenceErrorMode[ottlspanevent.TransformContext](errorMode),
+						ottl.WithLogicOperation[ottlspanevent.TransformContext](logicOp))
+					want, wantErr := sequence.Eval(context.Background(), newTCtx())
+
+					matcher := newConditionMatcher(conditions, settings, nil, errorMode, match)
+					got, gotErr := matcher.eval(context.Background(), nativeTarget{resource: rs.Resource(), span: span, event: event}, newTCtx)
+					if got != want || (gotErr != nil) != (wantErr != nil) {
+						t.Errorf("eval() = %v, %v; ConditionSequence.Eval() = %v, %v", got, gotErr, want, wantErr)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestConnector_NativeAndOTTLConditionsKeepOrder(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	// The failing OTTL condition comes first, so it must still propagate its error
+	cfg.EventConditions = []string{`Substring(name, 100, 5) == "x"`, `name == "exception"`}
+	cfg.ErrorMode = ottl.PropagateError
+	conn, _ := newTestConnector(t, cfg)
+	if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception")); err == nil {
+		t.Error("ConsumeTraces() expected error")
+	}
+
+	// With "all" every condition errored but the native one, which matched
+	cfg.EventConditionsMatch = MatchAll
+	cfg.ErrorMode = ottl.IgnoreError
+	if records := consumeTestTraces(t, cfg, newTestTraces("GET /api/cart", "exception", "other")); len(records) != 1 {
+		t.Errorf("got %d records, want 1", len(records))
+	}
+}
+
+// loadRealisticTraces reads up to limit OTLP JSON files from the src/realistic_traces corpus
+func loadRealisticTraces(b *testing.B, limit int) []ptrace.Traces {
+	b.Helper()
+	var corpus []ptrace.Traces
+	unmarshaler := &ptrace.JSONUnmarshaler{}
+	err := filepath.WalkDir(filepath.Join("src", "realistic_traces"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") || len(corpus) >= limit {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		td, err := unmarshaler.UnmarshalTraces(data)
+		if err != nil {
+			return err
+		}
+		corpus = append(corpus, td)
+		return nil
+	})
+	if err != nil {
+		b.Skipf("realistic traces corpus not available: %v", err)
+	}
+	return corpus
+}
+
+func BenchmarkConsumeTraces_Conditions(b *testing.B) {
+	corpus := loadRealisticTraces(b, 1000)
+
+	// Appending `and true` keeps each condition's meaning but is not a native shape,
+	// so the "ottl" case runs the same conditions through OTTL
+	benchmarks := []struct {
+		name            string
+		spanConditions  []string
+		eventConditions []string
+	}{
+		{
+			name:            "native",
+			spanConditions:  []string{`status.code == STATUS_CODE_ERROR`, `attributes["http.status_code"] != nil`},
+			eventConditions: []string{`name == "exception" or name == "error"`},
+		},
+		{
+			name:            "ottl",
+			spanConditions:  []string{`status.code == STATUS_CODE_ERROR and true`, `attributes["http.status_code"] != nil and true`},
+			eventConditions: []string{`(name == "exception" or name == "error") and true`},
+		},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.SpanConditions = bm.spanConditions
+			cfg.EventConditions = bm.eventConditions
+			conn, err := NewSpanEventConnector(connectortest.NewNopSettings(metadata.Type), cfg, consumertest.NewNop())
+			if err != nil {
+				b.Fatal(err)
+			}
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, td := range corpus {
+					if err := conn.ConsumeTraces(ctx, td); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
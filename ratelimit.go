@@ -0,0 +1,422 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// RateLimitOverflow determines what happens to log records over the rate limit
+type RateLimitOverflow string
+
+const (
+	// RateLimitDrop drops records over the limit
+	RateLimitDrop RateLimitOverflow = "drop"
+	// RateLimitSample keeps one of every sample_rate records over the limit
+	RateLimitSample RateLimitOverflow = "sample"
+	// RateLimitMarker drops records over the limit and periodically emits one marker
+	// record per key with the number of suppressed records
+	RateLimitMarker RateLimitOverflow = "marker"
+)
+
+// RateLimitConfig limits the log records produced per value of a resource attribute
+type RateLimitConfig struct {
+	// KeyAttribute is the resource attribute whose values are limited separately
+	KeyAttribute string `mapstructure:"key_attribute"`
+
+	// RecordsPerSecond is the sustained number of records per key. 0 disables the limit
+	RecordsPerSecond float64 `mapstructure:"records_per_second"`
+
+	// Burst is the number of records a key may produce at once. It defaults to RecordsPerSecond
+	Burst int `mapstructure:"burst"`
+
+	// BytesPerMinute is the size of the records per key, as OTLP protobuf. 0 disables the limit
+	BytesPerMinute int64 `mapstructure:"bytes_per_minute"`
+
+	// Overflow is what happens to records over the limit: drop (default), sample or marker
+	Overflow RateLimitOverflow `mapstructure:"overflow"`
+
+	// SampleRate keeps one of every SampleRate records over the limit when Overflow is sample
+	SampleRate int `mapstructure:"sample_rate"`
+
+	// MarkerInterval is the minimum time between two marker records of a key
+	MarkerInterval time.Duration `mapstructure:"marker_interval"`
+
+	// MaxKeys bounds the number of keys tracked separately. Further keys share one limit
+	MaxKeys int `mapstructure:"max_keys"`
+}
+
+const (
+	defaultRateLimitKeyAttribute   = "service.name"
+	defaultRateLimitSampleRate     = 10
+	defaultRateLimitMarkerInterval = time.Minute
+	defaultRateLimitMaxKeys        = 1000
+
+	// rateLimitOverflowKey is the key shared by the keys that do not fit in the table
+	rateLimitOverflowKey = "_overflow"
+)
+
+func defaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		KeyAttribute:   defaultRateLimitKeyAttribute,
+		Overflow:       RateLimitDrop,
+		SampleRate:     defaultRateLimitSampleRate,
+		MarkerInterval: defaultRateLimitMarkerInterval,
+		MaxKeys:        defaultRateLimitMaxKeys,
+	}
+}
+
+func (cfg *RateLimitConfig) enabled() bool {
+	return cfg.RecordsPerSecond > 0 || cfg.BytesPerMinute > 0
+}
+
+func (cfg *RateLimitConfig) validate() error {
+	if cfg.RecordsPerSecond < 0 {
+		return fmt.Errorf("invalid rate_limit.records_per_second: %v, must not be negative", cfg.RecordsPerSecond)
+	}
+	if cfg.BytesPerMinute < 0 {
+		return fmt.Errorf("invalid rate_limit.bytes_per_minute: %d, must not be negative", cfg.BytesPerMinute)
+	}
+	if cfg.Burst < 0 {
+		return fmt.Errorf("invalid rate_limit.burst: %d, must not be negative", cfg.Burst)
+	}
+	if !cfg.enabled() {
+		return nil
+	}
+	if cfg.KeyAttribute == "" {
+		return fmt.Errorf("rate_limit.key_attribute must be specified")
+	}
+	switch cfg.Overflow {
+	case "", RateLimitDrop, RateLimitMarker:
+	case RateLimitSample:
+		if cfg.SampleRate < 1 {
+			return fmt.Errorf("invalid rate_limit.sample_rate: %d, must be positive", cfg.SampleRate)
+		}
+	default:
+		return fmt.Errorf("invalid rate_limit.overflow: %q, must be one of [%s %s %s]", cfg.Overflow, RateLimitDrop, RateLimitSample, RateLimitMarker)
+	}
+	if cfg.MarkerInterval < 0 {
+		return fmt.Errorf("invalid rate_limit.marker_interval: %v, must not be negative", cfg.MarkerInterval)
+	}
+	if cfg.MaxKeys <= 0 {
+		return fmt.Errorf("invalid rate_limit.max_keys: %d, must be positive", cfg.MaxKeys)
+	}
+	return nil
+}
+
+// rateLimitKind is the limit a record exceeded
+type rateLimitKind int
+
+const (
+	rateLimitNone rateLimitKind = iota - 1
+	rateLimitRecords
+	rateLimitBytes
+
+	numRateLimitKinds
+)
+
+var rateLimitKindNames = [numRateLimitKinds]string{"records", "bytes"}
+
+// tokenBucket refills perSecond tokens every second up to capacity
+type tokenBucket struct {
+	tokens    float64
+	capacity  float64
+	perSecond float64
+	last      time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.perSecond)
+	}
+	b.last = now
+}
+
+// rateLimitEntry is the limit state of one key
+type rateLimitEntry struct {
+	key string
+
+	mu      sync.Mutex
+	records *tokenBucket
+	bytes   *tokenBucket
+	// overLimit counts records over the limit, for sampling
+	overLimit int64
+	// suppressed counts the records dropped since the last marker
+	suppressed int64
+	// markerOutput is the output of the last suppressed record, which the marker follows
+	markerOutput int
+	// markerResource is the resource of the next marker, stamped with the tenant of the
+	// first suppressed records; nil until the converter set it
+	markerResource *pcommon.Resource
+	lastMarker     time.Time
+}
+
+// rateLimiter tracks the limits of up to MaxKeys keys, least recently used first out.
+// It is shared by all workers, so it is safe for concurrent use.
+type rateLimiter struct {
+	cfg       RateLimitConfig
+	telemetry *connectorTelemetry
+	now       func() time.Time
+
+	mu       sync.Mutex
+	order    *list.List // of *rateLimitEntry, most recently used first
+	entries  map[string]*list.Element
+	overflow *rateLimitEntry
+}
+
+// newRateLimiter returns nil when no limit is configured
+func newRateLimiter(cfg RateLimitConfig, telemetry *connectorTelemetry) *rateLimiter {
+	if !cfg.enabled() {
+		return nil
+	}
+	if cfg.Overflow == "" {
+		cfg.Overflow = RateLimitDrop
+	}
+	l := &rateLimiter{
+		cfg:       cfg,
+		telemetry: telemetry,
+		now:       time.Now,
+		order:     list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+	l.overflow = l.newEntry(rateLimitOverflowKey, l.now())
+	return l
+}
+
+func (l *rateLimiter) newEntry(key string, now time.Time) *rateLimitEntry {
+	entry := &rateLimitEntry{key: key, lastMarker: now}
+	if l.cfg.RecordsPerSecond > 0 {
+		burst := float64(l.cfg.Burst)
+		if burst == 0 {
+			burst = math.Max(1, math.Ceil(l.cfg.RecordsPerSecond))
+		}
+		entry.records = &tokenBucket{tokens: burst, capacity: burst, perSecond: l.cfg.RecordsPerSecond, last: now}
+	}
+	if l.cfg.BytesPerMinute > 0 {
+		capacity := float64(l.cfg.BytesPerMinute)
+		entry.bytes = &tokenBucket{tokens: capacity, capacity: capacity, perSecond: capacity / 60, last: now}
+	}
+	return entry
+}
+
+// entry returns the limit state for the key of resource
+func (l *rateLimiter) entry(ctx context.Context, resource pcommon.Resource) *rateLimitEntry {
+	key := ""
+	if v, ok := resource.Attributes().Get(l.cfg.KeyAttribute); ok {
+		key = v.AsString()
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[key]; ok {
+		l.order.MoveToFront(elem)
+		return elem.Value.(*rateLimitEntry)
+	}
+	if l.order.Len() >= l.cfg.MaxKeys {
+		// A key can only be forgotten once its buckets are full again, so that
+		// evicting it does not reset a limit that is still in effect
+		oldest := l.order.Back().Value.(*rateLimitEntry)
+		if !oldest.idle(now) {
+			return l.overflow
+		}
+		l.order.Remove(l.order.Back())
+		delete(l.entries, oldest.key)
+	} else {
+		l.telemetry.builder.ConnectorSpaneventstologRateLimitKeys.Add(ctx, 1)
+	}
+	entry := l.newEntry(key, now)
+	l.entries[key] = l.order.PushFront(entry)
+	return entry
+}
+
+// idle reports whether the entry's buckets are full at now and no marker is pending
+func (e *rateLimitEntry) idle(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, b := range []*tokenBucket{e.records, e.bytes} {
+		if b != nil {
+			b.refill(now)
+			if b.tokens < b.capacity {
+				return false
+			}
+		}
+	}
+	return e.suppressed == 0
+}
+
+// allow decides whether the next record of the key, routed to output, is produced. It
+// returns the limit the record exceeded, or rateLimitNone; sampled records exceed a limit
+// but are still produced. Nothing is taken from the budget until charge is called, so
+// records that end up not being produced are free.
+func (l *rateLimiter) allow(e *rateLimitEntry, output int) (produce bool, exceeded rateLimitKind) {
+	now := l.now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	exceeded = rateLimitNone
+	if e.records != nil {
+		e.records.refill(now)
+		if e.records.tokens < 1 {
+			exceeded = rateLimitRecords
+		}
+	}
+	// The size of a record is only known once it is built, so a key may go into debt
+	// with its last record and is limited until the debt is paid off
+	if exceeded == rateLimitNone && e.bytes != nil {
+		e.bytes.refill(now)
+		if e.bytes.tokens <= 0 {
+			exceeded = rateLimitBytes
+		}
+	}
+	if exceeded == rateLimitNone {
+		return true, rateLimitNone
+	}
+
+	e.overLimit++
+	if l.cfg.Overflow == RateLimitSample && e.overLimit%int64(l.cfg.SampleRate) == 1%int64(l.cfg.SampleRate) {
+		return true, exceeded
+	}
+	e.suppressed++
+	e.markerOutput = output
+	return false, exceeded
+}
+
+// charge takes a produced record, which allow returned exceeded for, from the key's budget.
+// Sampled records only take their size. As allow and charge are separate steps,
+// concurrent workers may let a key exceed its record limit by a few records.
+func (l *rateLimiter) charge(e *rateLimitEntry, record plog.LogRecord, exceeded rateLimitKind) {
+	var size int
+	if e.bytes != nil {
+		size = logRecordSizer.LogRecordSize(record)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.records != nil && exceeded == rateLimitNone {
+		e.records.tokens--
+	}
+	if e.bytes != nil {
+		e.bytes.tokens -= float64(size)
+	}
+}
+
+// rateLimitMarker reports the records of a key suppressed since its last marker
+type rateLimitMarker struct {
+	key        string
+	suppressed int64
+	// output is the output of the last suppressed record
+	output   int
+	resource pcommon.Resource
+}
+
+// needsMarkerResource reports whether the key has suppressed records for a marker that
+// has no resource yet
+func (l *rateLimiter) needsMarkerResource(e *rateLimitEntry) bool {
+	if l.cfg.Overflow != RateLimitMarker {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.suppressed > 0 && e.markerResource == nil
+}
+
+// setMarkerResource sets the resource of the key's next marker, unless it is already set
+func (l *rateLimiter) setMarkerResource(e *rateLimitEntry, resource pcommon.Resource) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.markerResource == nil {
+		e.markerResource = &resource
+	}
+}
+
+// takeMarker returns the marker of the key if one is due. force ignores marker_interval,
+// so that no suppressed records go unreported on shutdown.
+func (l *rateLimiter) takeMarker(e *rateLimitEntry, force bool) (rateLimitMarker, bool) {
+	if l.cfg.Overflow != RateLimitMarker {
+		return rateLimitMarker{}, false
+	}
+	now := l.now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.suppressed == 0 || e.markerResource == nil || (!force && now.Sub(e.lastMarker) < l.cfg.MarkerInterval) {
+		return rateLimitMarker{}, false
+	}
+	marker := rateLimitMarker{key: e.key, suppressed: e.suppressed, output: e.markerOutput, resource: *e.markerResource}
+	e.suppressed = 0
+	e.markerResource = nil
+	e.lastMarker = now
+	return marker, true
+}
+
+// takeMarkers returns the due markers of all keys, including those that had no records
+// since their records were suppressed
+func (l *rateLimiter) takeMarkers(force bool) []rateLimitMarker {
+	if l.cfg.Overflow != RateLimitMarker {
+		return nil
+	}
+	l.mu.Lock()
+	entries := make([]*rateLimitEntry, 0, l.order.Len()+1)
+	for elem := l.order.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, elem.Value.(*rateLimitEntry))
+	}
+	l.mu.Unlock()
+
+	var markers []rateLimitMarker
+	for _, e := range append(entries, l.overflow) {
+		if marker, ok := l.takeMarker(e, force); ok {
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
+
+// Attributes of the marker records
+const (
+	rateLimitKeyAttribute        = "rate_limit.key"
+	rateLimitSuppressedAttribute = "rate_limit.suppressed"
+)
+
+// appendRateLimitMarker appends the record of marker to logs
+func appendRateLimitMarker(logs plog.Logs, marker rateLimitMarker, keyAttribute string, now time.Time) {
+	rl := logs.ResourceLogs().AppendEmpty()
+	marker.resource.CopyTo(rl.Resource())
+	logRecord := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	logRecord.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	logRecord.SetObservedTimestamp(pcommon.NewTimestampFromTime(now))
+	logRecord.SetSeverityText("Warn")
+	logRecord.SetSeverityNumber(plog.SeverityNumberWarn)
+	logRecord.Body().SetStr(fmt.Sprintf("Rate limited: %d log records suppressed for %s=%s", marker.suppressed, keyAttribute, marker.key))
+	logRecord.Attributes().PutStr(rateLimitKeyAttribute, marker.key)
+	logRecord.Attributes().PutInt(rateLimitSuppressedAttribute, marker.suppressed)
+}
+
+// takeRateLimitMarkers returns the due rate limit markers of all keys, one plog.Logs per
+// output, or nil when none is due. The connector calls it periodically, so keys that
+// stopped sending records still get their marker.
+func (c *Converter) takeRateLimitMarkers(force bool) routedLogs {
+	if c.limiter == nil {
+		return nil
+	}
+	markers := c.limiter.takeMarkers(force)
+	if len(markers) == 0 {
+		return nil
+	}
+	out := newRoutedLogs(c.router.outputs())
+	now := time.Now()
+	for _, marker := range markers {
+		appendRateLimitMarker(out[marker.output], marker, c.config.RateLimit.KeyAttribute, now)
+	}
+	return out
+}
+
+// logRecordSizer measures records for the bytes_per_minute limit
+var logRecordSizer = &plog.ProtoMarshaler{}
@@ -0,0 +1,292 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// newRateLimitTestTraces returns one span with n exception events for each service
+func newRateLimitTestTraces(n int, services ...string) ptrace.Traces {
+	events := make([]string, n)
+	for i := range events {
+		events[i] = "exception"
+	}
+	td := ptrace.NewTraces()
+	for _, service := range services {
+		other := newTestTraces("GET /api/cart", events...)
+		other.ResourceSpans().At(0).Resource().Attributes().PutStr("service.name", service)
+		other.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	}
+	return td
+}
+
+// recordsByService counts the produced log records of each service, and the markers separately
+func recordsByService(sink *consumertest.LogsSink) (records map[string]int, markers []plog.LogRecord) {
+	records = make(map[string]int)
+	for _, logs := range sink.AllLogs() {
+		for i := 0; i < logs.ResourceLogs().Len(); i++ {
+			rl := logs.ResourceLogs().At(i)
+			service, _ := rl.Resource().Attributes().Get("service.name")
+			lrs := rl.ScopeLogs().At(0).LogRecords()
+			for j := 0; j < lrs.Len(); j++ {
+				if _, ok := lrs.At(j).Attributes().Get(rateLimitSuppressedAttribute); ok {
+					markers = append(markers, lrs.At(j))
+					continue
+				}
+				records[service.Str()]++
+			}
+		}
+	}
+	return records, markers
+}
+
+func TestConnector_RateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		overflow   RateLimitOverflow
+		produced   int
+		suppressed int64
+		markers    int
+	}{
+		{name: "drop", overflow: RateLimitDrop, produced: 2, suppressed: 8},
+		// Of the 8 records over the limit the 1st and 5th are kept
+		{name: "sample", overflow: RateLimitSample, produced: 4, suppressed: 6},
+		{name: "marker", overflow: RateLimitMarker, produced: 2, suppressed: 8, markers: 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.EventConditions = []string{`name == "exception"`}
+			cfg.RateLimit.RecordsPerSecond = 1
+			cfg.RateLimit.Burst = 2
+			cfg.RateLimit.Overflow = tc.overflow
+			cfg.RateLimit.SampleRate = 4
+			cfg.RateLimit.MarkerInterval = 0
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			sink := new(consumertest.LogsSink)
+			conn, tt := newTelemetryTestConnector(t, cfg, sink)
+			now := time.Now()
+			conn.converter.limiter.now = func() time.Time { return now }
+
+			if err := conn.ConsumeTraces(context.Background(), newRateLimitTestTraces(10, "checkout")); err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+			records, markers := recordsByService(sink)
+			if records["checkout"] != tc.produced {
+				t.Errorf("produced %d records, want %d", records["checkout"], tc.produced)
+			}
+			got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_rate_limited", attribute.String("key", "checkout"), attribute.String("limit", "records"))
+			if got != tc.suppressed {
+				t.Errorf("rate limited = %d, want %d", got, tc.suppressed)
+			}
+			if len(markers) != tc.markers {
+				t.Fatalf("got %d markers, want %d", len(markers), tc.markers)
+			}
+			if tc.markers > 0 {
+				v, _ := markers[0].Attributes().Get(rateLimitSuppressedAttribute)
+				if v.Int() != tc.suppressed {
+					t.Errorf("marker suppressed = %d, want %d", v.Int(), tc.suppressed)
+				}
+				if markers[0].SeverityNumber() != plog.SeverityNumberWarn {
+					t.Errorf("marker severity = %v, want Warn", markers[0].SeverityNumber())
+				}
+			}
+
+			// Once the bucket refilled the key may produce records again
+			now = now.Add(time.Second)
+			if err := conn.ConsumeTraces(context.Background(), newRateLimitTestTraces(1, "checkout")); err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+			if records, _ := recordsByService(sink); records["checkout"] != tc.produced+1 {
+				t.Errorf("produced %d records after refill, want %d", records["checkout"], tc.produced+1)
+			}
+		})
+	}
+}
+
+func TestConnector_RateLimitMarkerWithoutLaterBatches(t *testing.T) {
+	tests := []struct {
+		name string
+		// advance moves the clock past marker_interval before the connector shuts down
+		advance bool
+	}{
+		{name: "timer", advance: true},
+		{name: "shutdown"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.EventConditions = []string{`name == "exception"`}
+			cfg.RateLimit.RecordsPerSecond = 1
+			cfg.RateLimit.Burst = 2
+			cfg.RateLimit.Overflow = RateLimitMarker
+			cfg.RateLimit.MarkerInterval = 10 * time.Millisecond
+			sink := new(consumertest.LogsSink)
+			conn, _ := newTelemetryTestConnector(t, cfg, sink)
+			var now atomic.Int64
+			now.Store(time.Now().UnixNano())
+			conn.converter.limiter.now = func() time.Time { return time.Unix(0, now.Load()) }
+			if err := conn.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+				t.Fatal(err)
+			}
+
+			// The key goes quiet after its records were suppressed
+			if err := conn.ConsumeTraces(context.Background(), newRateLimitTestTraces(10, "checkout")); err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+			if _, markers := recordsByService(sink); len(markers) != 0 {
+				t.Fatalf("got %d markers before marker_interval passed, want 0", len(markers))
+			}
+			if tc.advance {
+				now.Add(int64(cfg.RateLimit.MarkerInterval))
+				deadline := time.Now().Add(5 * time.Second)
+				for _, markers := recordsByService(sink); len(markers) == 0 && time.Now().Before(deadline); _, markers = recordsByService(sink) {
+					time.Sleep(time.Millisecond)
+				}
+				if _, markers := recordsByService(sink); len(markers) != 1 {
+					t.Fatalf("got %d markers from the timer, want 1", len(markers))
+				}
+			}
+			if err := conn.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			_, markers := recordsByService(sink)
+			if len(markers) != 1 {
+				t.Fatalf("got %d markers, want 1", len(markers))
+			}
+			if v, _ := markers[0].Attributes().Get(rateLimitSuppressedAttribute); v.Int() != 8 {
+				t.Errorf("marker suppressed = %d, want 8", v.Int())
+			}
+		})
+	}
+}
+
+func TestConnector_RateLimitTransformerDrops(t *testing.T) {
+	dropRetries := funcTransformer{name: "drop_retries", fn: func(event EventContext, _ plog.LogRecord) (bool, error) {
+		return event.Event.Name() != "retry", nil
+	}}
+	cfg := NewFactoryWithOptions(WithTransformers(dropRetries)).CreateDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name != "health"`}
+	cfg.Transformers = []string{"drop_retries"}
+	cfg.RateLimit.RecordsPerSecond = 1
+	cfg.RateLimit.Burst = 2
+	sink := new(consumertest.LogsSink)
+	conn, _ := newTelemetryTestConnector(t, cfg, sink)
+	now := time.Now()
+	conn.converter.limiter.now = func() time.Time { return now }
+
+	// The dropped retry records do not use up the budget of the exceptions
+	if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "retry", "retry", "exception", "exception")); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	if got := sink.LogRecordCount(); got != 2 {
+		t.Errorf("produced %d records, want the 2 exceptions", got)
+	}
+}
+
+func TestConnector_RateLimitPerKey(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.RateLimit.RecordsPerSecond = 3
+	sink := new(consumertest.LogsSink)
+	conn, _ := newTelemetryTestConnector(t, cfg, sink)
+
+	if err := conn.ConsumeTraces(context.Background(), newRateLimitTestTraces(5, "checkout", "cart")); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	records, _ := recordsByService(sink)
+	if records["checkout"] != 3 || records["cart"] != 3 {
+		t.Errorf("produced %v, want 3 records per service", records)
+	}
+}
+
+func TestConnector_RateLimitBytes(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.RateLimit.BytesPerMinute = 1
+	sink := new(consumertest.LogsSink)
+	conn, tt := newTelemetryTestConnector(t, cfg, sink)
+
+	// The first record takes the key into debt, so the rest is limited
+	if err := conn.ConsumeTraces(context.Background(), newRateLimitTestTraces(3, "checkout")); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	if got := sink.LogRecordCount(); got != 1 {
+		t.Errorf("produced %d records, want 1", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_rate_limited", attribute.String("key", "checkout"), attribute.String("limit", "bytes")); got != 2 {
+		t.Errorf("rate limited = %d, want 2", got)
+	}
+}
+
+func TestRateLimiter_MaxKeys(t *testing.T) {
+	cfg := defaultRateLimitConfig()
+	cfg.RecordsPerSecond = 1
+	cfg.MaxKeys = 1
+	builder, err := metadata.NewTelemetryBuilder(componenttest.NewNopTelemetrySettings())
+	if err != nil {
+		t.Fatalf("NewTelemetryBuilder() error = %v", err)
+	}
+	l := newRateLimiter(cfg, newConnectorTelemetry(builder, TelemetryConfig{}))
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	checkout := newRateLimitTestTraces(1, "checkout").ResourceSpans().At(0).Resource()
+	cart := newRateLimitTestTraces(1, "cart").ResourceSpans().At(0).Resource()
+	checkoutEntry := l.entry(context.Background(), checkout)
+	if produce, _ := l.allow(checkoutEntry, 0); !produce {
+		t.Fatal("allow() = false for the first record of checkout")
+	}
+	l.charge(checkoutEntry, plog.NewLogRecord(), rateLimitNone)
+	// checkout is still limited, so cart shares the overflow key
+	if got := l.entry(context.Background(), cart).key; got != rateLimitOverflowKey {
+		t.Errorf("key = %q, want %q", got, rateLimitOverflowKey)
+	}
+	// Once checkout's bucket is full again it is evicted in favour of cart
+	now = now.Add(time.Second)
+	entry := l.entry(context.Background(), cart)
+	if entry.key != "cart" {
+		t.Errorf("key = %q, want cart", entry.key)
+	}
+	l.charge(entry, plog.NewLogRecord(), rateLimitNone)
+	if got := l.entry(context.Background(), checkout).key; got != rateLimitOverflowKey {
+		t.Errorf("key = %q, want %q", got, rateLimitOverflowKey)
+	}
+}
+
+func TestConfig_RateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *RateLimitConfig)
+	}{
+		{name: "negative records_per_second", modify: func(cfg *RateLimitConfig) { cfg.RecordsPerSecond = -1 }},
+		{name: "negative bytes_per_minute", modify: func(cfg *RateLimitConfig) { cfg.BytesPerMinute = -1 }},
+		{name: "no key_attribute", modify: func(cfg *RateLimitConfig) { cfg.KeyAttribute = "" }},
+		{name: "unknown overflow", modify: func(cfg *RateLimitConfig) { cfg.Overflow = "block" }},
+		{name: "zero sample_rate", modify: func(cfg *RateLimitConfig) { cfg.Overflow, cfg.SampleRate = RateLimitSample, 0 }},
+		{name: "zero max_keys", modify: func(cfg *RateLimitConfig) { cfg.MaxKeys = 0 }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.EventConditions = []string{`name == "exception"`}
+			cfg.RateLimit.RecordsPerSecond = 10
+			tc.modify(&cfg.RateLimit)
+			if err := cfg.Validate(); err == nil {
+				t.Error("Validate() expected error")
+			}
+		})
+	}
+}
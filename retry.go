@@ -0,0 +1,328 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+// LogsErrorPolicy determines what happens to log records the next consumer rejects
+type LogsErrorPolicy string
+
+const (
+	// LogsErrorPropagate returns the error to the traces pipeline, which may retry the whole batch
+	LogsErrorPropagate LogsErrorPolicy = "propagate"
+	// LogsErrorDrop drops the log records and counts them
+	LogsErrorDrop LogsErrorPolicy = "drop"
+	// LogsErrorRetry queues the log records and resends them with exponential backoff.
+	// Permanent errors are dropped and counted
+	LogsErrorRetry LogsErrorPolicy = "retry"
+)
+
+func (p LogsErrorPolicy) validate() error {
+	switch p {
+	case "", LogsErrorPropagate, LogsErrorDrop, LogsErrorRetry:
+		return nil
+	default:
+		return fmt.Errorf("invalid on_logs_error: %q, must be one of [%s %s %s]", p, LogsErrorPropagate, LogsErrorDrop, LogsErrorRetry)
+	}
+}
+
+// RetryQueueConfig configures the retry queue used by on_logs_error: retry
+type RetryQueueConfig struct {
+	// QueueSize is the maximum number of rejected log batches held for retry.
+	// Batches rejected while the queue is full are dropped
+	QueueSize int `mapstructure:"queue_size"`
+
+	// InitialInterval is the time to wait before the first retry of a batch
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+
+	// MaxInterval caps the interval, which doubles after every failed retry
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+
+	// MaxElapsedTime is how long a batch is retried before it is dropped. 0 retries until shutdown
+	MaxElapsedTime time.Duration `mapstructure:"max_elapsed_time"`
+}
+
+const (
+	defaultRetryQueueSize       = 100
+	defaultRetryInitialInterval = time.Second
+	defaultRetryMaxInterval     = 30 * time.Second
+	defaultRetryMaxElapsedTime  = 5 * time.Minute
+)
+
+func defaultRetryQueueConfig() RetryQueueConfig {
+	return RetryQueueConfig{
+		QueueSize:       defaultRetryQueueSize,
+		InitialInterval: defaultRetryInitialInterval,
+		MaxInterval:     defaultRetryMaxInterval,
+		MaxElapsedTime:  defaultRetryMaxElapsedTime,
+	}
+}
+
+func (cfg RetryQueueConfig) validate() error {
+	if cfg.QueueSize <= 0 {
+		return fmt.Errorf("invalid retry_queue.queue_size: %d, must be positive", cfg.QueueSize)
+	}
+	if cfg.InitialInterval <= 0 {
+		return fmt.Errorf("invalid retry_queue.initial_interval: %s, must be positive", cfg.InitialInterval)
+	}
+	if cfg.MaxInterval < cfg.InitialInterval {
+		return fmt.Errorf("invalid retry_queue.max_interval: %s, must not be less than initial_interval", cfg.MaxInterval)
+	}
+	if cfg.MaxElapsedTime < 0 {
+		return fmt.Errorf("invalid retry_queue.max_elapsed_time: %s, must not be negative", cfg.MaxElapsedTime)
+	}
+	return nil
+}
+
+// Reasons recorded on otelcol_connector_spaneventstolog_log_records_dropped
+const (
+	logsDropRejected         = "rejected"
+	logsDropPermanent        = "permanent"
+	logsDropQueueFull        = "queue_full"
+	logsDropRetriesExhausted = "retries_exhausted"
+	logsDropShutdown         = "shutdown"
+)
+
+// rejectedLogs returns the part of logs that err reports as not accepted. Consumers
+// that accept part of a batch return it in a consumererror.Logs
+func rejectedLogs(err error, logs plog.Logs) plog.Logs {
+	var logsErr consumererror.Logs
+	if errors.As(err, &logsErr) {
+		return logsErr.Data()
+	}
+	return logs
+}
+
+// retryItem is a rejected log batch waiting in the retry queue
+type retryItem struct {
+	// ctx keeps the values of the ConsumeTraces context, such as client metadata,
+	// without its cancellation
+	ctx         context.Context
+	next        consumer.Logs
+	logs        plog.Logs
+	records     int
+	firstFailed time.Time
+	lastFailed  time.Time
+}
+
+// retryQueue holds log batches the next consumer rejected with a retryable error and
+// resends them in order, one batch at a time, with exponential backoff
+type retryQueue struct {
+	cfg       RetryQueueConfig
+	logger    *zap.Logger
+	telemetry *connectorTelemetry
+
+	items chan retryItem
+	stop  chan struct{}
+	done  chan struct{}
+
+	// mu guards stopped, so no batch is queued after shutdown drained the queue
+	mu      sync.RWMutex
+	stopped bool
+	started bool
+	// abandoned is set when shutdown gave up waiting for run, which then drops the
+	// batch it was retrying instead of leaving it pending
+	abandoned bool
+	// pending is the batch that was being retried when the queue stopped
+	pending *retryItem
+}
+
+func newRetryQueue(cfg RetryQueueConfig, logger *zap.Logger, telemetry *connectorTelemetry) *retryQueue {
+	return &retryQueue{
+		cfg:       cfg,
+		logger:    logger,
+		telemetry: telemetry,
+		items:     make(chan retryItem, cfg.QueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (q *retryQueue) start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started || q.stopped {
+		return
+	}
+	q.started = true
+	go q.run()
+}
+
+// enqueue adds a batch rejected by next without blocking. Batches that do not fit are dropped
+func (q *retryQueue) enqueue(ctx context.Context, next consumer.Logs, logs plog.Logs, records int) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.stopped {
+		q.telemetry.recordLogsDropped(ctx, records, logsDropShutdown)
+		return
+	}
+	now := time.Now()
+	select {
+	case q.items <- retryItem{ctx: context.WithoutCancel(ctx), next: next, logs: logs, records: records, firstFailed: now, lastFailed: now}:
+		q.telemetry.builder.ConnectorSpaneventstologRetryQueueSize.Add(ctx, 1)
+	default:
+		q.logger.Warn("Retry queue is full, dropping log records", zap.Int("records", records))
+		q.telemetry.recordLogsDropped(ctx, records, logsDropQueueFull)
+	}
+}
+
+func (q *retryQueue) run() {
+	defer close(q.done)
+	for {
+		select {
+		case <-q.stop:
+			return
+		case item := <-q.items:
+			q.telemetry.builder.ConnectorSpaneventstologRetryQueueSize.Add(item.ctx, -1)
+			if !q.retry(&item) {
+				q.mu.Lock()
+				if q.abandoned {
+					q.telemetry.recordLogsDropped(item.ctx, item.records, logsDropShutdown)
+				} else {
+					q.pending = &item
+				}
+				q.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// retry resends item until it is accepted, fails permanently or runs out of time.
+// It reports false if the queue stopped first.
+func (q *retryQueue) retry(item *retryItem) bool {
+	interval := q.cfg.InitialInterval
+	for {
+		if q.cfg.MaxElapsedTime > 0 && time.Since(item.firstFailed)+interval > q.cfg.MaxElapsedTime {
+			q.logger.Warn("Giving up retrying log records", zap.Int("records", item.records), zap.Duration("elapsed", time.Since(item.firstFailed)))
+			q.telemetry.recordLogsDropped(item.ctx, item.records, logsDropRetriesExhausted)
+			return true
+		}
+
+		// Batches that waited behind others may already be due
+		if wait := interval - time.Since(item.lastFailed); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-q.stop:
+				timer.Stop()
+				return false
+			case <-timer.C:
+			}
+		}
+
+		err := item.next.ConsumeLogs(item.ctx, item.logs)
+		if err == nil {
+			return true
+		}
+		q.telemetry.recordConsumeFailure(item.ctx, item.records)
+		if consumererror.IsPermanent(err) {
+			q.logger.Warn("Dropping log records rejected with a permanent error", zap.Int("records", item.records), zap.Error(err))
+			q.telemetry.recordLogsDropped(item.ctx, item.records, logsDropPermanent)
+			return true
+		}
+		item.logs = rejectedLogs(err, item.logs)
+		item.records = item.logs.LogRecordCount()
+		item.lastFailed = time.Now()
+		interval = min(2*interval, q.cfg.MaxInterval)
+	}
+}
+
+// shutdown stops retrying and makes one last attempt for every batch still held.
+// Batches that fail again, or that remain when ctx is done, are dropped. If ctx is
+// done before the batch being retried returns, that batch is dropped when it does.
+func (q *retryQueue) shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		return nil
+	}
+	q.stopped = true
+	started := q.started
+	q.mu.Unlock()
+
+	close(q.stop)
+	if started {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.abandoned = true
+			q.mu.Unlock()
+			if dropped := q.drop(); dropped > 0 {
+				return fmt.Errorf("dropped %d log records queued for retry: %w", dropped, ctx.Err())
+			}
+			return ctx.Err()
+		}
+	}
+
+	var dropped int
+	var errs error
+	flush := func(item retryItem) {
+		if err := q.flush(ctx, item); err != nil {
+			dropped += item.records
+			errs = errors.Join(errs, err)
+		}
+	}
+	if q.pending != nil {
+		flush(*q.pending)
+		q.pending = nil
+	}
+	for {
+		select {
+		case item := <-q.items:
+			q.telemetry.builder.ConnectorSpaneventstologRetryQueueSize.Add(item.ctx, -1)
+			flush(item)
+		default:
+			if errs != nil {
+				return fmt.Errorf("dropped %d log records queued for retry: %w", dropped, errs)
+			}
+			return nil
+		}
+	}
+}
+
+// drop empties the queue without sending and returns the number of dropped records
+func (q *retryQueue) drop() int {
+	var dropped int
+	for {
+		select {
+		case item := <-q.items:
+			q.telemetry.builder.ConnectorSpaneventstologRetryQueueSize.Add(item.ctx, -1)
+			q.telemetry.recordLogsDropped(item.ctx, item.records, logsDropShutdown)
+			dropped += item.records
+		default:
+			return dropped
+		}
+	}
+}
+
+// flush sends item once within the shutdown deadline
+func (q *retryQueue) flush(ctx context.Context, item retryItem) error {
+	err := ctx.Err()
+	if err == nil {
+		sendCtx := item.ctx
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			sendCtx, cancel = context.WithDeadline(sendCtx, deadline)
+			defer cancel()
+		}
+		if err = item.next.ConsumeLogs(sendCtx, item.logs); err == nil {
+			return nil
+		}
+		q.telemetry.recordConsumeFailure(item.ctx, item.records)
+	}
+	q.telemetry.recordLogsDropped(item.ctx, item.records, logsDropShutdown)
+	return err
+}
@@ -0,0 +1,262 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// flakyLogsConsumer rejects logs with a retryable error for its first failures calls
+// and while rejecting is set
+type flakyLogsConsumer struct {
+	consumertest.LogsSink
+	mu        sync.Mutex
+	failures  int
+	rejecting bool
+}
+
+func (f *flakyLogsConsumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	f.mu.Lock()
+	reject := f.rejecting || f.failures > 0
+	if f.failures > 0 {
+		f.failures--
+	}
+	f.mu.Unlock()
+	if reject {
+		return errors.New("logs pipeline unavailable")
+	}
+	return f.LogsSink.ConsumeLogs(ctx, ld)
+}
+
+func (f *flakyLogsConsumer) setRejecting(rejecting bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rejecting = rejecting
+}
+
+func newRetryTestConfig() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.OnLogsError = LogsErrorRetry
+	cfg.RetryQueue.InitialInterval = time.Millisecond
+	cfg.RetryQueue.MaxInterval = 4 * time.Millisecond
+	return cfg
+}
+
+func TestConnector_OnLogsError(t *testing.T) {
+	tests := []struct {
+		policy     LogsErrorPolicy
+		err        error
+		wantErr    bool
+		wantReason string
+	}{
+		{policy: LogsErrorPropagate, err: errors.New("unavailable"), wantErr: true},
+		{policy: LogsErrorDrop, err: errors.New("unavailable"), wantReason: logsDropRejected},
+		{policy: LogsErrorDrop, err: consumererror.NewPermanent(errors.New("bad data")), wantReason: logsDropPermanent},
+		{policy: LogsErrorRetry, err: consumererror.NewPermanent(errors.New("bad data")), wantReason: logsDropPermanent},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.policy)+"/"+tc.err.Error(), func(t *testing.T) {
+			cfg := newRetryTestConfig()
+			cfg.OnLogsError = tc.policy
+			conn, tt := newTelemetryTestConnector(t, cfg, consumertest.NewErr(tc.err))
+			if err := conn.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = conn.Shutdown(context.Background()) }()
+
+			err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception"))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ConsumeTraces() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantReason != "" {
+				if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_dropped", attribute.String("reason", tc.wantReason)); got != 1 {
+					t.Errorf("dropped[%s] = %d, want 1", tc.wantReason, got)
+				}
+			}
+		})
+	}
+}
+
+func TestConnector_RetryQueue(t *testing.T) {
+	next := &flakyLogsConsumer{failures: 3}
+	conn, tt := newTelemetryTestConnector(t, newRetryTestConfig(), next)
+	if err := conn.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception")); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for next.LogRecordCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := conn.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if got := next.LogRecordCount(); got != 1 {
+		t.Errorf("delivered %d log records, want 1", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_failed"); got != 3 {
+		t.Errorf("failed = %d, want 3", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_retry_queue_size"); got != 0 {
+		t.Errorf("queue size = %d, want 0", got)
+	}
+}
+
+func TestConnector_RetryQueueShutdown(t *testing.T) {
+	cfg := newRetryTestConfig()
+	cfg.RetryQueue.QueueSize = 1
+	cfg.RetryQueue.InitialInterval = time.Hour
+	cfg.RetryQueue.MaxInterval = time.Hour
+	cfg.RetryQueue.MaxElapsedTime = 0
+
+	tests := []struct {
+		name       string
+		recovered  bool
+		wantErr    bool
+		wantLogs   int
+		wantReason string
+	}{
+		{name: "flushed", recovered: true, wantLogs: 2},
+		{name: "dropped", wantErr: true, wantReason: logsDropShutdown},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &flakyLogsConsumer{rejecting: true}
+			conn, tt := newTelemetryTestConnector(t, cfg, next)
+			if err := conn.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+				t.Fatal(err)
+			}
+
+			// The first batch is taken by the retry loop and the second fills the queue,
+			// so the third finds it full
+			for i := 0; i < 3; i++ {
+				if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception")); err != nil {
+					t.Fatalf("ConsumeTraces() error = %v", err)
+				}
+				if i == 0 {
+					deadline := time.Now().Add(5 * time.Second)
+					for len(conn.retry.items) > 0 && time.Now().Before(deadline) {
+						time.Sleep(time.Millisecond)
+					}
+				}
+			}
+			if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_dropped", attribute.String("reason", logsDropQueueFull)); got != 1 {
+				t.Errorf("dropped[queue_full] = %d, want 1", got)
+			}
+
+			next.setRejecting(!tc.recovered)
+			if err := conn.Shutdown(context.Background()); (err != nil) != tc.wantErr {
+				t.Fatalf("Shutdown() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got := next.LogRecordCount(); got != tc.wantLogs {
+				t.Errorf("delivered %d log records, want %d", got, tc.wantLogs)
+			}
+			if tc.wantReason != "" {
+				if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_dropped", attribute.String("reason", tc.wantReason)); got != 2 {
+					t.Errorf("dropped[%s] = %d, want 2", tc.wantReason, got)
+				}
+			}
+		})
+	}
+}
+
+// blockingLogsConsumer rejects every batch. The second call, which is the first retry,
+// blocks until release is closed
+type blockingLogsConsumer struct {
+	consumertest.LogsSink
+	mu       sync.Mutex
+	calls    int
+	blocking chan struct{}
+	release  chan struct{}
+}
+
+func (b *blockingLogsConsumer) ConsumeLogs(context.Context, plog.Logs) error {
+	b.mu.Lock()
+	b.calls++
+	calls := b.calls
+	b.mu.Unlock()
+	if calls == 2 {
+		close(b.blocking)
+		<-b.release
+	}
+	return errors.New("logs pipeline unavailable")
+}
+
+func TestConnector_RetryQueueShutdownCancelled(t *testing.T) {
+	cfg := newRetryTestConfig()
+	cfg.RetryQueue.QueueSize = 2
+	cfg.RetryQueue.MaxElapsedTime = 0
+	next := &blockingLogsConsumer{blocking: make(chan struct{}), release: make(chan struct{})}
+	conn, tt := newTelemetryTestConnector(t, cfg, next)
+	if err := conn.Start(context.Background(), componenttest.NewNopHost()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first batch is being retried when the other two are queued
+	if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception")); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+	select {
+	case <-next.blocking:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the retry loop did not resend the first batch")
+	}
+	for i := 0; i < 2; i++ {
+		if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception")); err != nil {
+			t.Fatalf("ConsumeTraces() error = %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := conn.Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Shutdown() error = %v, want context.Canceled", err)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_dropped", attribute.String("reason", logsDropShutdown)); got != 2 {
+		t.Errorf("dropped[shutdown] = %d, want the 2 queued records", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_retry_queue_size"); got != 0 {
+		t.Errorf("retry queue size = %d, want 0", got)
+	}
+
+	// The batch in flight is dropped once the consumer returns
+	close(next.release)
+	<-conn.retry.done
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_dropped", attribute.String("reason", logsDropShutdown)); got != 3 {
+		t.Errorf("dropped[shutdown] = %d, want 3", got)
+	}
+}
+
+func TestConfig_OnLogsError(t *testing.T) {
+	cfg := newRetryTestConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	cfg.OnLogsError = "buffer"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for unknown on_logs_error")
+	}
+	cfg.OnLogsError = LogsErrorRetry
+	cfg.RetryQueue.QueueSize = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for empty retry queue")
+	}
+	cfg.RetryQueue.QueueSize = 10
+	cfg.RetryQueue.MaxInterval = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for max_interval below initial_interval")
+	}
+}
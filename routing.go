@@ -0,0 +1,202 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlspanevent"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pipeline"
+)
+
+// RoutingConfig sends log records to different logs pipelines
+type RoutingConfig struct {
+	// Routes are evaluated in order; a record goes to the pipelines of the first route it matches
+	Routes []RouteConfig `mapstructure:"routes"`
+
+	// DefaultPipelines receive the records that match no route. Without default
+	// pipelines those records are not produced
+	DefaultPipelines []pipeline.ID `mapstructure:"default_pipelines"`
+}
+
+// RouteConfig selects log records by rule, severity and OTTL condition. Every criterion
+// that is set must match
+type RouteConfig struct {
+	// Rules are body_templates keys, or "default" for events without a specific template
+	Rules []string `mapstructure:"rules"`
+
+	// MinSeverity matches records whose severity is at least this level
+	MinSeverity string `mapstructure:"min_severity"`
+
+	// Condition is an OTTL condition evaluated in the span event context
+	Condition string `mapstructure:"condition"`
+
+	// Pipelines are the logs pipelines that receive the matching records
+	Pipelines []pipeline.ID `mapstructure:"pipelines"`
+}
+
+func (cfg *RoutingConfig) validate(bodyTemplates map[string]BodyTemplateConfig) error {
+	for i, route := range cfg.Routes {
+		if len(route.Pipelines) == 0 {
+			return fmt.Errorf("routing.routes[%d]: at least one pipeline must be specified", i)
+		}
+		if len(route.Rules) == 0 && route.MinSeverity == "" && route.Condition == "" {
+			return fmt.Errorf("routing.routes[%d]: at least one of rules, min_severity or condition must be specified", i)
+		}
+		for _, rule := range route.Rules {
+			if _, ok := bodyTemplates[rule]; !ok && rule != defaultBodyTemplateKey {
+				return fmt.Errorf("routing.routes[%d]: unknown rule %q, must be a body_templates key or %q", i, rule, defaultBodyTemplateKey)
+			}
+		}
+		if route.MinSeverity != "" && !isValidLogLevel(route.MinSeverity) {
+			return fmt.Errorf("routing.routes[%d]: invalid min_severity: %s, must be one of %v", i, route.MinSeverity, validLogLevels)
+		}
+	}
+	if len(cfg.Routes) == 0 && len(cfg.DefaultPipelines) > 0 {
+		return errors.New("routing.default_pipelines requires at least one route")
+	}
+	return nil
+}
+
+// logsRoute is a compiled RouteConfig
+type logsRoute struct {
+	// rules is nil when the route matches every rule
+	rules       map[string]struct{}
+	minSeverity plog.SeverityNumber
+	// condition is nil when the route has no condition
+	condition *conditionMatcher[ottlspanevent.TransformContext]
+}
+
+// logsRouter assigns each log record to one of the connector's outputs. Output i is the
+// consumer of route i; the last output receives the records that match no route.
+type logsRouter struct {
+	routes []logsRoute
+	// hasDefault is false when unmatched records are not produced
+	hasDefault bool
+	// severity is set when a route has a min_severity
+	severity bool
+}
+
+// newLogsRouter returns the router that assigns log records to the outputs of the
+// connector, or nil without routes
+func newLogsRouter(cfg *RoutingConfig, conditions []*conditionMatcher[ottlspanevent.TransformContext]) *logsRouter {
+	if len(cfg.Routes) == 0 {
+		return nil
+	}
+	router := &logsRouter{hasDefault: len(cfg.DefaultPipelines) > 0}
+	for i, route := range cfg.Routes {
+		compiled := logsRoute{condition: conditions[i]}
+		if len(route.Rules) > 0 {
+			compiled.rules = make(map[string]struct{}, len(route.Rules))
+			for _, rule := range route.Rules {
+				compiled.rules[rule] = struct{}{}
+			}
+		}
+		if route.MinSeverity != "" {
+			compiled.minSeverity = severityNumber(route.MinSeverity)
+			router.severity = true
+		}
+		router.routes = append(router.routes, compiled)
+	}
+	return router
+}
+
+// outputs returns the number of outputs the router picks from
+func (r *logsRouter) outputs() int {
+	if r == nil {
+		return 1
+	}
+	return len(r.routes) + 1
+}
+
+// newLogsOutputs returns the consumers the connector sends its logs to, in the order of
+// the router's outputs. Without routes next is the only output.
+func newLogsOutputs(cfg *RoutingConfig, next consumer.Logs) ([]consumer.Logs, error) {
+	if len(cfg.Routes) == 0 {
+		return []consumer.Logs{next}, nil
+	}
+	pipelines, ok := next.(connector.LogsRouterAndConsumer)
+	if !ok {
+		return nil, errors.New("routing requires the connector to be used as a logs pipeline receiver")
+	}
+
+	outputs := make([]consumer.Logs, 0, len(cfg.Routes)+1)
+	for i, route := range cfg.Routes {
+		out, err := pipelines.Consumer(route.Pipelines...)
+		if err != nil {
+			return nil, fmt.Errorf("routing.routes[%d]: %w", i, err)
+		}
+		outputs = append(outputs, out)
+	}
+
+	var defaultOutput consumer.Logs
+	if len(cfg.DefaultPipelines) > 0 {
+		var err error
+		if defaultOutput, err = pipelines.Consumer(cfg.DefaultPipelines...); err != nil {
+			return nil, fmt.Errorf("routing.default_pipelines: %w", err)
+		}
+	}
+	return append(outputs, defaultOutput), nil
+}
+
+// bySeverity reports whether a route selects records by severity, so records must be
+// built before they are routed
+func (r *logsRouter) bySeverity() bool {
+	return r != nil && r.severity
+}
+
+// route returns the output of a record, or -1 if the record should not be produced.
+// An error is only returned when error_mode is propagate.
+func (r *logsRouter) route(ctx context.Context, rule string, severity plog.SeverityNumber, target nativeTarget, newTCtx func() ottlspanevent.TransformContext) (int, error) {
+	if r == nil {
+		return 0, nil
+	}
+	for i, route := range r.routes {
+		if route.rules != nil {
+			if _, ok := route.rules[rule]; !ok {
+				continue
+			}
+		}
+		if severity < route.minSeverity {
+			continue
+		}
+		if route.condition != nil {
+			match, err := route.condition.eval(ctx, target, newTCtx)
+			if err != nil {
+				return 0, err
+			}
+			if !match {
+				continue
+			}
+		}
+		return i, nil
+	}
+	if !r.hasDefault {
+		return -1, nil
+	}
+	return len(r.routes), nil
+}
+
+// routedLogs holds the converted log records of one batch per output
+type routedLogs []plog.Logs
+
+func newRoutedLogs(outputs int) routedLogs {
+	out := make(routedLogs, outputs)
+	for i := range out {
+		out[i] = plog.NewLogs()
+	}
+	return out
+}
+
+// moveAndAppendTo moves the records of every output to the end of dest's
+func (out routedLogs) moveAndAppendTo(dest routedLogs) {
+	for i, logs := range out {
+		logs.ResourceLogs().MoveAndAppendTo(dest[i].ResourceLogs())
+	}
+}
@@ -0,0 +1,212 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/connector/connectortest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	auditPipeline   = pipeline.NewIDWithName(pipeline.SignalLogs, "audit")
+	obsPipeline     = pipeline.NewIDWithName(pipeline.SignalLogs, "observability")
+	defaultPipeline = pipeline.NewIDWithName(pipeline.SignalLogs, "default")
+)
+
+// newRoutingTestSinks returns a router over one sink per test pipeline
+func newRoutingTestSinks() (connector.LogsRouterAndConsumer, map[pipeline.ID]*consumertest.LogsSink) {
+	sinks := map[pipeline.ID]*consumertest.LogsSink{
+		auditPipeline:   new(consumertest.LogsSink),
+		obsPipeline:     new(consumertest.LogsSink),
+		defaultPipeline: new(consumertest.LogsSink),
+	}
+	consumers := make(map[pipeline.ID]consumer.Logs, len(sinks))
+	for id, sink := range sinks {
+		consumers[id] = sink
+	}
+	return connector.NewLogsRouter(consumers), sinks
+}
+
+func newRoutingTestConfig() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name != "health"`}
+	cfg.BodyTemplates = map[string]BodyTemplateConfig{"exception": {Template: "{{.EventName}}"}}
+	cfg.Routing = RoutingConfig{
+		Routes: []RouteConfig{
+			{Condition: `attributes["security"] == true`, Pipelines: []pipeline.ID{auditPipeline}},
+			{Rules: []string{"exception"}, Pipelines: []pipeline.ID{obsPipeline}},
+		},
+		DefaultPipelines: []pipeline.ID{defaultPipeline},
+	}
+	return cfg
+}
+
+func newRoutingTestTraces() ptrace.Traces {
+	td := newTestTraces("POST /login", "login", "exception", "checkout", "exception")
+	// The second exception is security related, so the first route wins over the rule
+	events := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Events()
+	events.At(0).Attributes().PutBool("security", true)
+	events.At(3).Attributes().PutBool("security", true)
+	return td
+}
+
+func TestConnector_Routing(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		want    map[pipeline.ID]int
+		dropped int64
+	}{
+		{
+			name: "first matching route",
+			want: map[pipeline.ID]int{auditPipeline: 2, obsPipeline: 1, defaultPipeline: 1},
+		},
+		{
+			name:    "no default pipelines",
+			modify:  func(cfg *Config) { cfg.Routing.DefaultPipelines = nil },
+			want:    map[pipeline.ID]int{auditPipeline: 2, obsPipeline: 1},
+			dropped: 1,
+		},
+		{
+			name: "min severity",
+			modify: func(cfg *Config) {
+				cfg.LogLevel = "Warn"
+				cfg.Routing.Routes[0] = RouteConfig{MinSeverity: "Error", Pipelines: []pipeline.ID{auditPipeline}}
+				cfg.Routing.Routes[1].MinSeverity = "Warn"
+			},
+			want: map[pipeline.ID]int{obsPipeline: 2, defaultPipeline: 2},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newRoutingTestConfig()
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			router, sinks := newRoutingTestSinks()
+			conn, tt := newTelemetryTestConnector(t, cfg, router)
+			if err := conn.ConsumeTraces(context.Background(), newRoutingTestTraces()); err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+			for id, sink := range sinks {
+				if got := sink.LogRecordCount(); got != tc.want[id] {
+					t.Errorf("%s received %d records, want %d", id, got, tc.want[id])
+				}
+			}
+			if tc.dropped > 0 {
+				if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_events_dropped", attribute.String("reason", "unrouted")); got != tc.dropped {
+					t.Errorf("unrouted = %d, want %d", got, tc.dropped)
+				}
+			}
+		})
+	}
+}
+
+func TestConnector_RoutingBySeverity(t *testing.T) {
+	// Exceptions are raised to Error by a transformer, other events stay at log_level
+	raise := funcTransformer{name: "raise_exceptions", fn: func(event EventContext, record plog.LogRecord) (bool, error) {
+		if event.Event.Name() == "exception" {
+			record.SetSeverityNumber(plog.SeverityNumberError)
+			record.SetSeverityText("Error")
+		}
+		return true, nil
+	}}
+	cfg := NewFactoryWithOptions(WithTransformers(raise)).CreateDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name != "health"`}
+	cfg.LogLevel = "Info"
+	cfg.Transformers = []string{"raise_exceptions"}
+	cfg.Routing = RoutingConfig{
+		Routes:           []RouteConfig{{MinSeverity: "Error", Pipelines: []pipeline.ID{auditPipeline}}},
+		DefaultPipelines: []pipeline.ID{defaultPipeline},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	router, sinks := newRoutingTestSinks()
+	conn, _ := newTelemetryTestConnector(t, cfg, router)
+	if err := conn.ConsumeTraces(context.Background(), newTestTraces("POST /login", "login", "exception", "checkout", "exception")); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+
+	want := map[pipeline.ID]int{auditPipeline: 2, defaultPipeline: 2}
+	for id, sink := range sinks {
+		if got := sink.LogRecordCount(); got != want[id] {
+			t.Errorf("%s received %d records, want %d", id, got, want[id])
+		}
+	}
+	for _, logs := range sinks[auditPipeline].AllLogs() {
+		for i := 0; i < logs.ResourceLogs().Len(); i++ {
+			lr := logs.ResourceLogs().At(i).ScopeLogs().At(0).LogRecords().At(0)
+			if lr.SeverityNumber() != plog.SeverityNumberError {
+				t.Errorf("audit received a record with severity %v", lr.SeverityNumber())
+			}
+		}
+	}
+}
+
+func TestConnector_RoutingPipelines(t *testing.T) {
+	cfg := newRoutingTestConfig()
+	cfg.Routing.Routes[1].Pipelines = []pipeline.ID{pipeline.NewIDWithName(pipeline.SignalLogs, "missing")}
+	router, _ := newRoutingTestSinks()
+	if _, err := NewSpanEventConnector(connectortest.NewNopSettings(metadata.Type), cfg, router); err == nil {
+		t.Error("NewSpanEventConnector() expected error for a pipeline the connector does not feed")
+	}
+
+	if _, err := NewSpanEventConnector(connectortest.NewNopSettings(metadata.Type), newRoutingTestConfig(), consumertest.NewNop()); err == nil {
+		t.Error("NewSpanEventConnector() expected error without a pipeline router")
+	}
+}
+
+func TestConfig_Routing(t *testing.T) {
+	conf := confmap.NewFromStringMap(map[string]any{
+		"event_conditions": []any{`name == "exception"`},
+		"routing": map[string]any{
+			"routes": []any{
+				map[string]any{"rules": []any{"default"}, "pipelines": []any{"logs/audit"}},
+			},
+			"default_pipelines": []any{"logs/default"},
+		},
+	})
+	cfg := createDefaultConfig().(*Config)
+	if err := cfg.Unmarshal(conf); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got := cfg.Routing.Routes[0].Pipelines[0]; got != auditPipeline {
+		t.Errorf("pipeline = %v, want %v", got, auditPipeline)
+	}
+
+	tests := []struct {
+		name  string
+		route RouteConfig
+	}{
+		{name: "no pipelines", route: RouteConfig{Rules: []string{"default"}}},
+		{name: "no criteria", route: RouteConfig{Pipelines: []pipeline.ID{auditPipeline}}},
+		{name: "unknown rule", route: RouteConfig{Rules: []string{"exceptions"}, Pipelines: []pipeline.ID{auditPipeline}}},
+		{name: "invalid severity", route: RouteConfig{MinSeverity: "Critical", Pipelines: []pipeline.ID{auditPipeline}}},
+		{name: "invalid condition", route: RouteConfig{Condition: `attributes["x"] ==`, Pipelines: []pipeline.ID{auditPipeline}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newRoutingTestConfig()
+			cfg.Routing.Routes = []RouteConfig{tc.route}
+			if err := cfg.Validate(); err == nil {
+				t.Error("Validate() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+)
+
+// UpdateEnv is the environment variable that, set to any value, makes AssertGolden write
+// the golden files instead of comparing them. Unlike the -spaneventstologtest.update flag it
+// also works with go test ./..., where packages that do not import this package would
+// reject the flag.
+const UpdateEnv = "SPANEVENTSTOLOG_UPDATE_GOLDEN"
+
+var update = flag.Bool("spaneventstologtest.update", false, "rewrite golden files with the produced logs instead of comparing them")
+
+// updating reports whether golden files are rewritten
+func updating() bool {
+	return *update || os.Getenv(UpdateEnv) != ""
+}
+
+// AssertGolden compares the log records of out with the golden file at path and reports a
+// line diff when they differ. The golden file holds the records as an indented JSON array,
+// in the flattened form of the spanevents2log ndjson output, so it reads well in code
+// review. Run the test with -spaneventstologtest.update, or with SPANEVENTSTOLOG_UPDATE_GOLDEN
+// set, to create or rewrite the file.
+func AssertGolden(t testing.TB, path string, out Output) {
+	t.Helper()
+	got, err := MarshalGolden(out)
+	if err != nil {
+		t.Fatalf("failed to marshal logs: %v", err)
+	}
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist, rerun with -spaneventstologtest.update or %s=1 to create it", path, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n")), got) {
+		t.Errorf("logs differ from golden file %s, rerun with -spaneventstologtest.update or %s=1 to accept them:\n%s",
+			path, UpdateEnv, diff(string(want), string(got)))
+	}
+}
+
+// MarshalGolden returns the golden file content for out
+func MarshalGolden(out Output) ([]byte, error) {
+	records := []harness.Record{}
+	for _, p := range out.Pipelines {
+		records = append(records, harness.Records(p.Logs, p.Pipeline)...)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// diffContext is the number of unchanged lines shown around every change
+const diffContext = 3
+
+// maxDiffCells caps the LCS table of diff, in cells. Files that differ in more lines only
+// show their first difference.
+const maxDiffCells = 1 << 20
+
+// diff returns a unified diff of the lines of want and got
+func diff(want, got string) string {
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	// The lines both ends share are matched without the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	m, n := len(a)-prefix-suffix, len(b)-prefix-suffix
+	if (m+1)*(n+1) > maxDiffCells {
+		return firstDifference(a, b, prefix)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of the changed lines
+	// a[prefix+i:] and b[prefix+j:]
+	lcs := make([][]int, m+1)
+	for i := range lcs {
+		lcs[i] = make([]int, n+1)
+	}
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if a[prefix+i] == b[prefix+j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+		// wantLine and gotLine are the 1-based line numbers the line is at or follows
+		wantLine, gotLine int
+	}
+	var lines []line
+	for k := 0; k < prefix; k++ {
+		lines = append(lines, line{' ', a[k], k + 1, k + 1})
+	}
+	i, j := 0, 0
+	for i < m || j < n {
+		switch {
+		case i < m && j < n && a[prefix+i] == b[prefix+j]:
+			lines = append(lines, line{' ', a[prefix+i], prefix + i + 1, prefix + j + 1})
+			i++
+			j++
+		case i < m && (j == n || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[prefix+i], prefix + i + 1, prefix + j})
+			i++
+		default:
+			lines = append(lines, line{'+', b[prefix+j], prefix + i, prefix + j + 1})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		lines = append(lines, line{' ', a[prefix+m+k], prefix + m + k + 1, prefix + n + k + 1})
+	}
+
+	var out strings.Builder
+	out.WriteString("--- golden\n+++ produced\n")
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// A hunk runs from the change to diffContext lines after the last change that is
+		// separated from the next one by at most 2*diffContext unchanged lines
+		from := max(0, start-diffContext)
+		end := start
+		for k := start; k < len(lines) && k <= end+2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		to := min(len(lines), end+diffContext+1)
+		fmt.Fprintf(&out, "@@ golden line %d, produced line %d @@\n", max(1, lines[from].wantLine), max(1, lines[from].gotLine))
+		for _, l := range lines[from:to] {
+			fmt.Fprintf(&out, "%c %s\n", l.op, l.text)
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// firstDifference shows the lines of want and got from line at, the first that differs, for
+// files that differ in too many lines to be diffed
+func firstDifference(a, b []string, at int) string {
+	var out strings.Builder
+	out.WriteString("--- golden\n+++ produced\n")
+	from := max(0, at-diffContext)
+	fmt.Fprintf(&out, "@@ golden line %d, produced line %d @@\n", from+1, from+1)
+	for _, l := range a[from:at] {
+		fmt.Fprintf(&out, "  %s\n", l)
+	}
+	for _, l := range a[at:min(len(a), at+diffContext+1)] {
+		fmt.Fprintf(&out, "- %s\n", l)
+	}
+	for _, l := range b[at:min(len(b), at+diffContext+1)] {
+		fmt.Fprintf(&out, "+ %s\n", l)
+	}
+	fmt.Fprintf(&out, "... %d golden and %d produced lines, too different to diff beyond the first difference\n", len(a), len(b))
+	return out.String()
+}
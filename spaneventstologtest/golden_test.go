@@ -0,0 +1,157 @@
+package spaneventstologtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAssertGolden(t *testing.T) {
+	h := NewFromFile(t, "testdata/exceptions.yaml")
+	AssertGolden(t, "testdata/realistic.golden.json", h.Run(LoadTraces(t, realisticTraces)))
+	AssertGolden(t, "testdata/checkout.golden.json", h.Run(newCheckoutTraces()))
+}
+
+// recordingTB records the failures of a test instead of reporting them
+type recordingTB struct {
+	testing.TB
+	mu       sync.Mutex
+	failures []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls fn with r in a goroutine, so Fatalf stops fn as it stops a test
+func (r *recordingTB) run(fn func(tb testing.TB)) []string {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(r)
+	}()
+	<-done
+	return r.failures
+}
+
+func TestAssertGolden_Failures(t *testing.T) {
+	out := NewFromFile(t, "testdata/exceptions.yaml").Run(newCheckoutTraces())
+	golden, err := MarshalGolden(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "stale.golden.json")
+	content := strings.Replace(string(golden), "payment timed out", "payment declined", 2)
+	if err := os.WriteFile(stale, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	failures := (&recordingTB{}).run(func(tb testing.TB) { AssertGolden(tb, stale, out) })
+	if len(failures) != 1 {
+		t.Fatalf("got failures %q, want one", failures)
+	}
+	for _, want := range []string{
+		"logs differ from golden file " + stale,
+		`-     "body": "POST /api/checkout: payment declined",`,
+		`+     "body": "POST /api/checkout: payment timed out",`,
+	} {
+		if !strings.Contains(failures[0], want) {
+			t.Errorf("failure does not contain %q:\n%s", want, failures[0])
+		}
+	}
+
+	missing := filepath.Join(dir, "missing", "new.golden.json")
+	failures = (&recordingTB{}).run(func(tb testing.TB) { AssertGolden(tb, missing, out) })
+	if len(failures) != 1 || !strings.Contains(failures[0], "does not exist, rerun with -spaneventstologtest.update") {
+		t.Errorf("got failures %q, want a missing golden file", failures)
+	}
+
+	t.Setenv(UpdateEnv, "1")
+	for _, path := range []string{stale, missing} {
+		if failures := (&recordingTB{}).run(func(tb testing.TB) { AssertGolden(tb, path, out) }); len(failures) != 0 {
+			t.Errorf("update of %s failed: %q", path, failures)
+		}
+		if written, err := os.ReadFile(path); err != nil || string(written) != string(golden) {
+			t.Errorf("update did not write %s: %v", path, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		got  string
+		diff string
+	}{
+		{
+			name: "changed line",
+			want: "a\nb\nc\n",
+			got:  "a\nx\nc\n",
+			diff: "--- golden\n+++ produced\n@@ golden line 1, produced line 1 @@\n  a\n- b\n+ x\n  c\n",
+		},
+		{
+			name: "separate hunks",
+			want: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			got:  "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			diff: "--- golden\n+++ produced\n@@ golden line 1, produced line 1 @@\n+ 0\n  1\n  2\n  3\n@@ golden line 9, produced line 10 @@\n  9\n  10\n  11\n- 12\n",
+		},
+		{
+			name: "appended lines",
+			want: "[]\n",
+			got:  "[\n  {}\n]\n",
+			diff: "--- golden\n+++ produced\n@@ golden line 1, produced line 1 @@\n- []\n+ [\n+   {}\n+ ]\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := diff(tc.want, tc.got); got != tc.diff {
+				t.Errorf("diff() =\n%s\nwant\n%s", got, tc.diff)
+			}
+		})
+	}
+}
+
+func TestDiff_LargeFiles(t *testing.T) {
+	lines := func(n int, format string) []string {
+		l := make([]string, n)
+		for i := range l {
+			l[i] = fmt.Sprintf(format, i)
+		}
+		return l
+	}
+
+	// One changed line in a large file is diffed without a table for the shared lines
+	want := lines(100000, "line %d")
+	got := slices.Clone(want)
+	got[50000] = "changed"
+	if d := diff(strings.Join(want, "\n"), strings.Join(got, "\n")); !strings.Contains(d, "@@ golden line 49998, produced line 49998 @@\n  line 49997\n  line 49998\n  line 49999\n- line 50000\n+ changed\n  line 50001\n") {
+		t.Errorf("diff() =\n%s", d)
+	}
+
+	// Files that differ everywhere only show the first difference
+	want = append([]string{"[", "{"}, lines(2000, "  golden %d")...)
+	got = append([]string{"[", "{"}, lines(2000, "  produced %d")...)
+	wantDiff := "--- golden\n+++ produced\n@@ golden line 1, produced line 1 @@\n  [\n  {\n" +
+		"-   golden 0\n-   golden 1\n-   golden 2\n-   golden 3\n" +
+		"+   produced 0\n+   produced 1\n+   produced 2\n+   produced 3\n" +
+		"... 2002 golden and 2002 produced lines, too different to diff beyond the first difference\n"
+	if d := diff(strings.Join(want, "\n"), strings.Join(got, "\n")); d != wantDiff {
+		t.Errorf("diff() =\n%s\nwant\n%s", d, wantDiff)
+	}
+}
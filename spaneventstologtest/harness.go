@@ -0,0 +1,190 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package spaneventstologtest tests spaneventstolog connector configurations. A Harness runs
+// traces, built with NewTraces or loaded from fixtures, through the real connector, and
+// AssertGolden compares the produced logs with a golden file:
+//
+//	func TestCheckoutRules(t *testing.T) {
+//		h := spaneventstologtest.NewFromFile(t, "testdata/collector.yaml")
+//		out := h.Run(spaneventstologtest.LoadTraces(t, "testdata/checkout.json"))
+//		spaneventstologtest.AssertGolden(t, "testdata/checkout.golden.json", out)
+//	}
+package spaneventstologtest
+
+import (
+	"context"
+	"testing"
+
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/connector"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap/zaptest"
+)
+
+// Option configures LoadConfig, New and NewFromFile
+type Option func(*options)
+
+type options struct {
+	factory       connector.Factory
+	connectorName string
+}
+
+// WithFactory uses factory instead of NewFactory, such as one created by
+// NewFactoryWithOptions with the custom functions and transformers of a custom build
+func WithFactory(factory connector.Factory) Option {
+	return func(o *options) {
+		o.factory = factory
+	}
+}
+
+// WithConnectorName selects the connector, such as spaneventstolog/errors, when a collector
+// configuration has several
+func WithConnectorName(name string) Option {
+	return func(o *options) {
+		o.connectorName = name
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{factory: spaneventstologconnector.NewFactory()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// LoadConfig reads and validates a connector configuration. The file holds either the
+// connector's own settings or a whole collector configuration, as the spanevents2log
+// command accepts them. It fails the test when the configuration is invalid.
+func LoadConfig(t testing.TB, path string, opts ...Option) *spaneventstologconnector.Config {
+	t.Helper()
+	o := newOptions(opts)
+	cfg, err := harness.LoadConfig(path, o.connectorName, o.factory)
+	if err != nil {
+		t.Fatalf("failed to load connector configuration: %v", err)
+	}
+	return cfg
+}
+
+// Harness runs traces through a started connector. The connector logs to the test log and
+// is shut down when the test ends. A Harness must not be used concurrently.
+type Harness struct {
+	t    testing.TB
+	conv *harness.Conversion
+}
+
+// New starts a connector with cfg. It fails the test when cfg is invalid.
+func New(t testing.TB, cfg *spaneventstologconnector.Config, opts ...Option) *Harness {
+	t.Helper()
+	o := newOptions(opts)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid connector configuration: %v", err)
+	}
+	set := componenttest.NewNopTelemetrySettings()
+	set.Logger = zaptest.NewLogger(t)
+	conv, err := harness.New(context.Background(), o.factory, cfg, set)
+	if err != nil {
+		t.Fatalf("failed to start the connector: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := conv.Shutdown(context.Background()); err != nil {
+			t.Errorf("failed to shut down the connector: %v", err)
+		}
+	})
+	return &Harness{t: t, conv: conv}
+}
+
+// NewFromFile starts a connector with the configuration LoadConfig reads from path
+func NewFromFile(t testing.TB, path string, opts ...Option) *Harness {
+	t.Helper()
+	return New(t, LoadConfig(t, path, opts...), opts...)
+}
+
+// Run passes td to the connector and returns the logs it produced. It fails the test when
+// the connector returns an error; use TryRun to check errors.
+func (h *Harness) Run(td ptrace.Traces) Output {
+	h.t.Helper()
+	out, err := h.TryRun(td)
+	if err != nil {
+		h.t.Fatalf("connector failed to consume traces: %v", err)
+	}
+	return out
+}
+
+// TryRun passes td to the connector and returns the logs it produced along with the error
+// it returned, as happens with error_mode propagate
+func (h *Harness) TryRun(td ptrace.Traces) (Output, error) {
+	err := h.conv.Convert(context.Background(), td)
+	var out Output
+	_ = h.conv.Drain(func(logs plog.Logs, pipeline string) error {
+		out.add(logs, pipeline)
+		return nil
+	})
+	return out, err
+}
+
+// Output is the logs the connector produced for one Run
+type Output struct {
+	// Pipelines holds the logs of every logs pipeline that received records, in the order
+	// the routes reference the pipelines. Without routes there is one entry, with an
+	// empty name.
+	Pipelines []PipelineLogs
+}
+
+// PipelineLogs is the logs routed to one logs pipeline
+type PipelineLogs struct {
+	Pipeline string
+	Logs     plog.Logs
+}
+
+func (o *Output) add(logs plog.Logs, pipeline string) {
+	for i := range o.Pipelines {
+		if o.Pipelines[i].Pipeline == pipeline {
+			logs.ResourceLogs().MoveAndAppendTo(o.Pipelines[i].Logs.ResourceLogs())
+			return
+		}
+	}
+	o.Pipelines = append(o.Pipelines, PipelineLogs{Pipeline: pipeline, Logs: logs})
+}
+
+// Logs returns the logs of all pipelines together
+func (o Output) Logs() plog.Logs {
+	all := plog.NewLogs()
+	for _, p := range o.Pipelines {
+		for i := 0; i < p.Logs.ResourceLogs().Len(); i++ {
+			p.Logs.ResourceLogs().At(i).CopyTo(all.ResourceLogs().AppendEmpty())
+		}
+	}
+	return all
+}
+
+// LogRecordCount returns the number of log records in all pipelines
+func (o Output) LogRecordCount() int {
+	n := 0
+	for _, p := range o.Pipelines {
+		n += p.Logs.LogRecordCount()
+	}
+	return n
+}
+
+// Bodies returns the bodies of all log records as strings, in output order
+func (o Output) Bodies() []string {
+	var bodies []string
+	for _, p := range o.Pipelines {
+		rls := p.Logs.ResourceLogs()
+		for i := 0; i < rls.Len(); i++ {
+			sls := rls.At(i).ScopeLogs()
+			for j := 0; j < sls.Len(); j++ {
+				lrs := sls.At(j).LogRecords()
+				for k := 0; k < lrs.Len(); k++ {
+					bodies = append(bodies, lrs.At(k).Body().AsString())
+				}
+			}
+		}
+	}
+	return bodies
+}
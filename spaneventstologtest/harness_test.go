@@ -0,0 +1,143 @@
+package spaneventstologtest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	spaneventstologconnector "github.com/henrikrexed/spanEventstoLog"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const realisticTraces = "../src/realistic_traces/month=05/day=23/hour=13/minute=06/traces_168499423.json"
+
+// newCheckoutTraces returns two services, one with an exception and an auth event and one
+// with an exception on a slow span
+func newCheckoutTraces() ptrace.Traces {
+	return NewTraces().
+		Resource(map[string]any{"service.name": "cart"}).
+		Span("GET /api/cart", map[string]any{"http.status_code": 503}).
+		Exception("requests.exceptions.ConnectionError", "Connection refused").
+		Event("auth.failed", map[string]any{"user": "alice"}).
+		Resource(map[string]any{"service.name": "checkout"}).
+		Scope("checkout-instrumentation").
+		Span("POST /api/checkout", nil).
+		Duration(2500*time.Millisecond).
+		Exception("TimeoutError", "payment timed out").
+		Traces()
+}
+
+func TestHarness_Builder(t *testing.T) {
+	cfg := spaneventstologconnector.NewFactory().CreateDefaultConfig().(*spaneventstologconnector.Config)
+	cfg.SpanConditions = []string{`SpanDuration() > Duration("1s")`}
+	cfg.LogBodyTemplate = "{{.SpanName}}: {{.EventName}}"
+
+	out := New(t, cfg).Run(newCheckoutTraces())
+	if want := []string{"POST /api/checkout: exception"}; !slices.Equal(out.Bodies(), want) {
+		t.Errorf("Bodies() = %q, want %q", out.Bodies(), want)
+	}
+	if len(out.Pipelines) != 1 || out.Pipelines[0].Pipeline != "" {
+		t.Fatalf("got pipelines %+v, want one unnamed pipeline", out.Pipelines)
+	}
+	rl := out.Logs().ResourceLogs().At(0)
+	if service, _ := rl.Resource().Attributes().Get("service.name"); service.Str() != "checkout" {
+		t.Errorf("service.name = %q, want checkout", service.Str())
+	}
+	lr := rl.ScopeLogs().At(0).LogRecords().At(0)
+	if got, want := lr.Timestamp().AsTime(), StartTime.Add(time.Second+time.Millisecond); !got.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", got, want)
+	}
+	if got := lr.SpanID().String(); got != "0000000000000002" {
+		t.Errorf("span ID = %s, want 0000000000000002", got)
+	}
+}
+
+func TestHarness_CollectorConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		connectorName string
+		want          map[string][]string
+	}{
+		{
+			name:          "exceptions",
+			connectorName: "spaneventstolog/exceptions",
+			want: map[string][]string{"": {
+				"GET /api/cart: requests.exceptions.ConnectionError",
+				"POST /api/checkout: TimeoutError",
+			}},
+		},
+		{
+			name:          "routed",
+			connectorName: "spaneventstolog/audit",
+			want: map[string][]string{
+				"logs/audit":   {"Span Event: auth.failed"},
+				"logs/default": {"Connection refused", "payment timed out"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := NewFromFile(t, "testdata/collector.yaml", WithConnectorName(tc.connectorName)).Run(newCheckoutTraces())
+			got := make(map[string][]string)
+			for _, p := range out.Pipelines {
+				got[p.Pipeline] = Output{Pipelines: []PipelineLogs{p}}.Bodies()
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got pipelines %v, want %v", got, tc.want)
+			}
+			for pipeline, want := range tc.want {
+				if !slices.Equal(got[pipeline], want) {
+					t.Errorf("pipeline %q got %q, want %q", pipeline, got[pipeline], want)
+				}
+			}
+		})
+	}
+}
+
+func TestHarness_TryRun(t *testing.T) {
+	cfg := spaneventstologconnector.NewFactory().CreateDefaultConfig().(*spaneventstologconnector.Config)
+	cfg.EventConditions = []string{`Substring(name, 0, 20) == "x"`}
+	cfg.ErrorMode = "propagate"
+
+	out, err := New(t, cfg).TryRun(newCheckoutTraces())
+	if err == nil {
+		t.Error("TryRun() expected an error for a failing condition")
+	}
+	if out.LogRecordCount() != 0 {
+		t.Errorf("LogRecordCount() = %d, want 0", out.LogRecordCount())
+	}
+}
+
+// upperBody is a transformer that replaces the body with EXCEPTION
+type upperBody struct{}
+
+func (upperBody) Name() string {
+	return "upper"
+}
+
+func (upperBody) Transform(_ context.Context, _ spaneventstologconnector.EventContext, record plog.LogRecord) (bool, error) {
+	record.Body().SetStr("EXCEPTION")
+	return true, nil
+}
+
+func TestHarness_WithFactory(t *testing.T) {
+	factory := spaneventstologconnector.NewFactoryWithOptions(spaneventstologconnector.WithTransformers(upperBody{}))
+	cfg := LoadConfig(t, "testdata/exceptions.yaml", WithFactory(factory))
+	cfg.Transformers = []string{"upper"}
+
+	out := New(t, cfg, WithFactory(factory)).Run(newCheckoutTraces())
+	if want := []string{"EXCEPTION", "EXCEPTION"}; !slices.Equal(out.Bodies(), want) {
+		t.Errorf("Bodies() = %q, want %q", out.Bodies(), want)
+	}
+}
+
+func TestHarness_RunsAreSeparate(t *testing.T) {
+	h := NewFromFile(t, "testdata/exceptions.yaml")
+	first := h.Run(LoadTraces(t, realisticTraces))
+	second := h.Run(NewTraces().Span("GET /", nil).Event("log", nil).Traces())
+	if first.LogRecordCount() != 3 || second.LogRecordCount() != 0 {
+		t.Errorf("got %d and %d records, want 3 and 0", first.LogRecordCount(), second.LogRecordCount())
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologtest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/harness"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// LoadTraces reads a trace fixture: OTLP/JSON, Jaeger JSON or Zipkin v2 JSON, possibly
+// several documents one after the other as the file exporter writes them, or OTLP/protobuf.
+// All documents are returned as one ptrace.Traces.
+func LoadTraces(t testing.TB, path string) ptrace.Traces {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read traces: %v", err)
+	}
+	batches, err := harness.DecodeTraces(content)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	td := ptrace.NewTraces()
+	for _, batch := range batches {
+		batch.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	}
+	return td
+}
+
+// StartTime is the start time of the first span NewTraces builds
+var StartTime = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// TracesBuilder builds traces for tests. Every span gets its own trace ID and span ID and
+// starts one second after the previous one; events are one millisecond apart. IDs and
+// timestamps depend only on the order of the calls, so the produced logs are the same on
+// every run.
+//
+// Attribute values are those pcommon.Map.FromRaw accepts; the builder panics on others.
+type TracesBuilder struct {
+	traces   ptrace.Traces
+	resource ptrace.ResourceSpans
+	scope    ptrace.ScopeSpans
+	span     ptrace.Span
+	spans    uint64
+	// hasResource and hasSpan report whether resource and span are set
+	hasResource bool
+	hasSpan     bool
+}
+
+// NewTraces returns an empty builder
+func NewTraces() *TracesBuilder {
+	return &TracesBuilder{traces: ptrace.NewTraces()}
+}
+
+// Resource starts a new resource. Spans added before the first Resource belong to a
+// resource with service.name test-service.
+func (b *TracesBuilder) Resource(attributes map[string]any) *TracesBuilder {
+	b.resource = b.traces.ResourceSpans().AppendEmpty()
+	putAttributes(b.resource.Resource().Attributes(), attributes)
+	b.scope = b.resource.ScopeSpans().AppendEmpty()
+	b.hasResource = true
+	b.hasSpan = false
+	return b
+}
+
+// Scope starts a new instrumentation scope in the current resource
+func (b *TracesBuilder) Scope(name string) *TracesBuilder {
+	if !b.hasResource {
+		b.Resource(map[string]any{"service.name": "test-service"})
+	} else {
+		b.scope = b.resource.ScopeSpans().AppendEmpty()
+	}
+	b.scope.Scope().SetName(name)
+	b.hasSpan = false
+	return b
+}
+
+// Span adds a span with a duration of 100ms to the current scope
+func (b *TracesBuilder) Span(name string, attributes map[string]any) *TracesBuilder {
+	if !b.hasResource {
+		b.Resource(map[string]any{"service.name": "test-service"})
+	}
+	b.spans++
+	b.span = b.scope.Spans().AppendEmpty()
+	b.span.SetName(name)
+	b.span.SetKind(ptrace.SpanKindServer)
+	var traceID [16]byte
+	binary.BigEndian.PutUint64(traceID[8:], b.spans)
+	b.span.SetTraceID(traceID)
+	var spanID [8]byte
+	binary.BigEndian.PutUint64(spanID[:], b.spans)
+	b.span.SetSpanID(spanID)
+	start := StartTime.Add(time.Duration(b.spans-1) * time.Second)
+	b.span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	b.span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(100 * time.Millisecond)))
+	putAttributes(b.span.Attributes(), attributes)
+	b.hasSpan = true
+	return b
+}
+
+// Duration sets the duration of the current span
+func (b *TracesBuilder) Duration(d time.Duration) *TracesBuilder {
+	b.mustHaveSpan("Duration")
+	b.span.SetEndTimestamp(pcommon.NewTimestampFromTime(b.span.StartTimestamp().AsTime().Add(d)))
+	return b
+}
+
+// Status sets the status of the current span
+func (b *TracesBuilder) Status(code ptrace.StatusCode, message string) *TracesBuilder {
+	b.mustHaveSpan("Status")
+	b.span.Status().SetCode(code)
+	b.span.Status().SetMessage(message)
+	return b
+}
+
+// Event adds a span event to the current span
+func (b *TracesBuilder) Event(name string, attributes map[string]any) *TracesBuilder {
+	b.mustHaveSpan("Event")
+	event := b.span.Events().AppendEmpty()
+	event.SetName(name)
+	offset := time.Duration(b.span.Events().Len()) * time.Millisecond
+	event.SetTimestamp(pcommon.NewTimestampFromTime(b.span.StartTimestamp().AsTime().Add(offset)))
+	putAttributes(event.Attributes(), attributes)
+	return b
+}
+
+// Exception adds an exception event with exception.type and exception.message to the
+// current span, and sets its status to error
+func (b *TracesBuilder) Exception(exceptionType, message string) *TracesBuilder {
+	b.Event("exception", map[string]any{"exception.type": exceptionType, "exception.message": message})
+	return b.Status(ptrace.StatusCodeError, message)
+}
+
+// Traces returns the built traces. The builder must not be used afterwards.
+func (b *TracesBuilder) Traces() ptrace.Traces {
+	return b.traces
+}
+
+func (b *TracesBuilder) mustHaveSpan(method string) {
+	if !b.hasSpan {
+		panic(fmt.Sprintf("spaneventstologtest: %s called before Span", method))
+	}
+}
+
+func putAttributes(dest pcommon.Map, attributes map[string]any) {
+	if err := dest.FromRaw(attributes); err != nil {
+		panic(fmt.Sprintf("spaneventstologtest: invalid attributes %v: %v", attributes, err))
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"time"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// TelemetryConfig controls optional attributes on the connector's internal metrics.
+// Both add cardinality, so they are disabled by default.
+type TelemetryConfig struct {
+	// RuleAttribute adds the rule (the body_templates key that selected the template)
+	// to otelcol_connector_spaneventstolog_events_matched
+	RuleAttribute bool `mapstructure:"rule_attribute"`
+
+	// ServiceAttribute adds the service.name resource attribute to the span, event
+	// and template error metrics
+	ServiceAttribute bool `mapstructure:"service_attribute"`
+}
+
+// dropReason is why span events were not converted to log records
+type dropReason int
+
+const (
+	// dropConditions means the context-inferred conditions rejected the event
+	dropConditions dropReason = iota
+	// dropSpanConditions means the span_conditions rejected the event's span
+	dropSpanConditions
+	// dropEventConditions means the event_conditions rejected the event
+	dropEventConditions
+	// dropUnrouted means the event's record matched no route and there are no default pipelines
+	dropUnrouted
+	// dropTransformer means an event transformer dropped the event's record
+	dropTransformer
+
+	numDropReasons
+)
+
+var dropReasonNames = [numDropReasons]string{"conditions", "span_conditions", "event_conditions", "unrouted", "transformer"}
+
+var (
+	conditionErrorAttributes  = metric.WithAttributeSet(attribute.NewSet(attribute.String("stage", "condition")))
+	expressionErrorAttributes = metric.WithAttributeSet(attribute.NewSet(attribute.String("stage", "expression")))
+)
+
+// conversionStats counts what happened while converting one ResourceSpans
+type conversionStats struct {
+	spansEvaluated    int64
+	spansMatched      int64
+	eventsEvaluated   int64
+	eventsMatched     int64
+	eventsDropped     [numDropReasons]int64
+	templateErrors    int64
+	expressionErrors  int64
+	transformerErrors int64
+	// duplicatesSuppressed counts matched events whose log.record.uid was already emitted
+	duplicatesSuppressed int64
+	// rateLimited counts the records suppressed by the rate limit of rateLimitKey
+	rateLimited  [numRateLimitKinds]int64
+	rateLimitKey string
+	// matchedByRule is only set when telemetry.rule_attribute is enabled
+	matchedByRule map[string]int64
+}
+
+// dropSpan counts the events of a span that is not converted
+func (s *conversionStats) dropSpan(events int, reason dropReason) {
+	s.eventsDropped[reason] += int64(events)
+}
+
+// connectorTelemetry records conversionStats on the instruments generated from metadata.yaml
+type connectorTelemetry struct {
+	builder          *metadata.TelemetryBuilder
+	ruleAttribute    bool
+	serviceAttribute bool
+}
+
+func newConnectorTelemetry(builder *metadata.TelemetryBuilder, cfg TelemetryConfig) *connectorTelemetry {
+	return &connectorTelemetry{
+		builder:          builder,
+		ruleAttribute:    cfg.RuleAttribute,
+		serviceAttribute: cfg.ServiceAttribute,
+	}
+}
+
+// newStats returns the stats for converting one ResourceSpans
+func (t *connectorTelemetry) newStats() conversionStats {
+	var stats conversionStats
+	if t.ruleAttribute {
+		stats.matchedByRule = make(map[string]int64)
+	}
+	return stats
+}
+
+// record adds the stats of one ResourceSpans to the metrics
+func (t *connectorTelemetry) record(ctx context.Context, resource pcommon.Resource, s *conversionStats) {
+	var service []attribute.KeyValue
+	if t.serviceAttribute {
+		name := ""
+		if v, ok := resource.Attributes().Get("service.name"); ok {
+			name = v.AsString()
+		}
+		service = []attribute.KeyValue{attribute.String("service.name", name)}
+	}
+	serviceOpt := metric.WithAttributes(service...)
+
+	b := t.builder
+	addIfPositive(ctx, b.ConnectorSpaneventstologSpansEvaluated, s.spansEvaluated, serviceOpt)
+	addIfPositive(ctx, b.ConnectorSpaneventstologSpansMatched, s.spansMatched, serviceOpt)
+	addIfPositive(ctx, b.ConnectorSpaneventstologEventsEvaluated, s.eventsEvaluated, serviceOpt)
+	addIfPositive(ctx, b.ConnectorSpaneventstologTemplateErrors, s.templateErrors, serviceOpt)
+	addIfPositive(ctx, b.ConnectorSpaneventstologDuplicatesSuppressed, s.duplicatesSuppressed, serviceOpt)
+	addIfPositive(ctx, b.ConnectorSpaneventstologOttlErrors, s.expressionErrors, expressionErrorAttributes)
+
+	if s.matchedByRule != nil {
+		for rule, n := range s.matchedByRule {
+			addIfPositive(ctx, b.ConnectorSpaneventstologEventsMatched, n, metric.WithAttributes(append(service, attribute.String("rule", rule))...))
+		}
+	} else {
+		addIfPositive(ctx, b.ConnectorSpaneventstologEventsMatched, s.eventsMatched, serviceOpt)
+	}
+
+	for reason, n := range s.eventsDropped {
+		addIfPositive(ctx, b.ConnectorSpaneventstologEventsDropped, n, metric.WithAttributes(append(service, attribute.String("reason", dropReasonNames[reason]))...))
+	}
+	for limit, n := range s.rateLimited {
+		addIfPositive(ctx, b.ConnectorSpaneventstologLogRecordsRateLimited, n, metric.WithAttributes(attribute.String("key", s.rateLimitKey), attribute.String("limit", rateLimitKindNames[limit])))
+	}
+}
+
+// recordDuration records the time spent converting one batch
+func (t *connectorTelemetry) recordDuration(ctx context.Context, d time.Duration) {
+	t.builder.ConnectorSpaneventstologProcessingDuration.Record(ctx, d.Seconds())
+}
+
+// recordConsumeFailure counts the log records the next consumer rejected
+func (t *connectorTelemetry) recordConsumeFailure(ctx context.Context, records int) {
+	t.builder.ConnectorSpaneventstologLogRecordsFailed.Add(ctx, int64(records))
+}
+
+// recordLogsDropped counts log records that were given up after the next consumer rejected them
+func (t *connectorTelemetry) recordLogsDropped(ctx context.Context, records int, reason string) {
+	t.builder.ConnectorSpaneventstologLogRecordsDropped.Add(ctx, int64(records), metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+func addIfPositive(ctx context.Context, counter metric.Int64Counter, n int64, opts ...metric.AddOption) {
+	if n > 0 {
+		counter.Add(ctx, n, opts...)
+	}
+}
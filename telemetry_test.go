@@ -0,0 +1,166 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/henrikrexed/spanEventstoLog/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/connector/connectortest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTelemetryTestConnector creates a connector for cfg whose metrics are collected by tt
+func newTelemetryTestConnector(t *testing.T, cfg *Config, next consumer.Logs) (*SpanEventConnector, *componenttest.Telemetry) {
+	t.Helper()
+	tt := componenttest.NewTelemetry()
+	t.Cleanup(func() { _ = tt.Shutdown(context.Background()) })
+	set := connectortest.NewNopSettings(metadata.Type)
+	set.TelemetrySettings = tt.NewTelemetrySettings()
+	conn, err := NewSpanEventConnector(set, cfg, next)
+	if err != nil {
+		t.Fatalf("NewSpanEventConnector() error = %v", err)
+	}
+	return conn.(*SpanEventConnector), tt
+}
+
+// sumValue returns the value of the data point of metric name with exactly the given attributes
+func sumValue(t *testing.T, tt *componenttest.Telemetry, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	m, err := tt.GetMetric(name)
+	if err != nil {
+		t.Fatalf("GetMetric(%q) error = %v", name, err)
+	}
+	want := attribute.NewSet(attrs...)
+	for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		if dp.Attributes.Equals(&want) {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+func TestTelemetry_ConversionMetrics(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Conditions = []string{`resource.attributes["service.name"] != "frontend"`}
+	cfg.SpanConditions = []string{`name != "health"`}
+	cfg.EventConditions = []string{`name != "retry"`}
+	cfg.BodyTemplates = map[string]BodyTemplateConfig{"exception": {Template: "{{.EventName}}"}}
+	cfg.Telemetry = TelemetryConfig{RuleAttribute: true, ServiceAttribute: true}
+	conn, tt := newTelemetryTestConnector(t, cfg, new(consumertest.LogsSink))
+
+	td := newTestTraces("GET /api/cart", "exception", "checkout", "retry")
+	newTestTraces("health", "exception").ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	frontend := newTestTraces("GET /", "exception", "exception")
+	frontend.ResourceSpans().At(0).Resource().Attributes().PutStr("service.name", "frontend")
+	frontend.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+
+	loadgen := attribute.String("service.name", "loadgenerator")
+	frontendAttr := attribute.String("service.name", "frontend")
+	tests := []struct {
+		name  string
+		attrs []attribute.KeyValue
+		want  int64
+	}{
+		{name: "otelcol_connector_spaneventstolog_spans_evaluated", attrs: []attribute.KeyValue{loadgen}, want: 2},
+		{name: "otelcol_connector_spaneventstolog_spans_evaluated", attrs: []attribute.KeyValue{frontendAttr}, want: 1},
+		{name: "otelcol_connector_spaneventstolog_spans_matched", attrs: []attribute.KeyValue{loadgen}, want: 1},
+		{name: "otelcol_connector_spaneventstolog_events_evaluated", attrs: []attribute.KeyValue{loadgen}, want: 4},
+		{name: "otelcol_connector_spaneventstolog_events_matched", attrs: []attribute.KeyValue{loadgen, attribute.String("rule", "exception")}, want: 1},
+		{name: "otelcol_connector_spaneventstolog_events_matched", attrs: []attribute.KeyValue{loadgen, attribute.String("rule", "default")}, want: 1},
+		{name: "otelcol_connector_spaneventstolog_events_dropped", attrs: []attribute.KeyValue{loadgen, attribute.String("reason", "event_conditions")}, want: 1},
+		{name: "otelcol_connector_spaneventstolog_events_dropped", attrs: []attribute.KeyValue{loadgen, attribute.String("reason", "span_conditions")}, want: 1},
+		{name: "otelcol_connector_spaneventstolog_events_dropped", attrs: []attribute.KeyValue{frontendAttr, attribute.String("reason", "conditions")}, want: 2},
+	}
+	for _, tc := range tests {
+		if got := sumValue(t, tt, tc.name, tc.attrs...); got != tc.want {
+			t.Errorf("%s%v = %d, want %d", tc.name, tc.attrs, got, tc.want)
+		}
+	}
+
+	m, err := tt.GetMetric("otelcol_connector_spaneventstolog_processing_duration")
+	if err != nil {
+		t.Fatalf("GetMetric() error = %v", err)
+	}
+	if dps := m.Data.(metricdata.Histogram[float64]).DataPoints; len(dps) != 1 || dps[0].Count != 1 {
+		t.Errorf("processing_duration data points = %+v, want one batch", dps)
+	}
+}
+
+func TestTelemetry_ErrorMetrics(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	// Substring fails at runtime because the range exceeds the event name
+	cfg.EventConditions = []string{`Substring(name, 100, 5) == "x"`, `name == "exception"`}
+	cfg.BodyTemplates = map[string]BodyTemplateConfig{"exception": {Template: `{{if eq .EventName "exception"}}{{index .SpanName 100}}{{end}}`}}
+	cfg.Attributes = map[string]string{"bad": `Substring(name, 100, 5)`}
+	conn, tt := newTelemetryTestConnector(t, cfg, consumertest.NewErr(errors.New("logs pipeline unavailable")))
+
+	if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception", "other")); err == nil {
+		t.Fatal("ConsumeTraces() expected the consumer error")
+	}
+
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_ottl_errors", attribute.String("stage", "condition")); got != 2 {
+		t.Errorf("condition errors = %d, want 2", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_ottl_errors", attribute.String("stage", "expression")); got != 1 {
+		t.Errorf("expression errors = %d, want 1", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_template_errors"); got != 1 {
+		t.Errorf("template errors = %d, want 1", got)
+	}
+	// Metrics are recorded even though the logs were handed to the next consumer
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_events_matched"); got != 1 {
+		t.Errorf("events matched = %d, want 1", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_log_records_failed"); got != 1 {
+		t.Errorf("log records failed = %d, want 1", got)
+	}
+}
+
+func TestTelemetry_ConditionErrorModes(t *testing.T) {
+	tests := []struct {
+		errorMode ottl.ErrorMode
+		logged    int
+	}{
+		{errorMode: ottl.IgnoreError, logged: 2},
+		{errorMode: ottl.SilentError, logged: 0},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.errorMode), func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.EventConditions = []string{`Substring(name, 100, 5) == "x"`, `name == "exception"`}
+			cfg.ErrorMode = tc.errorMode
+			tt := componenttest.NewTelemetry()
+			t.Cleanup(func() { _ = tt.Shutdown(context.Background()) })
+			core, logged := observer.New(zap.WarnLevel)
+			set := connectortest.NewNopSettings(metadata.Type)
+			set.TelemetrySettings = tt.NewTelemetrySettings()
+			set.Logger = zap.New(core)
+			conn, err := NewSpanEventConnector(set, cfg, new(consumertest.LogsSink))
+			if err != nil {
+				t.Fatalf("NewSpanEventConnector() error = %v", err)
+			}
+			if err := conn.ConsumeTraces(context.Background(), newTestTraces("GET /api/cart", "exception", "other")); err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+
+			// Both modes count the failures, only ignore logs them
+			if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_ottl_errors", attribute.String("stage", "condition")); got != 2 {
+				t.Errorf("condition errors = %d, want 2", got)
+			}
+			if n := logged.FilterMessage(conditionErrorMessage).Len(); n != tc.logged {
+				t.Errorf("logged %d condition errors, want %d", n, tc.logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// defaultBodyTemplateKey is the body_templates key used for events that match no other key
+const defaultBodyTemplateKey = "default"
+
+// BodyTemplateConfig defines the log body template for one body_templates entry.
+// Exactly one of Template or File must be set.
+type BodyTemplateConfig struct {
+	// Template is an inline Go template for the log body
+	Template string `mapstructure:"template"`
+
+	// File is the path of a file containing the Go template for the log body
+	File string `mapstructure:"file"`
+}
+
+// bodyTemplateData is the data passed to log body templates
+type bodyTemplateData struct {
+	EventName       string
+	SpanName        string
+	EventAttributes map[string]string
+	SpanAttributes  map[string]string
+}
+
+// allowedTemplateFields lists the fields of bodyTemplateData a template may reference
+var allowedTemplateFields = map[string]struct{}{
+	"EventName":       {},
+	"SpanName":        {},
+	"EventAttributes": {},
+	"SpanAttributes":  {},
+}
+
+// templateFields is a set of bodyTemplateData fields
+type templateFields uint8
+
+const (
+	templateEventName templateFields = 1 << iota
+	templateSpanName
+	templateEventAttributes
+	templateSpanAttributes
+	// templateDot marks templates that use the data as a whole ({{.}} or $)
+	templateDot
+
+	templateAllFields = templateEventName | templateSpanName | templateEventAttributes | templateSpanAttributes | templateDot
+)
+
+var templateFieldsByName = map[string]templateFields{
+	"EventName":       templateEventName,
+	"SpanName":        templateSpanName,
+	"EventAttributes": templateEventAttributes,
+	"SpanAttributes":  templateSpanAttributes,
+}
+
+// bodyTemplate is a parsed log body template together with the data it references,
+// so that rendering only materialises what the template reads
+type bodyTemplate struct {
+	// rule is the body_templates key that selects this template, or "default"
+	rule   string
+	tmpl   *template.Template
+	fields templateFields
+	// constant is set when the template references no data; text is then its output
+	constant bool
+	text     string
+}
+
+// ruleName returns the rule a log record produced with this template belongs to
+func (t *bodyTemplate) ruleName() string {
+	if t == nil {
+		return defaultBodyTemplateKey
+	}
+	return t.rule
+}
+
+type globBodyTemplate struct {
+	pattern string
+	tmpl    *bodyTemplate
+}
+
+// bodyTemplateSet selects the log body template for a span event by event name.
+// Lookup order is: exact event name, glob patterns (longest first), the
+// "default" entry, then log_body_template.
+type bodyTemplateSet struct {
+	exact    map[string]*bodyTemplate
+	globs    []globBodyTemplate
+	fallback *bodyTemplate
+}
+
+// lookup returns the template for the given event name, or nil if none is configured
+func (s *bodyTemplateSet) lookup(eventName string) *bodyTemplate {
+	if s == nil {
+		return nil
+	}
+	if tmpl, ok := s.exact[eventName]; ok {
+		return tmpl
+	}
+	for _, g := range s.globs {
+		if ok, _ := path.Match(g.pattern, eventName); ok {
+			return g.tmpl
+		}
+	}
+	return s.fallback
+}
+
+// compileBodyTemplates parses and validates every log body template in the config,
+// together with the shared partials loaded from template_files.
+func compileBodyTemplates(cfg *Config) (*bodyTemplateSet, error) {
+	partials := template.New("template_files")
+	for _, file := range cfg.TemplateFiles {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template_files entry: %w", err)
+		}
+		if _, err := partials.New(file).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("invalid template_files entry: %w", err)
+		}
+	}
+
+	set := &bodyTemplateSet{exact: make(map[string]*bodyTemplate)}
+
+	if cfg.LogBodyTemplate != "" {
+		tmpl, err := parseBodyTemplate(partials, "log_body_template", cfg.LogBodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log_body_template: %w", err)
+		}
+		tmpl.rule = defaultBodyTemplateKey
+		set.fallback = tmpl
+	}
+
+	for key, tc := range cfg.BodyTemplates {
+		name, text, err := tc.load(key)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := parseBodyTemplate(partials, name, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body_templates[%q]: %w", key, err)
+		}
+		tmpl.rule = key
+		switch {
+		case key == defaultBodyTemplateKey:
+			set.fallback = tmpl
+		case isGlobPattern(key):
+			if _, err := path.Match(key, ""); err != nil {
+				return nil, fmt.Errorf("invalid body_templates[%q]: bad glob pattern: %w", key, err)
+			}
+			set.globs = append(set.globs, globBodyTemplate{pattern: key, tmpl: tmpl})
+		default:
+			set.exact[key] = tmpl
+		}
+	}
+
+	// More specific (longer) patterns win; ties are broken lexically so that
+	// lookups are deterministic regardless of map iteration order.
+	sort.Slice(set.globs, func(i, j int) bool {
+		if len(set.globs[i].pattern) != len(set.globs[j].pattern) {
+			return len(set.globs[i].pattern) > len(set.globs[j].pattern)
+		}
+		return set.globs[i].pattern < set.globs[j].pattern
+	})
+
+	return set, nil
+}
+
+// load returns the template name used in error messages and the template text
+func (tc BodyTemplateConfig) load(key string) (string, string, error) {
+	switch {
+	case tc.Template != "" && tc.File != "":
+		return "", "", fmt.Errorf("invalid body_templates[%q]: only one of template or file may be set", key)
+	case tc.File != "":
+		content, err := os.ReadFile(tc.File)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid body_templates[%q]: %w", key, err)
+		}
+		return tc.File, string(content), nil
+	case tc.Template != "":
+		return fmt.Sprintf("body_templates[%s]", key), tc.Template, nil
+	default:
+		return "", "", fmt.Errorf("invalid body_templates[%q]: one of template or file must be set", key)
+	}
+}
+
+func isGlobPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// parseBodyTemplate parses text as a new template named name on top of a copy
+// of the partials, validates it against bodyTemplateData and analyses which data it uses.
+func parseBodyTemplate(partials *template.Template, name, text string) (*bodyTemplate, error) {
+	base, err := partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := base.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try to execute the template with dummy data to catch invalid fields and missing partials
+	dummy := bodyTemplateData{
+		EventName:       "event",
+		SpanName:        "span",
+		EventAttributes: map[string]string{"key": "value"},
+		SpanAttributes:  map[string]string{"key": "value"},
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, dummy); err != nil {
+		return nil, fmt.Errorf("execution: %w", err)
+	}
+
+	// Strict validation: walk the template ASTs and ensure only allowed fields are referenced
+	for _, t := range tmpl.Templates() {
+		if t == nil || t.Tree == nil {
+			continue
+		}
+		if err := validateTemplateNode(t.Tree, t.Tree.Root); err != nil {
+			return nil, err
+		}
+	}
+
+	bt := &bodyTemplate{tmpl: tmpl, fields: templateFieldsOf(tmpl)}
+	if bt.fields == 0 {
+		// The output cannot depend on the event, so render it once
+		buf.Reset()
+		if err := tmpl.Execute(&buf, bodyTemplateData{}); err != nil {
+			return nil, fmt.Errorf("execution: %w", err)
+		}
+		bt.constant = true
+		bt.text = buf.String()
+	}
+	return bt, nil
+}
+
+// templateFieldsOf returns the data fields read by tmpl and the templates it calls
+func templateFieldsOf(tmpl *template.Template) templateFields {
+	var fields templateFields
+	visited := make(map[string]bool)
+	var walkTemplate func(t *template.Template)
+	var walk func(n parse.Node)
+	walkTemplate = func(t *template.Template) {
+		if t == nil || t.Tree == nil || visited[t.Name()] {
+			return
+		}
+		visited[t.Name()] = true
+		walk(t.Tree.Root)
+	}
+	walk = func(n parse.Node) {
+		switch node := n.(type) {
+		case *parse.FieldNode:
+			fields |= templateFieldsByName[node.Ident[0]]
+		case *parse.VariableNode:
+			switch {
+			case len(node.Ident) > 1 && node.Ident[0] == "$":
+				fields |= templateFieldsByName[node.Ident[1]]
+			case node.Ident[0] == "$":
+				fields |= templateAllFields
+			}
+		case *parse.DotNode:
+			fields |= templateAllFields
+		case *parse.ChainNode:
+			walk(node.Node)
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, child := range node.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.TemplateNode:
+			// {{template "name" .}} hands the data to the called template, which is walked itself
+			if !isDotPipe(node.Pipe) {
+				walk(node.Pipe)
+			}
+			walkTemplate(tmpl.Lookup(node.Name))
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		}
+	}
+	walkTemplate(tmpl)
+	return fields
+}
+
+func isDotPipe(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	_, ok := pipe.Cmds[0].Args[0].(*parse.DotNode)
+	return ok
+}
+
+var bodyTemplateDataPool = sync.Pool{
+	New: func() any {
+		return &bodyTemplateData{
+			EventAttributes: make(map[string]string),
+			SpanAttributes:  make(map[string]string),
+		}
+	},
+}
+
+var bodyBufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// maxPooledBufferSize keeps unusually large bodies from pinning memory in the pool
+const maxPooledBufferSize = 64 << 10
+
+// render executes the template for a span event, filling in only the data it references
+func (t *bodyTemplate) render(event ptrace.SpanEvent, span ptrace.Span) (string, error) {
+	if t.constant {
+		return t.text, nil
+	}
+
+	data := bodyTemplateDataPool.Get().(*bodyTemplateData)
+	defer func() {
+		data.EventName, data.SpanName = "", ""
+		clear(data.EventAttributes)
+		clear(data.SpanAttributes)
+		bodyTemplateDataPool.Put(data)
+	}()
+	if t.fields&templateEventName != 0 {
+		data.EventName = event.Name()
+	}
+	if t.fields&templateSpanName != 0 {
+		data.SpanName = span.Name()
+	}
+	if t.fields&templateEventAttributes != 0 {
+		fillTemplateAttributes(data.EventAttributes, event.Attributes())
+	}
+	if t.fields&templateSpanAttributes != 0 {
+		fillTemplateAttributes(data.SpanAttributes, span.Attributes())
+	}
+
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			buf.Reset()
+			bodyBufferPool.Put(buf)
+		}
+	}()
+
+	var err error
+	if t.fields&templateDot != 0 {
+		// The data is printed or passed on as a whole, so keep it a value as before
+		err = t.tmpl.Execute(buf, *data)
+	} else {
+		err = t.tmpl.Execute(buf, data)
+	}
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func fillTemplateAttributes(dst map[string]string, attrs pcommon.Map) {
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		dst[k] = v.AsString()
+		return true
+	})
+}
+
+// validateTemplateNode walks the template AST rooted at n and returns an error,
+// prefixed with the template name and line, for references to unknown fields.
+func validateTemplateNode(tree *parse.Tree, n parse.Node) error {
+	if n == nil {
+		return nil
+	}
+	switch node := n.(type) {
+	case *parse.FieldNode:
+		if len(node.Ident) > 0 {
+			return checkTemplateField(tree, node, node.Ident[0])
+		}
+	case *parse.VariableNode:
+		// Only "$.Field" refers to the template data; other variables are user-defined.
+		if len(node.Ident) > 1 && node.Ident[0] == "$" {
+			return checkTemplateField(tree, node, node.Ident[1])
+		}
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, child := range node.Nodes {
+			if err := validateTemplateNode(tree, child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return validateTemplateNode(tree, node.Pipe)
+	case *parse.TemplateNode:
+		if node.Pipe != nil {
+			return validateTemplateNode(tree, node.Pipe)
+		}
+	case *parse.PipeNode:
+		if node == nil {
+			return nil
+		}
+		for _, cmd := range node.Cmds {
+			if err := validateTemplateNode(tree, cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			if err := validateTemplateNode(tree, arg); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return validateBranchNode(tree, &node.BranchNode)
+	case *parse.RangeNode:
+		return validateBranchNode(tree, &node.BranchNode)
+	case *parse.WithNode:
+		return validateBranchNode(tree, &node.BranchNode)
+	}
+	return nil
+}
+
+func validateBranchNode(tree *parse.Tree, node *parse.BranchNode) error {
+	return errors.Join(
+		validateTemplateNode(tree, node.Pipe),
+		validateTemplateNode(tree, node.List),
+		validateTemplateNode(tree, node.ElseList),
+	)
+}
+
+func checkTemplateField(tree *parse.Tree, node parse.Node, field string) error {
+	if _, ok := allowedTemplateFields[field]; ok {
+		return nil
+	}
+	location, _ := tree.ErrorContext(node)
+	return fmt.Errorf("%s: invalid field .%s is not allowed", location, field)
+}
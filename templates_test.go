@@ -0,0 +1,235 @@
+package spaneventstologconnector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func renderBodyTemplate(t *testing.T, set *bodyTemplateSet, eventName string) string {
+	t.Helper()
+	tmpl := set.lookup(eventName)
+	if tmpl == nil {
+		t.Fatalf("no template for event %q", eventName)
+	}
+	span := ptrace.NewSpan()
+	span.SetName("GET /api/cart")
+	event := span.Events().AppendEmpty()
+	event.SetName(eventName)
+	event.Attributes().PutStr("exception.type", "ConnectionError")
+	body, err := tmpl.render(event, span)
+	if err != nil {
+		t.Fatalf("execute template: %v", err)
+	}
+	return body
+}
+
+func TestBodyTemplates_Lookup(t *testing.T) {
+	cfg := &Config{
+		EventConditions: []string{`name == "exception"`},
+		LogBodyTemplate: "fallback {{.EventName}}",
+		BodyTemplates: map[string]BodyTemplateConfig{
+			"exception":  {Template: `exception {{index .EventAttributes "exception.type"}}`},
+			"cart.*":     {Template: "cart {{.EventName}}"},
+			"cart.item*": {Template: "cart item {{.EventName}}"},
+		},
+	}
+	set, err := compileBodyTemplates(cfg)
+	if err != nil {
+		t.Fatalf("compileBodyTemplates() error = %v", err)
+	}
+
+	tests := map[string]string{
+		"exception":       "exception ConnectionError",
+		"cart.checkout":   "cart cart.checkout",
+		"cart.item_added": "cart item cart.item_added",
+		"other":           "fallback other",
+	}
+	for event, want := range tests {
+		if got := renderBodyTemplate(t, set, event); got != want {
+			t.Errorf("event %q: got %q, want %q", event, got, want)
+		}
+	}
+
+	cfg.BodyTemplates[defaultBodyTemplateKey] = BodyTemplateConfig{Template: "default {{.EventName}}"}
+	set, err = compileBodyTemplates(cfg)
+	if err != nil {
+		t.Fatalf("compileBodyTemplates() error = %v", err)
+	}
+	if got := renderBodyTemplate(t, set, "other"); got != "default other" {
+		t.Errorf("default template: got %q", got)
+	}
+}
+
+func TestBodyTemplates_FilesAndPartials(t *testing.T) {
+	dir := t.TempDir()
+	partials := filepath.Join(dir, "partials.tmpl")
+	writeFile(t, partials, `{{define "where"}}in {{.SpanName}}{{end}}`)
+	exception := filepath.Join(dir, "exception.tmpl")
+	writeFile(t, exception, `{{.EventName}} {{template "where" .}}`)
+
+	cfg := &Config{
+		EventConditions: []string{`name == "exception"`},
+		TemplateFiles:   []string{partials},
+		BodyTemplates: map[string]BodyTemplateConfig{
+			"exception": {File: exception},
+			"default":   {Template: `event {{template "where" .}}`},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	set, err := compileBodyTemplates(cfg)
+	if err != nil {
+		t.Fatalf("compileBodyTemplates() error = %v", err)
+	}
+	if got := renderBodyTemplate(t, set, "exception"); got != "exception in GET /api/cart" {
+		t.Errorf("file template: got %q", got)
+	}
+	if got := renderBodyTemplate(t, set, "cart.item_added"); got != "event in GET /api/cart" {
+		t.Errorf("default template: got %q", got)
+	}
+}
+
+func TestBodyTemplates_ValidationErrors(t *testing.T) {
+	dir := t.TempDir()
+	badField := filepath.Join(dir, "bad_field.tmpl")
+	writeFile(t, badField, "line one\n{{.Unknown}}\n")
+	badSyntax := filepath.Join(dir, "bad_syntax.tmpl")
+	writeFile(t, badSyntax, "line one\nline two\n{{end}}\n")
+
+	tests := []struct {
+		name      string
+		templates map[string]BodyTemplateConfig
+		files     []string
+		wantErr   string
+	}{
+		{
+			name:      "unknown_field_in_file",
+			templates: map[string]BodyTemplateConfig{"exception": {File: badField}},
+			wantErr:   badField + ":2:",
+		},
+		{
+			name:      "syntax_error_in_partials",
+			templates: map[string]BodyTemplateConfig{"exception": {Template: "{{.EventName}}"}},
+			files:     []string{badSyntax},
+			wantErr:   badSyntax + ":3:",
+		},
+		{
+			name:      "missing_partial",
+			templates: map[string]BodyTemplateConfig{"exception": {Template: `{{template "nope" .}}`}},
+			wantErr:   `template "nope" not defined`,
+		},
+		{
+			name:      "both_template_and_file",
+			templates: map[string]BodyTemplateConfig{"exception": {Template: "x", File: badField}},
+			wantErr:   "only one of template or file",
+		},
+		{
+			name:      "bad_glob",
+			templates: map[string]BodyTemplateConfig{"cart.[": {Template: "x"}},
+			wantErr:   "bad glob pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				EventConditions: []string{`name == "exception"`},
+				BodyTemplates:   tt.templates,
+				TemplateFiles:   tt.files,
+			}
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatal("Validate() expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBodyTemplates_Analysis(t *testing.T) {
+	partials := template.Must(template.New("template_files").Parse(`{{define "attrs"}}{{index .SpanAttributes "k"}}{{end}}{{define "unused"}}{{.EventAttributes}}{{end}}`))
+	tests := []struct {
+		text     string
+		fields   templateFields
+		constant bool
+		want     string
+	}{
+		{text: "Span Event: {{.EventName}}", fields: templateEventName, want: "Span Event: exception"},
+		{text: "static body", constant: true, want: "static body"},
+		{text: `{{printf "%s-%d" "x" 1}}`, constant: true, want: "x-1"},
+		{text: `{{range $k, $v := .EventAttributes}}{{$k}}={{$v}}{{end}}`, fields: templateEventAttributes, want: "exception.type=ConnectionError"},
+		{text: `{{$.SpanName}} {{template "attrs" .}}`, fields: templateSpanName | templateSpanAttributes, want: "GET /api/cart v"},
+		{text: `{{with .EventName}}{{.}}{{end}}`, fields: templateAllFields, want: "exception"},
+	}
+	span := ptrace.NewSpan()
+	span.SetName("GET /api/cart")
+	span.Attributes().PutStr("k", "v")
+	event := span.Events().AppendEmpty()
+	event.SetName("exception")
+	event.Attributes().PutStr("exception.type", "ConnectionError")
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			bt, err := parseBodyTemplate(partials, "test", tt.text)
+			if err != nil {
+				t.Fatalf("parseBodyTemplate() error = %v", err)
+			}
+			if bt.fields != tt.fields || bt.constant != tt.constant {
+				t.Errorf("fields = %b constant = %v, want %b %v", bt.fields, bt.constant, tt.fields, tt.constant)
+			}
+			got, err := bt.render(event, span)
+			if err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkBodyTemplate_Render(b *testing.B) {
+	span := ptrace.NewSpan()
+	span.SetName("GET /api/cart")
+	for i := 0; i < 20; i++ {
+		span.Attributes().PutStr(fmt.Sprintf("span.attr.%d", i), "value")
+	}
+	event := span.Events().AppendEmpty()
+	event.SetName("exception")
+	event.Attributes().PutStr("exception.message", "Connection refused")
+
+	for name, text := range map[string]string{
+		"default":    "Span Event: {{.EventName}}",
+		"constant":   "exception recorded",
+		"attributes": `{{.SpanName}}: {{index .EventAttributes "exception.message"}}`,
+	} {
+		b.Run(name, func(b *testing.B) {
+			bt, err := parseBodyTemplate(template.New("template_files"), name, text)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := bt.render(event, span); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
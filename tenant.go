@@ -0,0 +1,208 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// TenantConfig copies tenant identifiers of the incoming traces to the produced logs
+type TenantConfig struct {
+	// Keys are the tenant identifiers; each is read from the first source that has a value
+	Keys []TenantKeyConfig `mapstructure:"keys"`
+}
+
+// TenantKeyConfig reads one tenant identifier and stamps it on the produced logs
+type TenantKeyConfig struct {
+	// FromMetadata is a client metadata key of the incoming request, such as X-Scope-OrgID.
+	// The lookup is case-insensitive
+	FromMetadata string `mapstructure:"from_metadata"`
+
+	// FromAuth is an attribute of the auth data set by the receiver's authenticator
+	FromAuth string `mapstructure:"from_auth"`
+
+	// FromResourceAttribute is a resource attribute of the incoming traces
+	FromResourceAttribute string `mapstructure:"from_resource_attribute"`
+
+	// ResourceAttribute is the resource attribute set on the produced logs.
+	// It defaults to FromResourceAttribute
+	ResourceAttribute string `mapstructure:"resource_attribute"`
+
+	// MetadataKey is the client metadata key the value is forwarded under when the logs
+	// are passed on. It defaults to FromMetadata; without either the value is not forwarded
+	MetadataKey string `mapstructure:"metadata_key"`
+}
+
+func (cfg *TenantConfig) validate() error {
+	for i, key := range cfg.Keys {
+		if key.FromMetadata == "" && key.FromAuth == "" && key.FromResourceAttribute == "" {
+			return fmt.Errorf("tenant.keys[%d]: at least one of from_metadata, from_auth or from_resource_attribute must be specified", i)
+		}
+		if key.ResourceAttribute == "" && key.FromResourceAttribute == "" {
+			return fmt.Errorf("tenant.keys[%d]: resource_attribute must be specified", i)
+		}
+	}
+	return nil
+}
+
+// tenantKey is a TenantKeyConfig with its defaults applied
+type tenantKey struct {
+	fromMetadata          string
+	fromAuth              string
+	fromResourceAttribute string
+	resourceAttribute     string
+	metadataKey           string
+}
+
+// tenantStamper stamps tenant identifiers on produced logs and forwards them as client metadata
+type tenantStamper struct {
+	keys []tenantKey
+	// forward is whether any key is forwarded as client metadata
+	forward bool
+}
+
+// newTenantStamper returns nil when no tenant keys are configured
+func newTenantStamper(cfg TenantConfig) *tenantStamper {
+	if len(cfg.Keys) == 0 {
+		return nil
+	}
+	t := &tenantStamper{}
+	for _, key := range cfg.Keys {
+		k := tenantKey{
+			fromMetadata:          key.FromMetadata,
+			fromAuth:              key.FromAuth,
+			fromResourceAttribute: key.FromResourceAttribute,
+			resourceAttribute:     key.ResourceAttribute,
+			metadataKey:           key.MetadataKey,
+		}
+		if k.resourceAttribute == "" {
+			k.resourceAttribute = k.fromResourceAttribute
+		}
+		if k.metadataKey == "" {
+			k.metadataKey = k.fromMetadata
+		}
+		t.forward = t.forward || k.metadataKey != ""
+		t.keys = append(t.keys, k)
+	}
+	return t
+}
+
+// value reads the identifier of key from the request info, falling back to the traces resource
+func (k *tenantKey) value(info client.Info, resource pcommon.Resource) (string, bool) {
+	if k.fromMetadata != "" {
+		if values := info.Metadata.Get(k.fromMetadata); len(values) > 0 {
+			return values[0], true
+		}
+	}
+	if k.fromAuth != "" && info.Auth != nil {
+		if value, ok := info.Auth.GetAttribute(k.fromAuth).(string); ok && value != "" {
+			return value, true
+		}
+	}
+	if k.fromResourceAttribute != "" {
+		if value, ok := resource.Attributes().Get(k.fromResourceAttribute); ok {
+			return value.AsString(), true
+		}
+	}
+	return "", false
+}
+
+// stamp sets the tenant identifiers of the traces resource src, received with ctx, on the
+// logs resource dest
+func (t *tenantStamper) stamp(ctx context.Context, src, dest pcommon.Resource) {
+	if t == nil {
+		return
+	}
+	info := client.FromContext(ctx)
+	for i := range t.keys {
+		if value, ok := t.keys[i].value(info, src); ok {
+			dest.Attributes().PutStr(t.keys[i].resourceAttribute, value)
+		}
+	}
+}
+
+// split groups logs by the tenant identifiers stamped on their resources. It returns one
+// context per group that carries the identifiers as client metadata, and the group of
+// every ResourceLogs of logs, which is nil when there is only one. Logs of a single
+// tenant are not copied.
+func (t *tenantStamper) split(ctx context.Context, logs plog.Logs) ([]context.Context, []plog.Logs, []int) {
+	if t == nil || !t.forward {
+		return []context.Context{ctx}, []plog.Logs{logs}, nil
+	}
+
+	resourceLogs := logs.ResourceLogs()
+	groupKeys := make([]string, resourceLogs.Len())
+	groups := make(map[string]int)
+	var order []string
+	for i := 0; i < resourceLogs.Len(); i++ {
+		groupKeys[i] = t.groupKey(resourceLogs.At(i).Resource())
+		if _, ok := groups[groupKeys[i]]; !ok {
+			groups[groupKeys[i]] = len(order)
+			order = append(order, groupKeys[i])
+		}
+	}
+
+	if len(order) == 1 {
+		return []context.Context{t.withMetadata(ctx, resourceLogs.At(0).Resource())}, []plog.Logs{logs}, nil
+	}
+	ctxs := make([]context.Context, len(order))
+	parts := make([]plog.Logs, len(order))
+	for i := range parts {
+		parts[i] = plog.NewLogs()
+	}
+	groupOf := make([]int, resourceLogs.Len())
+	for i := 0; i < resourceLogs.Len(); i++ {
+		group := groups[groupKeys[i]]
+		groupOf[i] = group
+		if ctxs[group] == nil {
+			ctxs[group] = t.withMetadata(ctx, resourceLogs.At(i).Resource())
+		}
+		resourceLogs.At(i).MoveTo(parts[group].ResourceLogs().AppendEmpty())
+	}
+	return ctxs, parts, groupOf
+}
+
+// groupKey joins the forwarded tenant identifiers stamped on resource. Values of other
+// types, which the stamper did not set, are kept apart from strings by their type.
+func (t *tenantStamper) groupKey(resource pcommon.Resource) string {
+	var b strings.Builder
+	for i := range t.keys {
+		if t.keys[i].metadataKey == "" {
+			continue
+		}
+		if value, ok := resource.Attributes().Get(t.keys[i].resourceAttribute); ok {
+			b.WriteString(value.Type().String())
+			b.WriteByte(':')
+			b.WriteString(value.AsString())
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// withMetadata returns ctx with the tenant identifiers stamped on resource added to its
+// client metadata. Existing metadata under other keys is kept. The stamper only sets
+// strings, so attributes of other types came with the traces and are not forwarded.
+func (t *tenantStamper) withMetadata(ctx context.Context, resource pcommon.Resource) context.Context {
+	info := client.FromContext(ctx)
+	md := make(map[string][]string)
+	for key := range info.Metadata.Keys() {
+		md[key] = info.Metadata.Get(key)
+	}
+	for i := range t.keys {
+		if t.keys[i].metadataKey == "" {
+			continue
+		}
+		if value, ok := resource.Attributes().Get(t.keys[i].resourceAttribute); ok && value.Type() == pcommon.ValueTypeStr {
+			md[strings.ToLower(t.keys[i].metadataKey)] = []string{value.Str()}
+		}
+	}
+	return client.NewContext(ctx, client.Info{Addr: info.Addr, Auth: info.Auth, Metadata: client.NewMetadata(md)})
+}
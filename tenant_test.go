@@ -0,0 +1,196 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// metadataSink records the client metadata key of every ConsumeLogs call
+type metadataSink struct {
+	key   string
+	mu    sync.Mutex
+	calls []metadataCall
+}
+
+type metadataCall struct {
+	metadata []string
+	logs     plog.Logs
+}
+
+func (s *metadataSink) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{}
+}
+
+func (s *metadataSink) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, metadataCall{metadata: client.FromContext(ctx).Metadata.Get(s.key), logs: ld})
+	return nil
+}
+
+// testAuthData is auth data with a single string attribute
+type testAuthData struct {
+	name, value string
+}
+
+func (a testAuthData) GetAttribute(name string) any {
+	if name == a.name {
+		return a.value
+	}
+	return nil
+}
+
+func (a testAuthData) GetAttributeNames() []string {
+	return []string{a.name}
+}
+
+func TestConnector_TenantFromClientInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		key  TenantKeyConfig
+		info client.Info
+	}{
+		{
+			name: "metadata",
+			key:  TenantKeyConfig{FromMetadata: "X-Scope-OrgID", ResourceAttribute: "tenant.id"},
+			info: client.Info{Metadata: client.NewMetadata(map[string][]string{"x-scope-orgid": {"acme"}})},
+		},
+		{
+			name: "auth",
+			key:  TenantKeyConfig{FromAuth: "tenant", ResourceAttribute: "tenant.id", MetadataKey: "X-Scope-OrgID"},
+			info: client.Info{Auth: testAuthData{name: "tenant", value: "acme"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.EventConditions = []string{`name == "exception"`}
+			cfg.Tenant = TenantConfig{Keys: []TenantKeyConfig{tc.key}}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			sink := &metadataSink{key: "X-Scope-OrgID"}
+			conn, _ := newTelemetryTestConnector(t, cfg, sink)
+
+			ctx := client.NewContext(context.Background(), tc.info)
+			if err := conn.ConsumeTraces(ctx, newTestTraces("GET /api/cart", "exception")); err != nil {
+				t.Fatalf("ConsumeTraces() error = %v", err)
+			}
+			if len(sink.calls) != 1 {
+				t.Fatalf("got %d ConsumeLogs calls, want 1", len(sink.calls))
+			}
+			call := sink.calls[0]
+			if len(call.metadata) != 1 || call.metadata[0] != "acme" {
+				t.Errorf("forwarded metadata = %v, want [acme]", call.metadata)
+			}
+			v, ok := call.logs.ResourceLogs().At(0).Resource().Attributes().Get("tenant.id")
+			if !ok || v.Str() != "acme" {
+				t.Errorf("tenant.id = %v, want acme", v.AsString())
+			}
+		})
+	}
+}
+
+func TestConnector_TenantFromResourceAttribute(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.Tenant = TenantConfig{Keys: []TenantKeyConfig{{
+		FromMetadata:          "X-Scope-OrgID",
+		FromResourceAttribute: "tenant",
+		ResourceAttribute:     "tenant.id",
+	}}}
+	sink := &metadataSink{key: "X-Scope-OrgID"}
+	conn, _ := newTelemetryTestConnector(t, cfg, sink)
+
+	// Without request metadata every ResourceSpans falls back to its own tenant
+	td := newTestTraces("GET /api/cart", "exception", "exception")
+	td.ResourceSpans().At(0).Resource().Attributes().PutStr("tenant", "acme")
+	other := newTestTraces("GET /api/cart", "exception")
+	other.ResourceSpans().At(0).Resource().Attributes().PutStr("tenant", "globex")
+	other.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+
+	want := map[string]int{"acme": 2, "globex": 1}
+	if len(sink.calls) != len(want) {
+		t.Fatalf("got %d ConsumeLogs calls, want %d", len(sink.calls), len(want))
+	}
+	for _, call := range sink.calls {
+		if len(call.metadata) != 1 {
+			t.Fatalf("forwarded metadata = %v, want one tenant", call.metadata)
+		}
+		if got := call.logs.LogRecordCount(); got != want[call.metadata[0]] {
+			t.Errorf("tenant %s got %d records, want %d", call.metadata[0], got, want[call.metadata[0]])
+		}
+	}
+}
+
+func TestConnector_TenantNonStringAttributes(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.Tenant = TenantConfig{Keys: []TenantKeyConfig{
+		{FromResourceAttribute: "org", ResourceAttribute: "org.id", MetadataKey: "X-Org"},
+		{FromMetadata: "X-Scope-OrgID", ResourceAttribute: "tenant.id"},
+	}}
+	sink := &metadataSink{key: "X-Scope-OrgID"}
+	conn, _ := newTelemetryTestConnector(t, cfg, sink)
+
+	// tenant.id comes with the traces as an int, so the stamper did not set it; org is
+	// read by the stamper and forwarded as a string
+	td := newTestTraces("GET /api/cart", "exception")
+	td.ResourceSpans().At(0).Resource().Attributes().PutInt("org", 7)
+	td.ResourceSpans().At(0).Resource().Attributes().PutInt("tenant.id", 1)
+	other := newTestTraces("GET /api/cart", "exception")
+	other.ResourceSpans().At(0).Resource().Attributes().PutInt("org", 7)
+	other.ResourceSpans().At(0).Resource().Attributes().PutInt("tenant.id", 2)
+	other.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+
+	if len(sink.calls) != 2 {
+		t.Fatalf("got %d ConsumeLogs calls, want one per tenant.id", len(sink.calls))
+	}
+	for _, call := range sink.calls {
+		if len(call.metadata) != 0 {
+			t.Errorf("forwarded X-Scope-OrgID = %v, want none", call.metadata)
+		}
+		if got := call.logs.LogRecordCount(); got != 1 {
+			t.Errorf("got %d records, want 1", got)
+		}
+		if v, _ := call.logs.ResourceLogs().At(0).Resource().Attributes().Get("org.id"); v.Str() != "7" {
+			t.Errorf("org.id = %q, want 7", v.AsString())
+		}
+	}
+
+	resource := pcommon.NewResource()
+	resource.Attributes().PutStr("org.id", "7")
+	resource.Attributes().PutInt("tenant.id", 1)
+	md := client.FromContext(conn.tenant.withMetadata(context.Background(), resource)).Metadata
+	if got := md.Get("X-Org"); len(got) != 1 || got[0] != "7" {
+		t.Errorf("forwarded X-Org = %v, want [7]", got)
+	}
+	if got := md.Get("X-Scope-OrgID"); len(got) != 0 {
+		t.Errorf("forwarded X-Scope-OrgID = %v, want none", got)
+	}
+}
+
+func TestConfig_Tenant(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name == "exception"`}
+	cfg.Tenant = TenantConfig{Keys: []TenantKeyConfig{{ResourceAttribute: "tenant.id"}}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for a key without a source")
+	}
+	cfg.Tenant = TenantConfig{Keys: []TenantKeyConfig{{FromMetadata: "X-Scope-OrgID"}}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for a key without resource_attribute")
+	}
+}
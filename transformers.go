@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package spaneventstologconnector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// EventTransformer adds custom logic to the conversion of span events, such as mapping
+// internal error codes. Custom collector builds register transformers with WithTransformers,
+// and the transformers option of the configuration enables them by name.
+type EventTransformer interface {
+	// Name is the name the configuration refers to the transformer by
+	Name() string
+
+	// Transform is called for every matching span event with the log record created for it,
+	// after the body, attributes and expressions are set. It may change the record, and
+	// returns false to drop it. When it returns an error, the error is logged and counted,
+	// and the record is passed on to the next transformer unchanged by the connector.
+	Transform(ctx context.Context, event EventContext, record plog.LogRecord) (bool, error)
+}
+
+// EventContext is the span event a log record is created from. Transformers must not
+// modify it.
+type EventContext struct {
+	Event    ptrace.SpanEvent
+	Span     ptrace.Span
+	Scope    pcommon.InstrumentationScope
+	Resource pcommon.Resource
+	// Rule is the body_templates key that selected the log body template, or "default"
+	Rule string
+}
+
+// transformerChain runs the configured transformers in order
+type transformerChain struct {
+	transformers []EventTransformer
+	logger       *zap.Logger
+	errorCounter metric.Int64Counter
+}
+
+// newTransformerChain resolves names against the registered transformers. It returns nil
+// when no transformers are configured.
+func newTransformerChain(names []string, registered map[string]EventTransformer, logger *zap.Logger, errorCounter metric.Int64Counter) (*transformerChain, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	chain := &transformerChain{logger: logger, errorCounter: errorCounter}
+	for _, name := range names {
+		t, ok := registered[name]
+		if !ok {
+			return nil, unknownTransformerError(name, registered)
+		}
+		chain.transformers = append(chain.transformers, t)
+	}
+	return chain, nil
+}
+
+// validateTransformers checks that every name refers to a registered transformer
+func validateTransformers(names []string, registered map[string]EventTransformer) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := registered[name]; !ok {
+			return unknownTransformerError(name, registered)
+		}
+		if seen[name] {
+			return fmt.Errorf("invalid transformers: %q is listed twice, must be unique", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func unknownTransformerError(name string, registered map[string]EventTransformer) error {
+	names := make([]string, 0, len(registered))
+	for n := range registered {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return fmt.Errorf("invalid transformers: %q is not registered, must be one of %v", name, names)
+}
+
+// transform runs the transformers on record and reports whether it is kept
+func (c *transformerChain) transform(ctx context.Context, event EventContext, record plog.LogRecord, stats *conversionStats) bool {
+	if c == nil {
+		return true
+	}
+	for _, t := range c.transformers {
+		keep, err := t.Transform(ctx, event, record)
+		if err != nil {
+			stats.transformerErrors++
+			c.errorCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("transformer", t.Name())))
+			c.logger.Error("Failed to run event transformer", zap.String("transformer", t.Name()), zap.Error(err))
+			continue
+		}
+		if !keep {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeTransformers returns a copy of registered with transformers added. A transformer
+// replaces one registered under the same name.
+func mergeTransformers(registered map[string]EventTransformer, transformers ...EventTransformer) map[string]EventTransformer {
+	merged := make(map[string]EventTransformer, len(registered)+len(transformers))
+	for name, t := range registered {
+		merged[name] = t
+	}
+	for _, t := range transformers {
+		merged[t.Name()] = t
+	}
+	return merged
+}
@@ -0,0 +1,144 @@
+package spaneventstologconnector
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// funcTransformer is an EventTransformer backed by a function
+type funcTransformer struct {
+	name string
+	fn   func(event EventContext, record plog.LogRecord) (bool, error)
+}
+
+func (f funcTransformer) Name() string {
+	return f.name
+}
+
+func (f funcTransformer) Transform(_ context.Context, event EventContext, record plog.LogRecord) (bool, error) {
+	return f.fn(event, record)
+}
+
+// newTestTransformers returns transformers that map exception types to internal error codes,
+// drop retry events and fail for checkout events
+func newTestTransformers() []EventTransformer {
+	return []EventTransformer{
+		funcTransformer{name: "error_codes", fn: func(event EventContext, record plog.LogRecord) (bool, error) {
+			if v, ok := event.Event.Attributes().Get("exception.type"); ok && strings.HasSuffix(v.Str(), "ConnectionError") {
+				record.Attributes().PutStr("error.code", "E_CONN")
+			}
+			record.Attributes().PutStr("rule", event.Rule)
+			return true, nil
+		}},
+		funcTransformer{name: "drop_retries", fn: func(event EventContext, _ plog.LogRecord) (bool, error) {
+			return event.Event.Name() != "retry", nil
+		}},
+		funcTransformer{name: "failing", fn: func(event EventContext, _ plog.LogRecord) (bool, error) {
+			if event.Event.Name() == "checkout" {
+				return false, errors.New("checkout is not supported")
+			}
+			return true, nil
+		}},
+	}
+}
+
+func TestConnector_Transformers(t *testing.T) {
+	factory := NewFactoryWithOptions(WithTransformers(newTestTransformers()...))
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name != "health"`}
+	cfg.BodyTemplates = map[string]BodyTemplateConfig{"exception": {Template: "{{.EventName}}"}}
+	cfg.Transformers = []string{"error_codes", "drop_retries", "failing"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	sink := new(consumertest.LogsSink)
+	conn, tt := newTelemetryTestConnector(t, cfg, sink)
+
+	td := newTestTraces("GET /api/cart", "exception", "retry", "checkout", "health")
+	if err := conn.ConsumeTraces(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraces() error = %v", err)
+	}
+
+	// The failing transformer keeps checkout; the retry record leaves no empty resource behind
+	logs := sink.AllLogs()[0]
+	if n := logs.ResourceLogs().Len(); n != 2 {
+		t.Fatalf("got %d resource logs, want 2", n)
+	}
+	for i, want := range []string{"exception", "checkout"} {
+		lr := logs.ResourceLogs().At(i).ScopeLogs().At(0).LogRecords().At(0)
+		if got, _ := lr.Attributes().Get("event.name"); got.Str() != want {
+			t.Errorf("record %d is event %q, want %q", i, got.Str(), want)
+		}
+		if got, _ := lr.Attributes().Get("error.code"); got.Str() != "E_CONN" {
+			t.Errorf("record %d error.code = %q, want E_CONN", i, got.Str())
+		}
+	}
+	if rule, _ := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get("rule"); rule.Str() != "exception" {
+		t.Errorf("rule = %q, want exception", rule.Str())
+	}
+
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_events_dropped", attribute.String("reason", "transformer")); got != 1 {
+		t.Errorf("events dropped by transformers = %d, want 1", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_transformer_errors", attribute.String("transformer", "failing")); got != 1 {
+		t.Errorf("transformer errors = %d, want 1", got)
+	}
+	if got := sumValue(t, tt, "otelcol_connector_spaneventstolog_events_matched"); got != 2 {
+		t.Errorf("events matched = %d, want 2", got)
+	}
+}
+
+func TestConverter_Transformers(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.EventConditions = []string{`name != "health"`}
+	cfg.Transformers = []string{"drop_retries", "failing"}
+	if _, err := NewConverter(cfg); err == nil {
+		t.Fatal("NewConverter() expected error for unregistered transformers")
+	}
+
+	converter, err := NewConverter(cfg, WithEventTransformers(newTestTransformers()...))
+	if err != nil {
+		t.Fatalf("NewConverter() error = %v", err)
+	}
+	logs, stats, err := converter.Convert(context.Background(), newTestTraces("GET /api/cart", "exception", "retry", "checkout"))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if logs.LogRecordCount() != 2 || stats.EventsMatched != 2 || stats.EventsDropped != 1 || stats.TransformerErrors != 1 {
+		t.Errorf("got %d records and stats %+v, want 2 records, 1 dropped event and 1 transformer error", logs.LogRecordCount(), stats)
+	}
+}
+
+func TestConfig_Transformers(t *testing.T) {
+	factory := NewFactoryWithOptions(WithTransformers(newTestTransformers()...))
+	tests := []struct {
+		name         string
+		transformers []string
+		wantErr      string
+	}{
+		{name: "none"},
+		{name: "registered", transformers: []string{"failing", "error_codes"}},
+		{name: "unknown", transformers: []string{"error_codes", "pii"}, wantErr: `invalid transformers: "pii" is not registered, must be one of [drop_retries error_codes failing]`},
+		{name: "duplicate", transformers: []string{"failing", "failing"}, wantErr: `invalid transformers: "failing" is listed twice, must be unique`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := factory.CreateDefaultConfig().(*Config)
+			cfg.EventConditions = []string{`name == "exception"`}
+			cfg.Transformers = tc.transformers
+			err := cfg.Validate()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("Validate() error = %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Errorf("Validate() error = %v, want %s", err, tc.wantErr)
+			}
+		})
+	}
+}